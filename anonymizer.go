@@ -0,0 +1,186 @@
+package xplane
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// hex digits of a name's hash in its pseudonym, more are only used on a collision
+	pseudonymHashLength = 6
+	// the random key pseudonyms are derived with, a plain hash of a name could be reversed by hashing the
+	// repo's contributor list
+	anonymizerKeyFile = "anonymizer_key"
+	anonymizerKeySize = 32
+)
+
+// maps author names to pseudonyms like "Author 3f9a0c", so that names never reach the LLM while it can still
+// tell contributors apart. a pseudonym is the name's HMAC under a key kept in .xplane rather than the order
+// names are seen in, so it's the same on every run and new contributors don't make the stored context churn
+type authorAnonymizer struct {
+	key        []byte
+	pseudonyms map[string]string
+	taken      map[string]bool
+	names      []string       // longest first, rebuilt lazily along with matcher
+	matcher    *regexp.Regexp // finds where a known name may start, rebuilt lazily whenever a new name is registered
+}
+
+func newAuthorAnonymizer(key []byte) *authorAnonymizer {
+	return &authorAnonymizer{key: key, pseudonyms: make(map[string]string), taken: make(map[string]bool)}
+}
+
+// an anonymizer that knows every author of the repository. without persistKey a missing key isn't saved and
+// only lasts for this run, for the entry points that mustn't write to .xplane
+func newRepoAnonymizer(gitRoot string, persistKey bool) (*authorAnonymizer, error) {
+	key, err := loadAnonymizerKey(gitRoot, persistKey)
+	if err != nil {
+		return nil, fmt.Errorf("error loading the anonymization key: %w", err)
+	}
+	anonymizer := newAuthorAnonymizer(key)
+	if err := anonymizer.learnRepoAuthors(gitRoot); err != nil {
+		return nil, fmt.Errorf("error collecting author names to anonymize: %w", err)
+	}
+	return anonymizer, nil
+}
+
+// reads the key from .xplane, generating it on first use
+func loadAnonymizerKey(gitRoot string, persist bool) ([]byte, error) {
+	keyPath := filepath.Join(gitRoot, contextDir, anonymizerKeyFile)
+	content, err := os.ReadFile(keyPath)
+	if err == nil {
+		key, decodeErr := hex.DecodeString(strings.TrimSpace(string(content)))
+		if decodeErr != nil || len(key) != anonymizerKeySize {
+			return nil, fmt.Errorf("%s is corrupted, delete it to start over with new pseudonyms", keyPath)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	key := make([]byte, anonymizerKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if !persist {
+		return key, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyPath, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// returns the pseudonym for name, deriving it on first sight
+func (a *authorAnonymizer) pseudonym(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return name
+	}
+	if pseudonym, ok := a.pseudonyms[name]; ok {
+		return pseudonym
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(name))
+	digits := hex.EncodeToString(mac.Sum(nil))
+	length := pseudonymHashLength
+	pseudonym := "Author " + digits[:length]
+	for a.taken[pseudonym] && length < len(digits) {
+		length++
+		pseudonym = "Author " + digits[:length]
+	}
+	a.pseudonyms[name] = pseudonym
+	a.taken[pseudonym] = true
+	a.matcher = nil
+	return pseudonym
+}
+
+// registers every author and committer name found in the repository history
+func (a *authorAnonymizer) learnRepoAuthors(gitRoot string) error {
+	names, err := runCommand(gitRoot, "git", "log", "--format=%an%n%cn")
+	if err != nil {
+		return err
+	}
+	for _, name := range strings.Split(names, "\n") {
+		a.pseudonym(name)
+	}
+	return nil
+}
+
+// replaces every known name appearing as a whole word in text with its pseudonym. words are told apart with
+// unicode letters and digits, \b only knows ASCII and would miss names like "Jérôme" or "Łukasz"
+func (a *authorAnonymizer) anonymize(text string) string {
+	if len(a.pseudonyms) == 0 {
+		return text
+	}
+
+	if a.matcher == nil {
+		a.names = make([]string, 0, len(a.pseudonyms))
+		quoted := make([]string, 0, len(a.pseudonyms))
+		for name := range a.pseudonyms {
+			a.names = append(a.names, name)
+		}
+		// longest first, so "Jane Doe" wins over "Jane"
+		sort.Slice(a.names, func(i, j int) bool { return len(a.names[i]) > len(a.names[j]) })
+		for _, name := range a.names {
+			quoted = append(quoted, regexp.QuoteMeta(name))
+		}
+		a.matcher = regexp.MustCompile(strings.Join(quoted, "|"))
+	}
+
+	var builder strings.Builder
+	written, searchFrom := 0, 0
+	for searchFrom < len(text) {
+		loc := a.matcher.FindStringIndex(text[searchFrom:])
+		if loc == nil {
+			break
+		}
+		start := searchFrom + loc[0]
+		if name := a.wholeWordAt(text, start); name != "" {
+			builder.WriteString(text[written:start])
+			builder.WriteString(a.pseudonyms[name])
+			written, searchFrom = start+len(name), start+len(name)
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		searchFrom = start + size
+	}
+	builder.WriteString(text[written:])
+	return builder.String()
+}
+
+// the longest known name starting at start that isn't part of a bigger word, empty if there's none
+func (a *authorAnonymizer) wholeWordAt(text string, start int) string {
+	if before, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && isWordRune(before) {
+		return ""
+	}
+	for _, name := range a.names {
+		if !strings.HasPrefix(text[start:], name) {
+			continue
+		}
+		if after, _ := utf8.DecodeRuneInString(text[start+len(name):]); start+len(name) < len(text) && isWordRune(after) {
+			continue
+		}
+		return name
+	}
+	return ""
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
@@ -0,0 +1,90 @@
+package xplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testAnonymizerKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestAuthorAnonymizer(t *testing.T) {
+	anonymizer := newAuthorAnonymizer(testAnonymizerKey)
+
+	janeDoe := anonymizer.pseudonym("Jane Doe")
+	jdoe := anonymizer.pseudonym("jdoe")
+	assert.Regexp(t, `^Author [0-9a-f]{6}$`, janeDoe)
+	assert.NotEqual(t, janeDoe, jdoe)
+	assert.Equal(t, janeDoe, anonymizer.pseudonym("Jane Doe"), "pseudonyms are stable within a run")
+	assert.Equal(t, "", anonymizer.pseudonym(""))
+
+	jane := anonymizer.pseudonym("Jane")
+	text := "main.go: Jane Doe (12), Jane (3), jdoe (1)\nJanet and jdoes are other people, Jane Doex too"
+	expected := "main.go: " + janeDoe + " (12), " + jane + " (3), " + jdoe + " (1)\nJanet and jdoes are other people, " + jane + " Doex too"
+	assert.Equal(t, expected, anonymizer.anonymize(text))
+}
+
+func TestAuthorAnonymizerStableAcrossRuns(t *testing.T) {
+	first := newAuthorAnonymizer(testAnonymizerKey)
+	first.pseudonym("Ada")
+	first.pseudonym("Linus")
+
+	// a new contributor showing up first doesn't shift the others
+	second := newAuthorAnonymizer(testAnonymizerKey)
+	second.pseudonym("Grace")
+	second.pseudonym("Linus")
+	second.pseudonym("Ada")
+
+	assert.Equal(t, first.pseudonym("Ada"), second.pseudonym("Ada"))
+	assert.Equal(t, first.pseudonym("Linus"), second.pseudonym("Linus"))
+}
+
+func TestAuthorAnonymizerNonASCIINames(t *testing.T) {
+	anonymizer := newAuthorAnonymizer(testAnonymizerKey)
+	jerome := anonymizer.pseudonym("Jérôme")
+	lukasz := anonymizer.pseudonym("Łukasz")
+	anonymizer.pseudonym("Ana")
+
+	text := "blame: Jérôme (4), Łukasz (2)\nJérômes, Łukaszów and Anaïs are other people"
+	expected := "blame: " + jerome + " (4), " + lukasz + " (2)\nJérômes, Łukaszów and Anaïs are other people"
+	assert.Equal(t, expected, anonymizer.anonymize(text))
+}
+
+func TestAuthorAnonymizerLearnRepoAuthors(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+	anonymizer := newAuthorAnonymizer(testAnonymizerKey)
+
+	assert.NoError(t, anonymizer.learnRepoAuthors(root))
+	assert.Equal(t, "blame: "+anonymizer.pseudonym("xplane")+" (1)", anonymizer.anonymize("blame: xplane (1)"))
+}
+
+func TestAuthorAnonymizerKeyed(t *testing.T) {
+	otherKey := []byte("fedcba9876543210fedcba9876543210")
+	assert.NotEqual(t, newAuthorAnonymizer(testAnonymizerKey).pseudonym("Ada"), newAuthorAnonymizer(otherKey).pseudonym("Ada"),
+		"a pseudonym can't be recomputed from the name alone")
+}
+
+func TestLoadAnonymizerKey(t *testing.T) {
+	root := t.TempDir()
+
+	ephemeral, err := loadAnonymizerKey(root, false)
+	assert.NoError(t, err)
+	assert.Len(t, ephemeral, anonymizerKeySize)
+	assert.NoDirExists(t, filepath.Join(root, contextDir), "not saved without persist")
+
+	key, err := loadAnonymizerKey(root, true)
+	assert.NoError(t, err)
+	info, err := os.Stat(filepath.Join(root, contextDir, anonymizerKeyFile))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	again, err := loadAnonymizerKey(root, false)
+	assert.NoError(t, err)
+	assert.Equal(t, key, again, "the saved key is reused")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(root, contextDir, anonymizerKeyFile), []byte("not hex"), 0o600))
+	_, err = loadAnonymizerKey(root, true)
+	assert.ErrorContains(t, err, "corrupted")
+}
@@ -0,0 +1,73 @@
+package xplane
+
+import (
+	"os"
+	"strings"
+	"unicode"
+)
+
+// the banner for terminals that can't draw box characters
+const asciiHeader = `
+__  ______  _        _    _   _ _____
+\ \/ /  _ \| |      / \  | \ | | ____|
+ \  /| |_) | |     / _ \ |  \| |  _|
+ /  \|  __/| |___ / ___ \| |\  | |___
+/_/\_\_|   |_____/_/   \_\_| \_|_____|
+`
+
+var asciiSpinnerFrames = []string{"|", "/", "-", "\\"}
+
+// emojis that carry meaning get a plain stand-in, nerd font icons become '*' and anything else is dropped
+var asciiEmojis = strings.NewReplacer("✈️", ">>", "⚠️", "!!", "✅", "OK")
+
+func toASCII(s string) string {
+	s = asciiEmojis.Replace(s)
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r <= unicode.MaxASCII:
+			return r
+		case r >= '\ue000' && r <= '\uf8ff': // the private use area, where nerd fonts put their icons
+			return '*'
+		default:
+			return -1
+		}
+	}, s)
+}
+
+// whether the terminal is unlikely to show glyphs: XPLANE_ASCII_ONLY wins, 'true' or 'false',
+// otherwise a dumb terminal or a locale that isn't UTF-8. an unset locale isn't taken as a hint, too many setups have none
+func detectASCIIOnly(setting string) bool {
+	switch setting {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return true
+	}
+	for _, variable := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := os.Getenv(variable); locale != "" {
+			normalized := strings.ToLower(strings.ReplaceAll(locale, "-", ""))
+			return !strings.Contains(normalized, "utf8")
+		}
+	}
+	return false
+}
+
+// a message of messages.go as it should be printed, its glyphs swapped for plain ASCII with XPLANE_ASCII_ONLY.
+// decided per config at print time, so embedders with different configs don't step on each other
+func (c *Config) msg(message string) string {
+	if c.ASCIIOnly {
+		return toASCII(message)
+	}
+	return message
+}
+
+// the banner shown above summaries
+func banner(ascii bool) string {
+	if ascii {
+		return asciiHeader
+	}
+	return xplaneHeader
+}
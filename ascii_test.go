@@ -0,0 +1,100 @@
+package xplane
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectASCIIOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		setting  string
+		term     string
+		lcAll    string
+		lang     string
+		expected bool
+	}{
+		{"forced on", "true", "xterm-256color", "", "en_US.UTF-8", true},
+		{"forced off on a dumb terminal", "false", "dumb", "", "C", false},
+		{"utf-8 locale", "", "xterm-256color", "", "en_US.UTF-8", false},
+		{"utf8 spelling", "", "xterm-256color", "", "de_DE.utf8", false},
+		{"c locale", "", "xterm-256color", "", "C", true},
+		{"lc_all wins over lang", "", "xterm-256color", "POSIX", "en_US.UTF-8", true},
+		{"dumb terminal", "", "dumb", "", "en_US.UTF-8", true},
+		{"no locale at all", "", "xterm-256color", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TERM", tt.term)
+			t.Setenv("LC_ALL", tt.lcAll)
+			t.Setenv("LC_CTYPE", "")
+			t.Setenv("LANG", tt.lang)
+			assert.Equal(t, tt.expected, detectASCIIOnly(tt.setting))
+		})
+	}
+}
+
+// every message declared in messages.go, by name
+func declaredMessages(t *testing.T) map[string]string {
+	file, err := parser.ParseFile(token.NewFileSet(), "messages.go", nil, 0)
+	require.NoError(t, err)
+
+	messages := map[string]string{}
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			for i, name := range valueSpec.Names {
+				literal, ok := valueSpec.Values[i].(*ast.BasicLit)
+				if !ok || !strings.HasPrefix(name.Name, "Msg") {
+					continue
+				}
+				value, err := strconv.Unquote(literal.Value)
+				require.NoError(t, err)
+				messages[name.Name] = value
+			}
+		}
+	}
+	return messages
+}
+
+func TestToASCII(t *testing.T) {
+	assert.Equal(t, ">>  xplane: Gathering project context...", toASCII(MsgFetchingContext))
+	assert.Equal(t, "OK xplane: No new updates.", toASCII(MsgNoNewUpdates))
+	assert.Equal(t, "    - *     Fetching info from GitHub: %s", toASCII(MsgFetchingGithubRemoteInfo))
+
+	messages := declaredMessages(t)
+	assert.NotEmpty(t, messages)
+	for name, msg := range messages {
+		converted := toASCII(msg)
+		for _, r := range converted {
+			assert.LessOrEqual(t, r, rune(unicode.MaxASCII), "non-ASCII rune left in %s", name)
+		}
+		// the format verbs must survive, or the Printf calls would break
+		assert.Equal(t, strings.Count(msg, "%"), strings.Count(converted, "%"), name)
+	}
+}
+
+func TestConfigMsg(t *testing.T) {
+	plain, glyphs := &Config{ASCIIOnly: true}, &Config{}
+
+	assert.Equal(t, toASCII(MsgNoNewUpdates), plain.msg(MsgNoNewUpdates))
+	// one config asking for ASCII leaves the others, and the constants, alone
+	assert.Equal(t, MsgNoNewUpdates, glyphs.msg(MsgNoNewUpdates))
+	assert.Equal(t, asciiHeader, banner(plain.ASCIIOnly))
+	assert.Equal(t, xplaneHeader, banner(glyphs.ASCIIOnly))
+	assert.Equal(t, glamourRenderer{ascii: true}, plain.summaryRenderer())
+	assert.Equal(t, glamourRenderer{}, glyphs.summaryRenderer())
+}
@@ -0,0 +1,118 @@
+package xplane
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// CommandAudit is how one configured command did in a dry run, see AuditCommands
+type CommandAudit struct {
+	Command  string
+	Status   string // "ok", "failed" or "skipped"
+	Bytes    int
+	Duration time.Duration
+	Detail   string // the error for failed commands, the reason for skipped ones
+}
+
+// AuditCommands runs every command of XPLANE_COMMANDS and every XPLANE_INCLUDE_FILES entry once and reports how
+// each did. unlike a normal run a failing command doesn't stop the others, the cache is neither read nor
+// written and nothing is sent to the llm or saved to .xplane: coverage reports against the stored totals
+// without updating them, git_diff isn't summarized per file and build_size is skipped as it runs the build
+func AuditCommands(cfg *Config) ([]CommandAudit, error) {
+	gitRoot, err := findGitRoot()
+	if err != nil {
+		return nil, fmt.Errorf("not inside a git repository: %w", err)
+	}
+	return auditCommands(cfg, gitRoot), nil
+}
+
+func auditCommands(cfg *Config, gitRoot string) []CommandAudit {
+	gatherer := NewContextGatherer(gitRoot, cfg)
+	initErr := gatherer.initProvider()
+	handlers := commandHandlers(cfg, gitRoot, gatherer, true)
+
+	audits := make([]CommandAudit, 0, len(cfg.Commands)+len(cfg.IncludeFiles))
+	for _, command := range cfg.Commands {
+		trimmedCmd := strings.TrimSpace(command)
+		if gitProviderCommands[trimmedCmd] {
+			if initErr != nil {
+				audits = append(audits, CommandAudit{
+					Command: trimmedCmd, Status: "skipped",
+					Detail: fmt.Sprintf("could not initialize git provider (%s)", redactError(initErr)),
+				})
+				continue
+			}
+			if providerName := gatherer.gitProvider.GetProviderName(); skipForProvider(providerName, trimmedCmd) {
+				audits = append(audits, CommandAudit{
+					Command: trimmedCmd, Status: "skipped",
+					Detail: fmt.Sprintf("not available on %s", providerName),
+				})
+				continue
+			}
+		}
+
+		startedAt := time.Now()
+		output, err := runContextCommand(cfg, gitRoot, trimmedCmd, handlers)
+		audits = append(audits, newCommandAudit(trimmedCmd, output, time.Since(startedAt), err))
+	}
+
+	for _, path := range cfg.IncludeFiles {
+		startedAt := time.Now()
+		output, err := readIncludedFile(gitRoot, path)
+		audits = append(audits, newCommandAudit("file:"+path, output, time.Since(startedAt), err))
+	}
+	return audits
+}
+
+func newCommandAudit(command, output string, duration time.Duration, err error) CommandAudit {
+	audit := CommandAudit{Command: command, Status: "ok", Bytes: len(output), Duration: duration}
+	if errors.Is(err, errOmitBlock) {
+		audit.Status = "skipped"
+		audit.Detail = "nothing to report, left out of the context"
+	} else if errors.Is(err, errSkippedInDryRun) {
+		audit.Status = "skipped"
+		audit.Detail = err.Error()
+	} else if err != nil {
+		audit.Status = "failed"
+		audit.Bytes = 0
+		audit.Detail = redactError(err)
+	}
+	return audit
+}
+
+// a table with a row per command, the errors and skip reasons are listed under it so the columns stay narrow
+func formatAudit(audits []CommandAudit) string {
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "COMMAND\tSTATUS\tBYTES\tTIME")
+	totalBytes := 0
+	var notes []string
+	for _, audit := range audits {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\n", audit.Command, audit.Status, audit.Bytes, audit.Duration.Round(time.Millisecond))
+		totalBytes += audit.Bytes
+		if audit.Detail != "" {
+			notes = append(notes, fmt.Sprintf("- %s: %s", audit.Command, audit.Detail))
+		}
+	}
+	writer.Flush()
+
+	fmt.Fprintf(&builder, "\nTotal context size: %d bytes\n", totalBytes)
+	if len(notes) > 0 {
+		fmt.Fprintf(&builder, "\n%s\n", strings.Join(notes, "\n"))
+	}
+	return builder.String()
+}
+
+// RunAudit is the CLI side of AuditCommands, it prints the table
+func RunAudit(cfg *Config) error {
+	fmt.Print(cfg.msg(MsgAuditingCommands))
+	audits, err := AuditCommands(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Print(formatAudit(audits))
+	return nil
+}
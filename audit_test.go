@@ -0,0 +1,94 @@
+package xplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditCommands(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"README.md": "# Project\n"})
+	cfg := &Config{
+		Commands:       []string{"readme", "broken", "quiet"},
+		CommandAliases: map[string]string{"broken": "false", "quiet": "true"},
+		IncludeFiles:   []string{"README.md"},
+	}
+
+	audits := auditCommands(cfg, root)
+	assert.Len(t, audits, 4)
+
+	assert.Equal(t, "readme", audits[0].Command)
+	assert.Equal(t, "ok", audits[0].Status)
+	assert.Positive(t, audits[0].Bytes)
+
+	// a failure doesn't stop the commands after it
+	assert.Equal(t, "failed", audits[1].Status)
+	assert.NotEmpty(t, audits[1].Detail)
+	assert.Equal(t, "ok", audits[2].Status)
+	assert.Equal(t, 0, audits[2].Bytes)
+
+	assert.Equal(t, "file:README.md", audits[3].Command)
+	assert.Equal(t, len("# Project\n"), audits[3].Bytes)
+
+	_, err := os.Stat(filepath.Join(root, ".xplane"))
+	assert.True(t, os.IsNotExist(err), "the audit shouldn't write anything to .xplane")
+}
+
+func TestAuditLeavesStateUnchanged(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "main.go"), []byte("package app\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "coverage.out"), []byte("mode: set\na.go:1.1,2.2 3 1\na.go:3.1,4.2 1 0\n"), 0o644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, contextDir), 0o755))
+	coverageCache := filepath.Join(root, contextDir, coverageCacheFile)
+	assert.NoError(t, os.WriteFile(coverageCache, []byte("60.0\n\n"), 0o644))
+	cfg := &Config{
+		Commands:           []string{"coverage", "build_size", "git_diff"},
+		BuildCmd:           "touch built",
+		BuildArtifact:      "built",
+		PerFileDiffSummary: true,
+		DiffBudget:         1, // would summarize with the llm, no provider is configured
+	}
+
+	audits := auditCommands(cfg, root)
+
+	assert.Equal(t, "ok", audits[0].Status)
+	assert.Equal(t, "skipped", audits[1].Status)
+	assert.Contains(t, audits[1].Detail, "XPLANE_BUILD_CMD")
+	assert.NoFileExists(t, filepath.Join(root, "built"), "the build isn't run")
+	assert.Equal(t, "ok", audits[2].Status)
+	entries, err := os.ReadDir(filepath.Join(root, contextDir))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "no cache written")
+	cached, err := os.ReadFile(coverageCache)
+	assert.NoError(t, err)
+	assert.Equal(t, "60.0\n\n", string(cached))
+}
+
+func TestFormatAudit(t *testing.T) {
+	audits := []CommandAudit{
+		{Command: "git_status", Status: "ok", Bytes: 120, Duration: 15 * time.Millisecond},
+		{Command: "tokei", Status: "failed", Detail: "executable file not found in $PATH"},
+		{Command: "github_prs", Status: "skipped", Detail: "could not initialize git provider (no remote)"},
+	}
+
+	expected := `COMMAND     STATUS   BYTES  TIME
+git_status  ok       120    15ms
+tokei       failed   0      0s
+github_prs  skipped  0      0s
+
+Total context size: 120 bytes
+
+- tokei: executable file not found in $PATH
+- github_prs: could not initialize git provider (no remote)
+`
+	assert.Equal(t, expected, formatAudit(audits))
+}
+
+func TestNewCommandAuditOmitted(t *testing.T) {
+	audit := newCommandAudit("git_branch_status", "", time.Millisecond, errOmitBlock)
+	assert.Equal(t, "skipped", audit.Status)
+	assert.Equal(t, "nothing to report, left out of the context", audit.Detail)
+}
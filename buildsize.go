@@ -0,0 +1,85 @@
+package xplane
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	buildSizeCacheFile = "build_size_cache.txt"
+	buildFailureLines  = 20 // the tail of a failed build's output kept in the context
+)
+
+// the size in bytes of a file, or of all the files under a directory
+func artifactSize(path string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
+
+func formatByteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exponent := float64(size)/unit, 0
+	for value >= unit && exponent < 3 {
+		value /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[exponent])
+}
+
+// runs XPLANE_BUILD_CMD and reports the size of XPLANE_BUILD_ARTIFACT, with the delta from the previous size.
+// a failing build is reported as such instead of failing the run, it's worth summarizing too
+func getBuildSize(gitRoot, buildCmd, artifact string) (string, error) {
+	if buildCmd == "" || artifact == "" {
+		return "build_size needs XPLANE_BUILD_CMD and XPLANE_BUILD_ARTIFACT to be set.", nil
+	}
+
+	fields, err := splitCommandLine(buildCmd)
+	if err != nil {
+		return "", fmt.Errorf("invalid XPLANE_BUILD_CMD: %w", err)
+	}
+	if _, err := runCommand(gitRoot, fields[0], fields[1:]...); err != nil {
+		lines := strings.Split(strings.TrimSpace(err.Error()), "\n")
+		if len(lines) > buildFailureLines {
+			lines = lines[len(lines)-buildFailureLines:]
+		}
+		return fmt.Sprintf("Build failing: '%s' did not succeed, the last lines of its output:\n%s", buildCmd, strings.Join(lines, "\n")), nil
+	}
+
+	size, err := artifactSize(filepath.Join(gitRoot, artifact))
+	if err != nil {
+		return fmt.Sprintf("The build succeeded but its artifact '%s' could not be measured: %v", artifact, err), nil
+	}
+	current := strconv.FormatInt(size, 10)
+
+	previous, err := trackValue(gitRoot, buildSizeCacheFile, current, true)
+	if err != nil {
+		return "", fmt.Errorf("could not cache the build size: %w", err)
+	}
+
+	output := fmt.Sprintf("Size of '%s': %s (%d bytes)", artifact, formatByteSize(size), size)
+	if previousSize, err := strconv.ParseInt(previous, 10, 64); err == nil && previousSize > 0 {
+		delta := size - previousSize
+		output += fmt.Sprintf(" (previously %s, delta %+d bytes, %+.1f%%)", formatByteSize(previousSize), delta, float64(delta)/float64(previousSize)*100)
+	}
+	return output, nil
+}
@@ -0,0 +1,52 @@
+package xplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatByteSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatByteSize(512))
+	assert.Equal(t, "1.5 KiB", formatByteSize(1536))
+	assert.Equal(t, "12.0 MiB", formatByteSize(12*1024*1024))
+}
+
+func TestGetBuildSize(t *testing.T) {
+	root := t.TempDir()
+
+	output, err := getBuildSize(root, "", "bin/app")
+	assert.NoError(t, err)
+	assert.Equal(t, "build_size needs XPLANE_BUILD_CMD and XPLANE_BUILD_ARTIFACT to be set.", output)
+
+	build := func(size int) {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, "bin"), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(root, "bin", "app"), make([]byte, size), 0o644))
+	}
+
+	build(1000)
+	output, err = getBuildSize(root, "true", "bin/app")
+	assert.NoError(t, err)
+	assert.Equal(t, "Size of 'bin/app': 1000 B (1000 bytes)", output)
+
+	build(1500)
+	output, err = getBuildSize(root, "true", "bin/app")
+	assert.NoError(t, err)
+	assert.Equal(t, "Size of 'bin/app': 1.5 KiB (1500 bytes) (previously 1000 B, delta +500 bytes, +50.0%)", output)
+
+	// a directory artifact is measured as a whole
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "bin", "lib.so"), make([]byte, 500), 0o644))
+	output, err = getBuildSize(root, "true", "bin")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Size of 'bin': 2.0 KiB (2000 bytes)")
+
+	output, err = getBuildSize(root, `sh -c 'test -f "bin/app"'`, "bin/app")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Size of 'bin/app': 1.5 KiB (1500 bytes)", "quoted arguments reach the build as one")
+
+	output, err = getBuildSize(root, "false", "bin/app")
+	assert.NoError(t, err, "a failing build is context, not an error")
+	assert.Contains(t, output, "Build failing: 'false' did not succeed")
+}
@@ -0,0 +1,142 @@
+package xplane
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	cacheDir              = "cache"
+	defaultRemoteCacheTTL = 5 * time.Minute
+)
+
+// remote commands whose result depends on what's checked out, cached per branch and HEAD so a checkout or
+// a new commit doesn't keep serving the previous result until the ttl runs out
+var headScopedCommands = map[string]bool{
+	"git_branch_status": true,
+	"merge_status":      true,
+	"pr_overlap":        true,
+	"shipped_issues":    true,
+}
+
+// the cache key of a remote command, false when HEAD can't be resolved for one that depends on it. the settings
+// shaping its output are part of the key, so changing one doesn't keep serving what the previous value printed
+func remoteCacheKey(cfg *Config, gitRoot, command string) (string, bool) {
+	scope := remoteOutputSettings(cfg)
+	if headScopedCommands[command] {
+		// the sha, then the branch name: --abbrev-ref only applies to the arguments after it
+		head, err := runCommand(gitRoot, "git", "rev-parse", "HEAD", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return "", false
+		}
+		scope += "\n" + head
+	}
+	hash := sha256.Sum256([]byte(scope))
+	return command + "_" + hex.EncodeToString(hash[:8]), true
+}
+
+// the settings that change what the remote commands print, one per line
+func remoteOutputSettings(cfg *Config) string {
+	templateSource := func(tmpl *template.Template) string {
+		if tmpl == nil || tmpl.Tree == nil {
+			return ""
+		}
+		return tmpl.Root.String()
+	}
+	settings := []string{
+		fmt.Sprintf("anonymize_authors=%t", cfg.AnonymizeAuthors),
+		fmt.Sprintf("pr_ci_status=%t", cfg.PRCIStatus),
+		fmt.Sprintf("pr_threads=%t", cfg.PRThreads),
+		fmt.Sprintf("group_prs_by_label=%t", cfg.GroupPRsByLabel),
+		fmt.Sprintf("group_prs_by_branch=%t", cfg.GroupPRsByBranch),
+		fmt.Sprintf("prs_by_author=%t", cfg.PRsByAuthor),
+		fmt.Sprintf("pr_template=%q", templateSource(cfg.PRTemplate)),
+		fmt.Sprintf("release_template=%q", templateSource(cfg.ReleaseTemplate)),
+		fmt.Sprintf("stale_pr_days=%d", cfg.StalePRDays),
+		fmt.Sprintf("stale_branch_days=%d", cfg.staleBranchDays()),
+		fmt.Sprintf("compare_branch=%q", cfg.CompareBranch),
+		fmt.Sprintf("omit_identical_branch=%t", cfg.OmitIdenticalBranch),
+	}
+	return strings.Join(settings, "\n")
+}
+
+// where the author names mentioned by a cached output are kept, so that a cache hit is anonymized like a fresh run
+func cachedAuthorsKey(key string) string {
+	return key + ".authors"
+}
+
+func cachedOutputPath(gitRoot, key string) string {
+	return filepath.Join(gitRoot, contextDir, cacheDir, key+".txt")
+}
+
+// returns the cached output for key if it was written less than ttl ago
+func readCachedOutput(gitRoot, key string, ttl time.Duration) (string, bool) {
+	cachePath := cachedOutputPath(gitRoot, key)
+	info, err := os.Stat(cachePath)
+	if err != nil || time.Since(info.ModTime()) > ttl {
+		return "", false
+	}
+
+	content, err := os.ReadFile(cachePath)
+	if err != nil {
+		return "", false
+	}
+	return string(content), true
+}
+
+func writeCachedOutput(gitRoot, key, output string) error {
+	cachePath := cachedOutputPath(gitRoot, key)
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(cachePath, []byte(output), 0o644)
+}
+
+// drops the entries starting with prefix that are older than maxAge, for keys that would otherwise pile up
+func pruneCachedOutputs(gitRoot, prefix string, maxAge time.Duration) {
+	entries, err := os.ReadDir(filepath.Join(gitRoot, contextDir, cacheDir))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > maxAge {
+			os.Remove(filepath.Join(gitRoot, contextDir, cacheDir, entry.Name()))
+		}
+	}
+}
+
+// a value tracked across runs, like the coverage total or the build size. its file holds the latest value and
+// the one before it changed, so the reported delta stays stable across runs that don't change it. returns the
+// value current is to be compared against, empty on the first run, and with record stores current if it changed
+func trackValue(gitRoot, cacheFile, current string, record bool) (string, error) {
+	cachePath := filepath.Join(gitRoot, contextDir, cacheFile)
+	var cachedCurrent, cachedPrevious string
+	if content, err := os.ReadFile(cachePath); err == nil {
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		cachedCurrent = lines[0]
+		if len(lines) > 1 {
+			cachedPrevious = lines[1]
+		}
+	}
+	if current == cachedCurrent {
+		return cachedPrevious, nil
+	}
+	if record {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(cachePath, []byte(current+"\n"+cachedCurrent+"\n"), 0o644); err != nil {
+			return "", err
+		}
+	}
+	return cachedCurrent, nil
+}
@@ -0,0 +1,109 @@
+package xplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedOutput(t *testing.T) {
+	root := t.TempDir()
+
+	_, ok := readCachedOutput(root, "github_prs", time.Minute)
+	assert.False(t, ok, "nothing cached yet")
+
+	assert.NoError(t, writeCachedOutput(root, "github_prs", "- PR (by jane)"))
+	output, ok := readCachedOutput(root, "github_prs", time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, "- PR (by jane)", output)
+
+	stale := time.Now().Add(-2 * time.Minute)
+	assert.NoError(t, os.Chtimes(cachedOutputPath(root, "github_prs"), stale, stale))
+	_, ok = readCachedOutput(root, "github_prs", time.Minute)
+	assert.False(t, ok, "expired entries are ignored")
+}
+
+func TestRemoteCacheKey(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+	cfg := &Config{}
+
+	key, ok := remoteCacheKey(cfg, root, "github_prs")
+	assert.True(t, ok)
+	assert.Regexp(t, `^github_prs_[0-9a-f]{16}$`, key)
+
+	onMain, ok := remoteCacheKey(cfg, root, "git_branch_status")
+	assert.True(t, ok)
+	_, err := runCommand(root, "git", "checkout", "-q", "-b", "feature")
+	assert.NoError(t, err)
+	onFeature, ok := remoteCacheKey(cfg, root, "git_branch_status")
+	assert.True(t, ok)
+	assert.NotEqual(t, onMain, onFeature, "a checkout of the same commit still changes the key")
+	_, err = runCommand(root, "git", "-c", "user.name=xplane", "-c", "user.email=xplane@example.com", "commit", "-q", "--allow-empty", "-m", "next")
+	assert.NoError(t, err)
+	afterCommit, _ := remoteCacheKey(cfg, root, "git_branch_status")
+	assert.NotEqual(t, onFeature, afterCommit)
+
+	onFeatureBranch, _ := remoteCacheKey(cfg, root, "github_prs")
+	assert.Equal(t, key, onFeatureBranch, "not tied to the checked out branch")
+
+	_, ok = remoteCacheKey(cfg, t.TempDir(), "merge_status")
+	assert.False(t, ok, "not cached outside a repository")
+}
+
+func TestRemoteCacheKeyOutputSettings(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+	prTemplate, err := parseEntityTemplate("XPLANE_PR_TEMPLATE", "{{.Title}}")
+	assert.NoError(t, err)
+
+	base, _ := remoteCacheKey(&Config{}, root, "github_prs")
+	for name, cfg := range map[string]*Config{
+		"anonymized":     {AnonymizeAuthors: true},
+		"ci status":      {PRCIStatus: true},
+		"threads":        {PRThreads: true},
+		"grouped":        {GroupPRsByLabel: true},
+		"template":       {PRTemplate: prTemplate},
+		"stale days":     {StalePRDays: 7},
+		"compare branch": {CompareBranch: "develop"},
+	} {
+		key, ok := remoteCacheKey(cfg, root, "github_prs")
+		assert.True(t, ok, name)
+		assert.NotEqual(t, base, key, name)
+	}
+	same, _ := remoteCacheKey(&Config{Language: "French"}, root, "github_prs")
+	assert.Equal(t, base, same, "settings that don't change the output keep the key")
+}
+
+func TestPruneCachedOutputs(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, writeCachedOutput(root, "merge_status_old", "old"))
+	assert.NoError(t, writeCachedOutput(root, "merge_status_new", "new"))
+	assert.NoError(t, writeCachedOutput(root, "github_prs", "prs"))
+	stale := time.Now().Add(-time.Hour)
+	for _, key := range []string{"merge_status_old", "github_prs"} {
+		assert.NoError(t, os.Chtimes(cachedOutputPath(root, key), stale, stale))
+	}
+
+	pruneCachedOutputs(root, "merge_status_", time.Minute)
+
+	assert.NoFileExists(t, cachedOutputPath(root, "merge_status_old"))
+	assert.FileExists(t, cachedOutputPath(root, "merge_status_new"))
+	assert.FileExists(t, cachedOutputPath(root, "github_prs"), "other prefixes are left alone")
+}
+
+func TestTrackValue(t *testing.T) {
+	root := t.TempDir()
+
+	previous, err := trackValue(root, "tracked.txt", "10", false)
+	assert.NoError(t, err)
+	assert.Empty(t, previous)
+	assert.NoFileExists(t, filepath.Join(root, contextDir, "tracked.txt"), "nothing is stored without record")
+
+	for _, step := range []struct{ current, previous string }{{"10", ""}, {"12", "10"}, {"12", "10"}, {"15", "12"}} {
+		previous, err = trackValue(root, "tracked.txt", step.current, true)
+		assert.NoError(t, err)
+		assert.Equal(t, step.previous, previous, "after %s", step.current)
+	}
+}
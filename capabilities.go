@@ -0,0 +1,40 @@
+package xplane
+
+import (
+	"maps"
+	"slices"
+)
+
+// Capabilities describes what this build of xplane supports, so editor plugins and wrappers don't have to hardcode it
+type Capabilities struct {
+	Providers      []string            `json:"providers"`
+	Commands       []CommandCapability `json:"commands"`
+	OutputFormats  []string            `json:"output_formats"`
+	ContextFormats []string            `json:"context_formats"`
+	EnvVars        []string            `json:"env_vars"`
+}
+
+type CommandCapability struct {
+	Name             string `json:"name"`
+	RequiredBinary   string `json:"required_binary,omitempty"` // empty when the command only reads files or calls an api
+	NeedsGitProvider bool   `json:"needs_git_provider"`        // needs a GitHub/GitLab remote and token
+}
+
+// GetCapabilities lists the supported providers, built-in commands, formats and environment variables
+func GetCapabilities() Capabilities {
+	var commands []CommandCapability
+	for _, name := range slices.Sorted(maps.Keys(specialCommandToBinMap)) {
+		commands = append(commands, CommandCapability{
+			Name:             name,
+			RequiredBinary:   specialCommandToBinMap[name],
+			NeedsGitProvider: gitProviderCommands[name],
+		})
+	}
+	return Capabilities{
+		Providers:      slices.Clone(supportedProviders),
+		Commands:       commands,
+		OutputFormats:  slices.Sorted(maps.Keys(summaryRenderers)),
+		ContextFormats: slices.Sorted(maps.Keys(contextFormats)),
+		EnvVars:        slices.Clone(recognizedEnvVars),
+	}
+}
@@ -0,0 +1,23 @@
+package xplane
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCapabilities(t *testing.T) {
+	capabilities := GetCapabilities()
+
+	assert.Equal(t, []string{"claude_code", "gemini_cli", "gemini", "ollama"}, capabilities.Providers)
+	assert.Len(t, capabilities.Commands, len(specialCommandToBinMap))
+	assert.Contains(t, capabilities.Commands, CommandCapability{Name: "tokei", RequiredBinary: "tokei"})
+	assert.Contains(t, capabilities.Commands, CommandCapability{Name: "github_prs", NeedsGitProvider: true})
+	assert.Equal(t, []string{"glamour", "html", "json", "plain"}, capabilities.OutputFormats)
+	assert.Contains(t, capabilities.EnvVars, "XPLANE_PROVIDER")
+
+	encoded, err := json.Marshal(capabilities)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `{"name":"git_diff","required_binary":"git","needs_git_provider":false}`)
+}
@@ -0,0 +1,38 @@
+package xplane
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// clipboard utilities tried in order, the first one found on PATH wins
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip.exe"}, // windows, and WSL
+}
+
+var errNoClipboard = errors.New("no clipboard utility found, install one of pbcopy, wl-copy, xclip or xsel")
+
+// pipes text into the system clipboard through whichever utility is available
+func copyToClipboard(text string) error {
+	for _, command := range clipboardCommands {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("'%s' failed: %v, stderr: %s", command[0], err, stderr.String())
+		}
+		return nil
+	}
+	return errNoClipboard
+}
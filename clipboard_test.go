@@ -0,0 +1,33 @@
+package xplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyToClipboard(t *testing.T) {
+	t.Run("no clipboard utility", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		assert.ErrorIs(t, copyToClipboard("summary"), errNoClipboard)
+	})
+
+	t.Run("first available utility", func(t *testing.T) {
+		bin := t.TempDir()
+		copied := filepath.Join(bin, "copied.txt")
+		script := "#!/bin/sh\necho \"$@\" > " + copied + ".args\nexec /bin/cat > " + copied + "\n"
+		assert.NoError(t, os.WriteFile(filepath.Join(bin, "xclip"), []byte(script), 0o755))
+		t.Setenv("PATH", bin)
+
+		assert.NoError(t, copyToClipboard("# Summary\n"))
+
+		content, err := os.ReadFile(copied)
+		assert.NoError(t, err)
+		assert.Equal(t, "# Summary\n", string(content))
+		args, err := os.ReadFile(copied + ".args")
+		assert.NoError(t, err)
+		assert.Equal(t, "-selection clipboard\n", string(args))
+	})
+}
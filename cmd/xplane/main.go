@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Gdetrane/xplane"
+)
+
+const usage = `usage:
+  xplane [--force] [--refresh] [--post-comment] [--copy] [--show-diff] [--explain] [--set key=value ...]
+  xplane --compare <from> <to>
+  xplane pr <number>
+  xplane audit
+  xplane init
+  xplane snapshot save <name>
+  xplane snapshot list
+  xplane capabilities [--json]
+
+flags:
+`
+
+func main() {
+	force := flag.Bool("force", false, "generate a summary even when the context hasn't changed")
+	postComment := flag.Bool("post-comment", false, "post the summary as a comment on the current branch's pull/merge request")
+	copySummary := flag.Bool("copy", false, "copy the generated summary to the system clipboard")
+	showDiff := flag.Bool("show-diff", false, "print the uncommitted diff with syntax highlighting after the summary")
+	explain := flag.Bool("explain", false, "describe what each configured command gathers and needs before running them")
+	refresh := flag.Bool("refresh", false, "ignore cached results of remote commands and fetch them again")
+	compare := flag.String("compare", "", "summarize the changes between two saved snapshots, e.g. '--compare v1 v2'")
+	var overrides overrideFlags
+	flag.Var(&overrides, "set", "override a setting, repeatable, e.g. '--set provider=ollama --set model=llama3' (keys are the env vars without XPLANE_, lowercased)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// the flag package stops at the first positional argument, which is the second snapshot name of
+	// '--compare v1 v2'. flags after it are parsed too, instead of being silently ignored
+	var compareTo string
+	if *compare != "" {
+		if flag.NArg() > 0 {
+			compareTo = flag.Arg(0)
+			flag.CommandLine.Parse(flag.Args()[1:])
+		}
+		if compareTo == "" || flag.NArg() != 0 {
+			log.Fatalf("xplane: --compare needs two snapshot names, e.g. 'xplane --compare v1 v2'")
+		}
+	}
+
+	// introspection only, it must work without a git repo or a valid config
+	if flag.NArg() > 0 && flag.Arg(0) == "capabilities" {
+		printCapabilities(flag.Args()[1:])
+		return
+	}
+
+	// flags win over env vars, the overrides are set before loading so they're validated the same way
+	if err := xplane.ApplyOverrides(overrides); err != nil {
+		log.Fatalf("Error loading configuration: %s", xplane.RedactSecrets(err.Error()))
+	}
+
+	// loading configuration
+	cfg, err := xplane.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading configuration: %s", xplane.RedactSecrets(err.Error()))
+	}
+	cfg.ForceSummary = *force
+	cfg.PostComment = *postComment
+	cfg.CopyToClipboard = *copySummary
+	cfg.RefreshCache = *refresh
+	cfg.ShowDiff = *showDiff
+	cfg.Explain = *explain
+
+	if flag.NArg() > 0 && flag.Arg(0) == "snapshot" {
+		runSnapshotCommand(cfg, flag.Args()[1:])
+		return
+	}
+
+	if flag.NArg() > 0 && flag.Arg(0) == "pr" {
+		runPullRequestCommand(cfg, flag.Args()[1:])
+		return
+	}
+
+	if flag.NArg() > 0 && flag.Arg(0) == "init" {
+		path, err := xplane.InitStaticContext()
+		if err != nil {
+			log.Fatalf("xplane: %s", xplane.RedactSecrets(err.Error()))
+		}
+		fmt.Printf("xplane: Wrote the default prompt to %s, edit it to customize the summaries.\n", path)
+		return
+	}
+
+	if flag.NArg() > 0 && flag.Arg(0) == "audit" {
+		if err := xplane.RunAudit(cfg); err != nil {
+			log.Fatalf("xplane: %s", xplane.RedactSecrets(err.Error()))
+		}
+		return
+	}
+
+	if *compare != "" {
+		if err := xplane.RunCompare(cfg, *compare, compareTo); err != nil {
+			log.Fatalf("xplane: %s", xplane.RedactSecrets(err.Error()))
+		}
+		return
+	}
+
+	if err := xplane.Run(cfg); err != nil {
+		log.Fatalf("xplane: %s", xplane.RedactSecrets(err.Error()))
+	}
+}
+
+// collects every --set, the flag package only keeps the last value otherwise
+type overrideFlags []string
+
+func (o *overrideFlags) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *overrideFlags) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("expected key=value, got '%s'", value)
+	}
+	*o = append(*o, value)
+	return nil
+}
+
+func runPullRequestCommand(cfg *xplane.Config, args []string) {
+	if len(args) != 1 {
+		flag.Usage()
+		log.Fatalf("xplane: expected 'pr <number>'")
+	}
+	number, err := strconv.Atoi(strings.TrimLeft(args[0], "#!"))
+	if err != nil || number <= 0 {
+		log.Fatalf("xplane: '%s' isn't a pull/merge request number", args[0])
+	}
+	if err := xplane.RunPullRequest(cfg, number); err != nil {
+		log.Fatalf("xplane: %s", xplane.RedactSecrets(err.Error()))
+	}
+}
+
+func runSnapshotCommand(cfg *xplane.Config, args []string) {
+	switch {
+	case len(args) == 2 && args[0] == "save":
+		if err := xplane.SaveSnapshot(cfg, args[1]); err != nil {
+			log.Fatalf("xplane: %s", xplane.RedactSecrets(err.Error()))
+		}
+	case len(args) == 1 && args[0] == "list":
+		names, err := xplane.ListSnapshots()
+		if err != nil {
+			log.Fatalf("xplane: %s", xplane.RedactSecrets(err.Error()))
+		}
+		if len(names) == 0 {
+			fmt.Println("xplane: No snapshots saved yet.")
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	default:
+		flag.Usage()
+		log.Fatalf("xplane: unknown snapshot command, expected 'snapshot save <name>' or 'snapshot list'")
+	}
+}
+
+func printCapabilities(args []string) {
+	capabilities := xplane.GetCapabilities()
+	if len(args) == 1 && args[0] == "--json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(capabilities); err != nil {
+			log.Fatalf("xplane: %s", xplane.RedactSecrets(err.Error()))
+		}
+		return
+	}
+	if len(args) > 0 {
+		flag.Usage()
+		log.Fatalf("xplane: unknown capabilities argument, expected 'capabilities' or 'capabilities --json'")
+	}
+
+	fmt.Printf("Providers: %s\n", strings.Join(capabilities.Providers, ", "))
+	fmt.Printf("Output formats: %s\n", strings.Join(capabilities.OutputFormats, ", "))
+	fmt.Printf("Context formats: %s\n", strings.Join(capabilities.ContextFormats, ", "))
+	fmt.Println("Commands:")
+	for _, command := range capabilities.Commands {
+		var needs []string
+		if command.RequiredBinary != "" {
+			needs = append(needs, "needs '"+command.RequiredBinary+"'")
+		}
+		if command.NeedsGitProvider {
+			needs = append(needs, "needs a GitHub/GitLab remote")
+		}
+		if len(needs) > 0 {
+			fmt.Printf("  %s (%s)\n", command.Name, strings.Join(needs, ", "))
+		} else {
+			fmt.Printf("  %s\n", command.Name)
+		}
+	}
+	fmt.Printf("Environment variables: %s\n", strings.Join(capabilities.EnvVars, ", "))
+}
@@ -0,0 +1,92 @@
+package xplane
+
+import (
+	"errors"
+	"strings"
+)
+
+// splits a command line like a POSIX shell would, without running one: words are separated by whitespace,
+// single quotes keep everything as is, double quotes keep whitespace and only honor \" and \\, and a
+// backslash outside quotes escapes the next character. no variables, globs or pipes
+func splitCommandLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord, escaped := false, false
+	var quote rune
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			if quote == '"' && r != '"' && r != '\\' {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated " + string(quote) + " quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	if len(words) == 0 {
+		return nil, errors.New("empty command line")
+	}
+	return words, nil
+}
+
+// splits raw on sep like strings.Split, except where sep is quoted or escaped, so that a command line
+// in a list can hold one as long as it's quoted like the shell would need it to be
+func splitOutsideQuotes(raw string, sep rune) []string {
+	var parts []string
+	start, escaped := 0, false
+	var quote rune
+	for i, r := range raw {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == sep:
+			parts = append(parts, raw[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, raw[start:])
+}
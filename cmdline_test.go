@@ -0,0 +1,44 @@
+package xplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected []string
+		err      string
+	}{
+		{"plain words", "golangci-lint run  ./...", []string{"golangci-lint", "run", "./..."}, ""},
+		{"single quotes", `git grep -n 'TODO: fix'`, []string{"git", "grep", "-n", "TODO: fix"}, ""},
+		{"double quotes", `git log --format="%h %s" -n 5`, []string{"git", "log", "--format=%h %s", "-n", "5"}, ""},
+		{"escapes in double quotes", `echo "a \"b\" \\ \n"`, []string{"echo", `a "b" \ \n`}, ""},
+		{"single quotes keep backslashes", `grep 'a\|b'`, []string{"grep", `a\|b`}, ""},
+		{"escaped space", `cat my\ file.txt`, []string{"cat", "my file.txt"}, ""},
+		{"empty quoted word", `printf ''`, []string{"printf", ""}, ""},
+		{"unterminated quote", `echo "oops`, nil, `unterminated " quote`},
+		{"trailing backslash", `echo \`, nil, "trailing backslash"},
+		{"empty", "   ", nil, "empty command line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, err := splitCommandLine(tt.line)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, words)
+		})
+	}
+}
+
+func TestSplitOutsideQuotes(t *testing.T) {
+	assert.Equal(t, []string{"a=x", "b=cut -d ',' -f1", `c="1,2"`, `d=x\,y`}, splitOutsideQuotes(`a=x,b=cut -d ',' -f1,c="1,2",d=x\,y`, ','))
+	assert.Equal(t, []string{""}, splitOutsideQuotes("", ','))
+}
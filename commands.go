@@ -1,14 +1,21 @@
-package main
+package xplane
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
+	"unicode/utf8"
 )
 
 // generic command runner
@@ -26,8 +33,19 @@ func runCommand(dir, name string, args ...string) (string, error) {
 	return out.String(), nil
 }
 
+// git is the first thing xplane runs, so a missing binary gets a clearer error than a failed command
+func ensureGitInstalled() error {
+	if err := ensureBinaryInstalled("git"); err != nil {
+		return fmt.Errorf("xplane requires git to be installed and in your PATH: %w", err)
+	}
+	return nil
+}
+
 // finds the top-level directory of the current git repository
 func findGitRoot() (string, error) {
+	if err := ensureGitInstalled(); err != nil {
+		return "", err
+	}
 	output, err := runCommand(".", "git", "rev-parse", "--show-toplevel")
 	if err != nil {
 		return "", err
@@ -56,6 +74,46 @@ func hasRemoteTrackingBranch(gitRoot string) bool {
 	return err == nil
 }
 
+// a rebase or amend leaves the same commits on both sides of HEAD...@{u}: patch-equivalent ones ('=' from --cherry-mark)
+// or ones with the same subject whose patch changed while resolving conflicts
+func looksRewritten(leftRightLog string) bool {
+	subjects := map[byte]map[string]bool{'<': {}, '>': {}}
+	for _, line := range strings.Split(strings.TrimSpace(leftRightLog), "\n") {
+		mark, subject, found := strings.Cut(line, "\x1f")
+		if !found || len(mark) != 1 {
+			continue
+		}
+		if mark[0] == '=' {
+			return true
+		}
+		if side, ok := subjects[mark[0]]; ok {
+			side[subject] = true
+		}
+	}
+	for subject := range subjects['<'] {
+		if subjects['>'][subject] {
+			return true
+		}
+	}
+	return false
+}
+
+// reports whether the local branch and its remote tracking branch hold rewritten copies of each other's history,
+// i.e. a local rebase that still needs a force-push, or a force-push to the remote since the last pull
+func hasRewrittenHistory(gitRoot string) (bool, error) {
+	// fetch records a force-pushed remote branch as a 'forced-update' in the tracking branch's reflog
+	lastUpdate, err := runCommand(gitRoot, "git", "reflog", "show", "-n", "1", "--format=%gs", "@{u}")
+	if err == nil && strings.Contains(lastUpdate, "forced-update") {
+		return true, nil
+	}
+
+	leftRightLog, err := runCommand(gitRoot, "git", "log", "--left-right", "--cherry-mark", "--format=%m%x1f%s", "HEAD...@{u}")
+	if err != nil {
+		return false, err
+	}
+	return looksRewritten(leftRightLog), nil
+}
+
 func getHostFromURL(url string) (string, error) {
 	host, _, _, err := parseGitURL(url)
 	if err != nil {
@@ -98,39 +156,281 @@ func getGitProvider(gitRoot string, cfg *Config) (GitProvider, error) {
 		if cfg.GithubToken == "" {
 			return nil, fmt.Errorf("special command 'github_prs' requires GITHUB_TOKEN to be set")
 		}
-		return NewGitHubProvider(cfg.GithubToken, originRemote, primaryRemote), nil
+		provider := NewGitHubProvider(cfg.GithubToken, originRemote, primaryRemote)
+		provider.SetUserAgent(cfg.userAgent())
+		return provider, nil
 	}
 
 	if strings.Contains(primaryRemote, "gitlab") {
 		if cfg.GitlabToken == "" {
 			return nil, fmt.Errorf("special command 'gitlab_mrs' requires GITLAB_TOKEN to be set")
 		}
-		return NewGitlabProvider(cfg.GitlabToken, hostURL, originRemote, primaryRemote)
+		provider, err := NewGitlabProvider(cfg.GitlabToken, hostURL, originRemote, primaryRemote)
+		if err != nil {
+			return nil, err
+		}
+		provider.SetUserAgent(cfg.userAgent())
+		return provider, nil
 	}
 	return nil, fmt.Errorf("xplane: unsupported git provider")
 }
 
-// returns git status in a machine parsable format using the low level porcelain format
-func getGitStatus(gitRoot string) (string, error) {
-	fmt.Println(MsgCheckingGitStatus)
-	return runCommand(gitRoot, "git", "status", "--porcelain")
+// appends a pathspec leaving out .xplane/ and the extra paths, so xplane doesn't end up summarizing its own stored
+// context and knowledge in repos that commit them
+func excludingXplaneFiles(extra []string, args ...string) []string {
+	args = append(args, "--", ".")
+	return append(args, xplaneExcludePathspecs(extra)...)
+}
+
+// the pathspecs leaving out .xplane/ and the extra paths, for commands that are already scoped to some paths
+func xplaneExcludePathspecs(extra []string) []string {
+	pathspecs := []string{":(exclude)" + contextDir}
+	for _, path := range extra {
+		pathspecs = append(pathspecs, ":(exclude)"+path)
+	}
+	return pathspecs
+}
+
+// returns git status in a machine parsable format using the low level porcelain format, excluded are paths to leave out
+func getGitStatus(gitRoot string, excluded ...string) (string, error) {
+	return runCommand(gitRoot, "git", excludingXplaneFiles(excluded, "status", "--porcelain")...)
 }
 
 // returns a concise log of the latest N commits
-func getGitLog(gitRoot string, n int) (string, error) {
-	fmt.Println(MsgFetchingGitLog)
-	return runCommand(gitRoot, "git", "log", "--oneline", "--graph", "--decorate", "-n", strconv.Itoa(n))
+func getGitLog(gitRoot string, n int, author string) (string, error) {
+	args := append([]string{"log", "--oneline", "--graph", "--decorate", "-n", strconv.Itoa(n)}, authorFilterArgs(author)...)
+	output, err := runCommand(gitRoot, "git", args...)
+	if err != nil || author == "" {
+		return output, err
+	}
+	return scopeToAuthor(output, author), nil
+}
+
+// XPLANE_AUTHOR narrows the commit based commands to one person's work, git matches it as a regex against name and email
+func authorFilterArgs(author string) []string {
+	if author == "" {
+		return nil
+	}
+	return []string{"--author=" + author}
+}
+
+// makes it obvious to the llm that the history is filtered, rather than the project being quiet
+func scopeToAuthor(output, author string) string {
+	if strings.TrimSpace(output) == "" {
+		return fmt.Sprintf("No recent commits by '%s'.", author)
+	}
+	return fmt.Sprintf("Only commits by '%s':\n%s", author, output)
+}
+
+// each commit's patch is cut past this, one big refactor shouldn't crowd out the other commits
+const maxCommitPatchLength = 4000
+
+// returns the latest N commits with their patches, each capped to maxCommitPatchLength
+func getGitLogPatches(gitRoot string, n int, author string) (string, error) {
+	// the record separator marks where each commit starts, patches can contain anything else
+	args := append([]string{"log", "-p", "--no-color", "-n", strconv.Itoa(n), "--format=%x1ecommit %h (%an, %ad)%n%n    %s%n", "--date=short"}, authorFilterArgs(author)...)
+	output, err := runCommand(gitRoot, "git", args...)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	for _, commit := range strings.Split(output, "\x1e") {
+		if strings.TrimSpace(commit) == "" {
+			continue
+		}
+		builder.WriteString(capCommitPatch(commit))
+	}
+	if author != "" {
+		return scopeToAuthor(builder.String(), author), nil
+	}
+	if builder.Len() == 0 {
+		return "No commits yet.", nil
+	}
+	return builder.String(), nil
+}
+
+// returns the latest n commits touching path with their patches, following renames, for deep dives on one module
+func getPathHistory(gitRoot, path string, n int) (string, error) {
+	if path == "" {
+		return "path_history needs XPLANE_HISTORY_PATH to be set.", nil
+	}
+	output, err := runCommand(gitRoot, "git", "log", "--follow", "-p", "--no-color", "-n", strconv.Itoa(n),
+		"--format=%x1ecommit %h (%an, %ad)%n%n    %s%n", "--date=short", "--", path)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	for _, commit := range strings.Split(output, "\x1e") {
+		if strings.TrimSpace(commit) == "" {
+			continue
+		}
+		builder.WriteString(capCommitPatch(commit))
+	}
+	if builder.Len() == 0 {
+		return fmt.Sprintf("No commits touch '%s'.", path), nil
+	}
+	return fmt.Sprintf("History of '%s':\n%s", path, builder.String()), nil
+}
+
+// what changed in the commits since the latest tag reachable from HEAD, for release notes style summaries.
+// the full diff is cut past budget, the stat above it still covers every file
+func getDiffSinceRelease(gitRoot string, budget int) (string, error) {
+	tags, err := runCommand(gitRoot, "git", "tag", "--list")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(tags) == "" {
+		return "No release tags found, nothing to compare against.", nil
+	}
+	tag, err := runCommand(gitRoot, "git", "describe", "--tags", "--abbrev=0")
+	if err != nil {
+		return "No release tag is reachable from the current commit.", nil
+	}
+	tag = strings.TrimSpace(tag)
+	releaseRange := tag + "..HEAD"
+
+	commitCount, err := runCommand(gitRoot, "git", "rev-list", "--count", releaseRange)
+	if err != nil {
+		return "", err
+	}
+	commitCount = strings.TrimSpace(commitCount)
+	if commitCount == "0" {
+		return fmt.Sprintf("No changes since the latest release '%s'.", tag), nil
+	}
+
+	diff, err := getRangeDiff(gitRoot, releaseRange, budget)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Changes since the latest release '%s' (%s commits):\n%s", tag, commitCount, diff), nil
+}
+
+// the --stat overview of a commit range followed by its full diff, cut past budget
+func getRangeDiff(gitRoot, revRange string, budget int) (string, error) {
+	stat, err := runCommand(gitRoot, "git", "diff", "--stat", revRange)
+	if err != nil {
+		return "", err
+	}
+	diff, err := runCommand(gitRoot, "git", "diff", revRange)
+	if err != nil {
+		return "", err
+	}
+	if len(diff) > budget {
+		kept := truncateAtRune(diff, budget)
+		diff = fmt.Sprintf("%s\n[diff truncated, %d more characters]\n", kept, len(diff)-len(kept))
+	}
+	return stat + "\n" + diff, nil
+}
+
+// the longest prefix of s no longer than limit bytes that doesn't split a multi-byte character
+func truncateAtRune(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
+
+// everything the current branch added since it forked off the base branch, committed work only
+func getBranchDiff(gitRoot, baseBranch string, budget int) (string, error) {
+	baseRef := findBaseBranchRef(gitRoot, baseBranch)
+	if baseRef == "" {
+		return "Could not find the branch to compare against, set XPLANE_COMPARE_BRANCH or run 'git remote set-head origin --auto'.", nil
+	}
+	mergeBase, err := runCommand(gitRoot, "git", "merge-base", baseRef, "HEAD")
+	if err != nil {
+		return fmt.Sprintf("The current branch shares no history with '%s'.", baseRef), nil
+	}
+	mergeBase = strings.TrimSpace(mergeBase)
+	branchRange := mergeBase + "..HEAD"
+
+	commitCount, err := runCommand(gitRoot, "git", "rev-list", "--count", branchRange)
+	if err != nil {
+		return "", err
+	}
+	commitCount = strings.TrimSpace(commitCount)
+	if commitCount == "0" {
+		return fmt.Sprintf("No commits on the current branch since it forked off '%s'.", baseRef), nil
+	}
+
+	diff, err := getRangeDiff(gitRoot, branchRange, budget)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Changes on the current branch since it forked off '%s' at %.12s (%s commits):\n%s", baseRef, mergeBase, commitCount, diff), nil
 }
 
-// returns code statistics in json format
+func capCommitPatch(commit string) string {
+	if len(commit) <= maxCommitPatchLength {
+		return commit
+	}
+	kept := truncateAtRune(commit, maxCommitPatchLength)
+	return fmt.Sprintf("%s\n[patch truncated, %d more characters]\n\n", kept, len(commit)-len(kept))
+}
+
+// returns code statistics per language as a compact table, falling back to tokei's own text output
+// when its json can't be read (tokei built without json support, or a format this doesn't know)
 func getTokeiStats(gitRoot string) (string, error) {
-	fmt.Println(MsgGetCodeStats)
-	return runCommand(gitRoot, "tokei", "--output", "json")
+	output, err := runCommand(gitRoot, "tokei", "--output", "json")
+	if err == nil {
+		if summary, parseErr := formatTokeiJSON(output); parseErr == nil {
+			return summary, nil
+		}
+	}
+	return runCommand(gitRoot, "tokei")
+}
+
+// the fields shared by all tokei json versions, per-file details are 'reports' since tokei 12 and 'stats' before
+type tokeiLanguage struct {
+	Blanks   int               `json:"blanks"`
+	Code     int               `json:"code"`
+	Comments int               `json:"comments"`
+	Reports  []json.RawMessage `json:"reports"`
+	Stats    []json.RawMessage `json:"stats"`
+}
+
+func formatTokeiJSON(output string) (string, error) {
+	var languages map[string]tokeiLanguage
+	if err := json.Unmarshal([]byte(output), &languages); err != nil {
+		return "", err
+	}
+
+	// recomputed below, the files count isn't in tokei's own total
+	delete(languages, "Total")
+	names := slices.Collect(maps.Keys(languages))
+	// biggest first, by name on ties so the context is stable between runs
+	sort.Slice(names, func(i, j int) bool {
+		if languages[names[i]].Code != languages[names[j]].Code {
+			return languages[names[i]].Code > languages[names[j]].Code
+		}
+		return names[i] < names[j]
+	})
+
+	if len(names) == 0 {
+		return "No code found by tokei.", nil
+	}
+
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "Language\tFiles\tCode\tComments\tBlanks")
+	var files, code, comments, blanks int
+	for _, name := range names {
+		language := languages[name]
+		languageFiles := len(language.Reports) + len(language.Stats)
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%d\n", name, languageFiles, language.Code, language.Comments, language.Blanks)
+		files, code, comments, blanks = files+languageFiles, code+language.Code, comments+language.Comments, blanks+language.Blanks
+	}
+	fmt.Fprintf(writer, "Total\t%d\t%d\t%d\t%d\n", files, code, comments, blanks)
+	writer.Flush()
+	return builder.String(), nil
 }
 
 // returns potential leaked secrets
 func getRipSecrets(gitRoot string) (string, error) {
-	fmt.Println(MsgGetLeakedSecrets)
 	cmd := exec.Command("ripsecrets", gitRoot)
 	cmd.Dir = gitRoot
 	var out, stderr bytes.Buffer
@@ -153,6 +453,102 @@ func getRipSecrets(gitRoot string) (string, error) {
 	return "", fmt.Errorf("command 'ripsecrets' failed: %s, stderr: %s", err, stderr.String())
 }
 
+// the commit a revert undoes, as 'git revert' writes it in the message body
+var revertedCommitRegex = regexp.MustCompile(`This reverts commit ([0-9a-f]{7,40})`)
+
+// lists the latest n revert commits, found by git revert's subject or its "This reverts commit" body line
+func getReverts(gitRoot string, n int) (string, error) {
+	// the unit and record separators keep multi-line bodies apart
+	output, err := runCommand(gitRoot, "git", "log", "-n", strconv.Itoa(n), "-E", "--grep=^Revert", "--grep=This reverts commit [0-9a-f]+",
+		"--date=short", "--format=%h%x1f%ad%x1f%an%x1f%s%x1f%b%x1e")
+	if err != nil {
+		return "", err
+	}
+	return formatReverts(output), nil
+}
+
+func formatReverts(logOutput string) string {
+	var builder strings.Builder
+	for _, record := range strings.Split(logOutput, "\x1e") {
+		fields := strings.SplitN(strings.TrimSpace(record), "\x1f", 5)
+		if len(fields) < 5 {
+			continue
+		}
+		hash, date, author, subject, body := fields[0], fields[1], fields[2], fields[3], fields[4]
+		builder.WriteString(fmt.Sprintf("- %s %s (%s, %s)", hash, subject, author, date))
+		if match := revertedCommitRegex.FindStringSubmatch(body); match != nil {
+			builder.WriteString(fmt.Sprintf(", reverts %s", shortHash(match[1])))
+		}
+		builder.WriteString("\n")
+	}
+	if builder.Len() == 0 {
+		return "No recent reverts."
+	}
+	return builder.String()
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// conflict markers as git writes them
+const conflictMarkerPattern = `^(<<<<<<<( |$)|>>>>>>>( |$)|=======$)`
+
+// reports tracked files still holding conflict markers, left behind by an unfinished merge or rebase
+func getConflictMarkers(gitRoot string) (string, error) {
+	// -z separates path, line number and content with NUL, paths can contain colons
+	cmd := exec.Command("git", "grep", "-n", "-z", "-I", "-E", conflictMarkerPattern)
+	cmd.Dir = gitRoot
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		// git grep exits with 1 when nothing matched
+		return "No conflict markers found.", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("command 'git grep' failed: %s, stderr: %s", err, stderr.String())
+	}
+	return formatConflictMarkers(out.String()), nil
+}
+
+func formatConflictMarkers(grepOutput string) string {
+	var files []string
+	linesByFile := make(map[string][]string)
+	hasConflict := make(map[string]bool)
+	for _, match := range strings.Split(strings.TrimSpace(grepOutput), "\n") {
+		fields := strings.SplitN(match, "\x00", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		file, line, content := fields[0], fields[1], fields[2]
+		if _, seen := linesByFile[file]; !seen {
+			files = append(files, file)
+		}
+		linesByFile[file] = append(linesByFile[file], line)
+		// a lone ======= is just as likely a markdown heading underline
+		if !strings.HasPrefix(content, "=") {
+			hasConflict[file] = true
+		}
+	}
+	files = slices.DeleteFunc(files, func(file string) bool { return !hasConflict[file] })
+	if len(files) == 0 {
+		return "No conflict markers found."
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Files with unresolved conflict markers:\n")
+	for _, file := range files {
+		builder.WriteString(fmt.Sprintf("- %s (lines %s)\n", file, strings.Join(linesByFile[file], ", ")))
+	}
+	return builder.String()
+}
+
 // reads and returns README.md's content if present, or a placeholder string
 func getReadme(gitRoot string) (string, error) {
 	var output string
@@ -167,9 +563,24 @@ func getReadme(gitRoot string) (string, error) {
 	return output, nil
 }
 
+// the directory shared by all worktrees of the repo, in a linked worktree .git is a file pointing elsewhere.
+// falls back to <root>/.git when git can't tell, e.g. for a bare copy of a .git folder
+func gitCommonDir(gitRoot string) string {
+	commonDir, err := runCommand(gitRoot, "git", "rev-parse", "--git-common-dir")
+	commonDir = strings.TrimSpace(commonDir)
+	if err != nil || commonDir == "" {
+		return filepath.Join(gitRoot, ".git")
+	}
+	// relative to the directory git ran in, in the main worktree it's just '.git'
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitRoot, commonDir)
+	}
+	return commonDir
+}
+
 // reads and returns .git/info/exclude content if present, or a placeholder string
 func getGitExclude(gitRoot string) (string, error) {
-	excludeBytes, err := os.ReadFile(filepath.Join(gitRoot, ".git", "info", "exclude"))
+	excludeBytes, err := os.ReadFile(filepath.Join(gitCommonDir(gitRoot), "info", "exclude"))
 	if os.IsNotExist(err) {
 		return "No .git/info/exclude file found.", nil
 	} else if err != nil {
@@ -189,22 +600,583 @@ func getGitignore(gitRoot string) (string, error) {
 	return string(gitignoreBytes), nil
 }
 
-// returns git diff output showing latest changes
-func getGitDiff(gitRoot string) (string, error) {
-	fmt.Println(MsgFetchingGitDiff)
-	diff, err := runCommand(gitRoot, "git", "diff")
+var licenseFiles = []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "COPYING"}
+
+// the first non-blank line of a license is its title in virtually every template ("MIT License", "Apache License", ...)
+func detectLicenseType(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.Trim(strings.TrimSpace(line), "#= ")
+		if line == "" {
+			continue
+		}
+		if len(line) > 80 {
+			line = line[:80] + "..."
+		}
+		return line
+	}
+	return "unknown (empty license file)"
+}
+
+// reports the project's license type and flags uncommitted changes to the license file, staged or not, and a
+// license file that isn't tracked yet, without its full text
+func getLicense(gitRoot string) (string, error) {
+	for _, name := range licenseFiles {
+		content, err := os.ReadFile(filepath.Join(gitRoot, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		licenseType := detectLicenseType(string(content))
+		output := fmt.Sprintf("License file: %s\nLicense type: %s\n", name, licenseType)
+		untracked, err := runCommand(gitRoot, "git", "ls-files", "--others", "--exclude-standard", "--", name)
+		if err != nil {
+			return "", err
+		}
+		if untracked != "" {
+			return output + "WARNING: the license file is new and not tracked yet, which may be legally significant.\n", nil
+		}
+		stagedStat, err := runCommand(gitRoot, "git", "diff", "--cached", "--stat", "--", name)
+		if err != nil {
+			return "", err
+		}
+		unstagedStat, err := runCommand(gitRoot, "git", "diff", "--stat", "--", name)
+		if err != nil {
+			return "", err
+		}
+		if stagedStat == "" && unstagedStat == "" {
+			return output + "The license file has no uncommitted changes.", nil
+		}
+
+		output += "WARNING: the license file has uncommitted changes, which may be legally significant:\n"
+		if stagedStat != "" {
+			output += fmt.Sprintf("Staged:\n%s\n", stagedStat)
+		}
+		if unstagedStat != "" {
+			output += fmt.Sprintf("Not staged:\n%s\n", unstagedStat)
+		}
+		// a changed title means a different license altogether, not just a reworded year or holder
+		committed, err := runCommand(gitRoot, "git", "show", "HEAD:"+name)
+		if err != nil {
+			output += "The license file is new, it isn't in the last commit.\n"
+		} else if previousType := detectLicenseType(committed); previousType != licenseType {
+			output += fmt.Sprintf("The license type changed from '%s' to '%s'.\n", previousType, licenseType)
+		}
+		return output, nil
+	}
+	return "No license file found in this project.", nil
+}
+
+// the remote default branch refs tried, in order, to find where the current branch forked off
+var defaultBranchRefs = []string{"refs/remotes/upstream/HEAD", "refs/remotes/origin/HEAD"}
+
+// the first of defaultBranchRefs that exists, or of the remote and local refs of baseBranch when it's set
+// (XPLANE_COMPARE_BRANCH), empty when there's none
+func findBaseBranchRef(gitRoot, baseBranch string) string {
+	candidates := defaultBranchRefs
+	if baseBranch != "" {
+		candidates = []string{"refs/remotes/upstream/" + baseBranch, "refs/remotes/origin/" + baseBranch, "refs/heads/" + baseBranch}
+	}
+	for _, ref := range candidates {
+		if _, err := runCommand(gitRoot, "git", "rev-parse", "--verify", "--quiet", ref); err == nil {
+			return ref
+		}
+	}
+	return ""
+}
+
+// lists the files the current branch touches: its commits since it forked off the remote default branch, plus uncommitted changes
+func getBranchChangedFiles(gitRoot string) ([]string, error) {
+	changed, err := runCommand(gitRoot, "git", "diff", "--name-only", "HEAD")
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if ref := findBaseBranchRef(gitRoot, ""); ref != "" {
+		committed, err := runCommand(gitRoot, "git", "diff", "--name-only", ref+"...HEAD")
+		if err != nil {
+			return nil, err
+		}
+		changed += committed
+	}
+
+	var files []string
+	for _, file := range strings.Split(changed, "\n") {
+		if file = strings.TrimSpace(file); file != "" && !slices.Contains(files, file) {
+			files = append(files, file)
+		}
 	}
+	return files, nil
+}
 
+// returns git diff output showing latest changes
+func getGitDiff(gitRoot string) (string, error) {
+	return getBudgetedGitDiff(&Config{}, gitRoot, false)
+}
+
+func formatGitDiff(diff string) string {
 	// Add timestamp and explanatory context to help LLMs understand
 	// that this shows uncommitted changes (static until committed)
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	header := fmt.Sprintf("Git diff captured at %s - Shows uncommitted changes (remains static until committed):\n\n", timestamp)
 
 	if diff == "" {
-		return header + "No uncommitted changes found.", nil
+		return header + "No uncommitted changes found."
+	}
+
+	return header + diff
+}
+
+type fileChurn struct {
+	path      string
+	additions int
+	deletions int
+	binary    bool
+}
+
+// parses `git diff --numstat` into per-file line counts, biggest churn first, binary files ('-' counts) last
+func parseNumstat(numstat string) []fileChurn {
+	var files []fileChurn
+	for _, line := range strings.Split(strings.TrimSpace(numstat), "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		file := fileChurn{path: fields[2]}
+		additions, addErr := strconv.Atoi(fields[0])
+		deletions, delErr := strconv.Atoi(fields[1])
+		if addErr != nil || delErr != nil {
+			file.binary = true
+		}
+		file.additions, file.deletions = additions, deletions
+		files = append(files, file)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		churnI, churnJ := files[i].additions+files[i].deletions, files[j].additions+files[j].deletions
+		if churnI != churnJ {
+			return churnI > churnJ
+		}
+		return files[i].path < files[j].path
+	})
+	return files
+}
+
+// a compact per-file table of uncommitted line changes, a cheap complement to the full git_diff
+func getDiffSummary(gitRoot string, excluded ...string) (string, error) {
+	numstat, err := runCommand(gitRoot, "git", excludingXplaneFiles(excluded, "diff", "--numstat")...)
+	if err != nil {
+		return "", err
+	}
+
+	files := parseNumstat(numstat)
+	if len(files) == 0 {
+		return "No uncommitted changes found.", nil
+	}
+
+	var builder strings.Builder
+	totalAdditions, totalDeletions := 0, 0
+	for _, file := range files {
+		if file.binary {
+			builder.WriteString(fmt.Sprintf("%s: binary\n", file.path))
+			continue
+		}
+		totalAdditions += file.additions
+		totalDeletions += file.deletions
+		builder.WriteString(fmt.Sprintf("%s: +%d/-%d\n", file.path, file.additions, file.deletions))
+	}
+	builder.WriteString(fmt.Sprintf("Total: %d files, +%d/-%d\n", len(files), totalAdditions, totalDeletions))
+	return builder.String(), nil
+}
+
+var apiSpecPathspecs = []string{
+	":(glob)**/openapi.yaml", ":(glob)**/openapi.yml", ":(glob)**/openapi.json",
+	":(glob)**/swagger.yaml", ":(glob)**/swagger.yml", ":(glob)**/swagger.json",
+}
+
+// returns the uncommitted git diff restricted to the given pathspecs
+func getScopedGitDiff(gitRoot string, pathspecs ...string) (string, error) {
+	args := append([]string{"diff", "--"}, pathspecs...)
+	return runCommand(gitRoot, "git", args...)
+}
+
+var containerPathspecs = []string{
+	":(glob)**/Dockerfile", ":(glob)**/Dockerfile.*", ":(glob)**/*.Dockerfile", ":(glob)**/Containerfile",
+	":(glob)**/docker-compose*.yml", ":(glob)**/docker-compose*.yaml", ":(glob)**/compose.yml", ":(glob)**/compose.yaml",
+	":(glob)**/.dockerignore",
+}
+
+var ciConfigPathspecs = []string{
+	":(glob).github/workflows/**", ":(glob).github/actions/**", ":(glob)**/.gitlab-ci.yml", ":(glob).gitlab/ci/**",
+	":(glob)**/Makefile", ":(glob)**/*.mk", ":(glob)**/.env.example", ":(glob)**/.env.sample",
+}
+
+var docsPathspecs = []string{
+	":(glob)docs/**", ":(glob)doc/**",
+	":(glob)**/*.md", ":(glob)**/*.mdx", ":(glob)**/*.rst", ":(glob)**/*.adoc",
+}
+
+var testPathspecs = []string{
+	":(glob)**/*_test.go", ":(glob)**/test/**", ":(glob)**/tests/**", ":(glob)**/spec/**", ":(glob)**/__tests__/**",
+	":(glob)**/*.test.*", ":(glob)**/*.spec.*", ":(glob)**/test_*.py",
+}
+
+// returns uncommitted changes to test files, new untracked ones included. when there are none it names the
+// other changed files instead, so the llm can point out logic changes that came without tests
+func getTestDiff(gitRoot string) (string, error) {
+	diff, err := getScopedGitDiff(gitRoot, testPathspecs...)
+	if err != nil {
+		return "", err
+	}
+	newTests, err := runCommand(gitRoot, "git", append([]string{"ls-files", "--others", "--exclude-standard", "--"}, testPathspecs...)...)
+	if err != nil {
+		return "", err
+	}
+	if files := outputLines(newTests); len(files) > 0 {
+		diff += fmt.Sprintf("New untracked test files: %s\n", strings.Join(files, ", "))
+	}
+	if diff != "" {
+		return diff, nil
+	}
+
+	otherPathspecs := []string{".", ":(exclude)" + contextDir}
+	for _, pathspec := range testPathspecs {
+		otherPathspecs = append(otherPathspecs, strings.Replace(pathspec, ":(glob)", ":(exclude,glob)", 1))
+	}
+	changed, err := runCommand(gitRoot, "git", append([]string{"diff", "--name-only", "--"}, otherPathspecs...)...)
+	if err != nil {
+		return "", err
+	}
+	untracked, err := runCommand(gitRoot, "git", append([]string{"ls-files", "--others", "--exclude-standard", "--"}, otherPathspecs...)...)
+	if err != nil {
+		return "", err
+	}
+	files := append(outputLines(changed), outputLines(untracked)...)
+	if len(files) == 0 {
+		return "No uncommitted changes to test files.", nil
+	}
+	return fmt.Sprintf("No uncommitted changes to test files, while %d other files changed: %s", len(files), strings.Join(files, ", ")), nil
+}
+
+// the non-empty lines of a command's output, e.g. one path per line that may contain spaces
+func outputLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// returns the uncommitted diff for the given pathspecs, or a placeholder naming the kind of files when nothing changed
+func describeScopedGitDiff(gitRoot, kind string, pathspecs []string) (string, error) {
+	diff, err := getScopedGitDiff(gitRoot, pathspecs...)
+	if err != nil {
+		return "", err
+	}
+	if diff == "" {
+		return fmt.Sprintf("No uncommitted changes to %s.", kind), nil
+	}
+	return diff, nil
+}
+
+// returns uncommitted changes to Dockerfiles, compose files and .dockerignore
+func getContainerDiff(gitRoot string) (string, error) {
+	return describeScopedGitDiff(gitRoot, "container config files", containerPathspecs)
+}
+
+// returns uncommitted changes to CI pipelines, Makefiles and example env files, operational changes easily lost in a big diff
+func getCIConfigDiff(gitRoot string) (string, error) {
+	return describeScopedGitDiff(gitRoot, "CI and build config files", ciConfigPathspecs)
+}
+
+// returns uncommitted changes to docs folders and markup files, so they can be framed apart from code changes.
+// excluded are paths to leave out besides .xplane/, whose knowledge file is markdown too
+func getDocsDiff(gitRoot string, excluded ...string) (string, error) {
+	pathspecs := append(slices.Clone(docsPathspecs), xplaneExcludePathspecs(excluded)...)
+	return describeScopedGitDiff(gitRoot, "documentation", pathspecs)
+}
+
+// returns uncommitted changes to tracked OpenAPI/Swagger spec files, if any exist
+func getAPISpecDiff(gitRoot string) (string, error) {
+	args := append([]string{"ls-files", "--"}, apiSpecPathspecs...)
+	specFiles, err := runCommand(gitRoot, "git", args...)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(specFiles) == "" {
+		return "No OpenAPI/Swagger spec files found in this project.", nil
+	}
+
+	diff, err := getScopedGitDiff(gitRoot, apiSpecPathspecs...)
+	if err != nil {
+		return "", err
 	}
 
+	header := fmt.Sprintf("API spec files:\n%s\n", specFiles)
+	if diff == "" {
+		return header + "No uncommitted changes to API spec files.", nil
+	}
 	return header + diff, nil
 }
+
+var issueReferenceRegex = regexp.MustCompile(`(?:^|[^\w&/])#(\d+)\b`)
+
+type issueReference struct {
+	number int
+	commit string
+}
+
+// extracts unique issue references like '#123' or 'Closes #456' from `git log --format=%h%x1f%B%x1e` output,
+// keeping the most recent commit that mentioned each issue
+func extractIssueReferences(gitLog string) []issueReference {
+	var references []issueReference
+	seen := make(map[int]bool)
+
+	for _, record := range strings.Split(gitLog, "\x1e") {
+		commit, message, found := strings.Cut(strings.TrimSpace(record), "\x1f")
+		if !found {
+			continue
+		}
+		for _, match := range issueReferenceRegex.FindAllStringSubmatch(message, -1) {
+			number, err := strconv.Atoi(match[1])
+			if err != nil || seen[number] {
+				continue
+			}
+			seen[number] = true
+			references = append(references, issueReference{number: number, commit: commit})
+		}
+	}
+	return references
+}
+
+// caps how many changed files get blamed, blame is slow on big files
+const maxBlamedFiles = 10
+
+// counts lines per author in `git blame --line-porcelain` output, formatted as "alice (12), bob (3)"
+func summarizeBlameAuthors(porcelain string) string {
+	counts := make(map[string]int)
+	for _, line := range strings.Split(porcelain, "\n") {
+		if author, found := strings.CutPrefix(line, "author "); found {
+			counts[author]++
+		}
+	}
+
+	authors := make([]string, 0, len(counts))
+	for author := range counts {
+		authors = append(authors, author)
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		if counts[authors[i]] != counts[authors[j]] {
+			return counts[authors[i]] > counts[authors[j]]
+		}
+		return authors[i] < authors[j]
+	})
+
+	parts := make([]string, 0, len(authors))
+	for _, author := range authors {
+		parts = append(parts, fmt.Sprintf("%s (%d)", author, counts[author]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// returns per-author line counts for the files touched by the uncommitted diff
+func getRecentBlame(gitRoot string) (string, error) {
+	changedFiles, err := runCommand(gitRoot, "git", "diff", "--name-only", "--diff-filter=d")
+	if err != nil {
+		return "", err
+	}
+
+	files := strings.Split(strings.TrimSpace(changedFiles), "\n")
+	if files[0] == "" {
+		return "No uncommitted changes to blame.", nil
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Line ownership of files with uncommitted changes ('Not Committed Yet' marks uncommitted lines):\n")
+	for i, file := range files {
+		if i == maxBlamedFiles {
+			builder.WriteString(fmt.Sprintf("... and %d more files\n", len(files)-maxBlamedFiles))
+			break
+		}
+		porcelain, err := runCommand(gitRoot, "git", "blame", "--line-porcelain", "--", file)
+		if err != nil {
+			// e.g. binary or freshly renamed files, not worth failing the whole command
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("%s: %s\n", file, summarizeBlameAuthors(porcelain)))
+	}
+	return builder.String(), nil
+}
+
+// caps how many recorded conflict resolutions get listed
+const maxRerereResolutions = 10
+
+type rerereResolution struct {
+	signature  string
+	recordedAt time.Time
+	resolved   bool
+}
+
+// lists the conflicts recorded in git's rr-cache, newest first, a conflict counts as resolved once git saved a postimage for it
+func listRerereResolutions(rrCacheDir string) ([]rerereResolution, error) {
+	entries, err := os.ReadDir(rrCacheDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var resolutions []rerereResolution
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(rrCacheDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		resolution := rerereResolution{signature: entry.Name()}
+		for _, file := range files {
+			info, err := file.Info()
+			if err != nil {
+				continue
+			}
+			if info.ModTime().After(resolution.recordedAt) {
+				resolution.recordedAt = info.ModTime()
+			}
+			if strings.HasPrefix(file.Name(), "postimage") {
+				resolution.resolved = true
+			}
+		}
+		resolutions = append(resolutions, resolution)
+	}
+
+	sort.Slice(resolutions, func(i, j int) bool {
+		return resolutions[i].recordedAt.After(resolutions[j].recordedAt)
+	})
+	return resolutions, nil
+}
+
+// reports whether git rerere is on, which paths it's tracking right now and the latest recorded resolutions
+func getRerereStatus(gitRoot string) (string, error) {
+	rrCachePath, err := runCommand(gitRoot, "git", "rev-parse", "--git-path", "rr-cache")
+	if err != nil {
+		return "", err
+	}
+	rrCacheDir := strings.TrimSpace(rrCachePath)
+	if !filepath.IsAbs(rrCacheDir) {
+		rrCacheDir = filepath.Join(gitRoot, rrCacheDir)
+	}
+
+	// git enables rerere implicitly when rr-cache exists and rerere.enabled isn't set
+	setting, _ := runCommand(gitRoot, "git", "config", "--get", "rerere.enabled")
+	setting = strings.TrimSpace(setting)
+	_, statErr := os.Stat(rrCacheDir)
+	if setting == "false" || (setting == "" && os.IsNotExist(statErr)) {
+		return "git rerere is disabled.", nil
+	}
+
+	var builder strings.Builder
+	builder.WriteString("git rerere is enabled.\n")
+
+	if tracked, err := runCommand(gitRoot, "git", "rerere", "status"); err == nil && strings.TrimSpace(tracked) != "" {
+		builder.WriteString(fmt.Sprintf("Paths with conflicts being recorded right now:\n%s\n", strings.TrimSpace(tracked)))
+	}
+
+	resolutions, err := listRerereResolutions(rrCacheDir)
+	if err != nil {
+		return "", fmt.Errorf("could not read rr-cache: %w", err)
+	}
+	if len(resolutions) == 0 {
+		builder.WriteString("No conflict resolutions recorded yet.")
+		return builder.String(), nil
+	}
+
+	builder.WriteString("Recently recorded conflict resolutions:\n")
+	for i, resolution := range resolutions {
+		if i == maxRerereResolutions {
+			builder.WriteString(fmt.Sprintf("... and %d older ones\n", len(resolutions)-maxRerereResolutions))
+			break
+		}
+		state := "resolved"
+		if !resolution.resolved {
+			state = "unresolved"
+		}
+		builder.WriteString(fmt.Sprintf("- %s (%s, %s)\n", resolution.signature, state, resolution.recordedAt.Format("2006-01-02 15:04")))
+	}
+	return builder.String(), nil
+}
+
+var submoduleStates = map[byte]string{
+	' ': "in sync",
+	'-': "not initialized",
+	'+': "checked out commit differs from the recorded one",
+	'U': "has merge conflicts",
+}
+
+type submoduleStatus struct {
+	path     string
+	commit   string
+	describe string
+	state    string
+}
+
+// parses `git submodule status` lines like "+1a2b3c4 vendor/lib (v1.2.0-3-g1a2b3c4)"
+func parseSubmoduleStatus(output string) []submoduleStatus {
+	var submodules []submoduleStatus
+	for _, line := range strings.Split(output, "\n") {
+		if len(line) < 2 {
+			continue
+		}
+		state, ok := submoduleStates[line[0]]
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(line[1:])
+		if len(fields) < 2 {
+			continue
+		}
+		submodule := submoduleStatus{commit: fields[0], path: fields[1], state: state}
+		if len(fields) > 2 {
+			submodule.describe = strings.Trim(strings.Join(fields[2:], " "), "()")
+		}
+		submodules = append(submodules, submodule)
+	}
+	return submodules
+}
+
+// lists submodule commit pointers and their state, plus a summary of pointers changed but not committed yet
+func getGitSubmodules(gitRoot string) (string, error) {
+	statusOutput, err := runCommand(gitRoot, "git", "submodule", "status")
+	if err != nil {
+		return "", err
+	}
+
+	submodules := parseSubmoduleStatus(statusOutput)
+	if len(submodules) == 0 {
+		return "No submodules in this repository.", nil
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Submodules:\n")
+	for _, submodule := range submodules {
+		commit := submodule.commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		if submodule.describe != "" {
+			commit = fmt.Sprintf("%s (%s)", commit, submodule.describe)
+		}
+		builder.WriteString(fmt.Sprintf("- %s at %s: %s\n", submodule.path, commit, submodule.state))
+	}
+
+	summary, err := runCommand(gitRoot, "git", "submodule", "summary")
+	if err == nil && strings.TrimSpace(summary) != "" {
+		builder.WriteString("\nUncommitted submodule pointer changes:\n")
+		builder.WriteString(strings.TrimSpace(summary))
+		builder.WriteString("\n")
+	}
+	return builder.String(), nil
+}
@@ -1,11 +1,13 @@
-package main
+package xplane
 
 import (
 	"bytes"
 	"io"
 	"os"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -26,6 +28,13 @@ func TestFindGitRoot(t *testing.T) {
 		assert.Error(t, err)
 		assert.Equal(t, root, "")
 	})
+
+	t.Run("fails clearly when git is not installed", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		root, err := findGitRoot()
+		assert.ErrorContains(t, err, "xplane requires git to be installed and in your PATH")
+		assert.Equal(t, root, "")
+	})
 }
 
 func TestGetHostFromURL(t *testing.T) {
@@ -129,6 +138,22 @@ func TestGetGitExclude(t *testing.T) {
 	}
 }
 
+func TestGetGitExcludeInLinkedWorktree(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+	assert.NoError(t, os.WriteFile(path.Join(root, ".git", "info", "exclude"), []byte("*.secret\n"), 0o644))
+	worktree := path.Join(t.TempDir(), "feature")
+	_, err := runCommand(root, "git", "worktree", "add", "-q", "-b", "feature", worktree)
+	assert.NoError(t, err)
+
+	gitFile, err := os.Stat(path.Join(worktree, ".git"))
+	assert.NoError(t, err)
+	assert.False(t, gitFile.IsDir(), ".git is a file pointing to the main repo in a linked worktree")
+
+	content, err := getGitExclude(worktree)
+	assert.NoError(t, err)
+	assert.Equal(t, "*.secret\n", content)
+}
+
 func TestGetGitignore(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -170,7 +195,7 @@ func TestGetGitStatus(t *testing.T) {
 		r, w, _ := os.Pipe()
 		os.Stdout = w
 
-		getGitStatus("/tmp") // fail but print msg
+		printProgress(&Config{}, ProgressEvent{Kind: ProgressCommandStarted, Command: "git_status"})
 
 		w.Close()
 		os.Stdout = old
@@ -241,7 +266,7 @@ func TestGetGitLog(t *testing.T) {
 			r, w, _ := os.Pipe()
 			os.Stdout = w
 
-			result, err := getGitLog(tt.gitRoot, tt.n)
+			result, err := getGitLog(tt.gitRoot, tt.n, "")
 
 			w.Close()
 			os.Stdout = old
@@ -260,8 +285,8 @@ func TestGetGitLog(t *testing.T) {
 				}
 			}
 			
-			// Verify the message was printed
-			assert.Contains(t, buf.String(), "Fetching recent git log")
+			// printing is left to the progress handler
+			assert.NotContains(t, buf.String(), "Fetching recent git log")
 		})
 	}
 }
@@ -297,8 +322,8 @@ func TestGetTokeiStats(t *testing.T) {
 				assert.NotEmpty(t, result)
 			}
 
-			// Verify the message was printed
-			assert.Contains(t, buf.String(), "Analyzing code stats")
+			// printing is left to the progress handler
+			assert.NotContains(t, buf.String(), "Analyzing code stats")
 		})
 	}
 }
@@ -354,8 +379,8 @@ func TestGetRipSecrets(t *testing.T) {
 				assert.IsType(t, "", result)
 			}
 
-			// Verify the message was printed
-			assert.Contains(t, buf.String(), "Detecting potentially leaked secrets")
+			// printing is left to the progress handler
+			assert.NotContains(t, buf.String(), "Detecting potentially leaked secrets")
 		})
 	}
 }
@@ -393,8 +418,8 @@ func TestGetGitDiff(t *testing.T) {
 				assert.Contains(t, result, "Git diff captured at")
 			}
 
-			// Verify the message was printed
-			assert.Contains(t, buf.String(), "Fetching uncommitted diff")
+			// printing is left to the progress handler
+			assert.NotContains(t, buf.String(), "Fetching uncommitted diff")
 		})
 	}
 }
@@ -424,4 +449,675 @@ func TestGetOriginOwner(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// creates a throwaway git repository with a single commit containing the given files
+func initTestRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		fullPath := path.Join(root, name)
+		assert.NoError(t, os.MkdirAll(path.Dir(fullPath), 0o755))
+		assert.NoError(t, os.WriteFile(fullPath, []byte(content), 0o644))
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=xplane", "-c", "user.email=xplane@example.com", "add", "-A"},
+		{"-c", "user.name=xplane", "-c", "user.email=xplane@example.com", "commit", "-q", "--allow-empty", "-m", "initial"},
+	} {
+		_, err := runCommand(root, "git", args...)
+		assert.NoError(t, err)
+	}
+	return root
+}
+
+func TestGetAPISpecDiff(t *testing.T) {
+	t.Run("no spec files", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{"main.go": "package main"})
+		output, err := getAPISpecDiff(root)
+		assert.NoError(t, err)
+		assert.Equal(t, "No OpenAPI/Swagger spec files found in this project.", output)
+	})
+
+	t.Run("unchanged spec", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{"api/openapi.yaml": "openapi: 3.0.0\n"})
+		output, err := getAPISpecDiff(root)
+		assert.NoError(t, err)
+		assert.Contains(t, output, "api/openapi.yaml")
+		assert.Contains(t, output, "No uncommitted changes to API spec files.")
+	})
+
+	t.Run("modified spec", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{"swagger.json": "{}\n", "main.go": "package main\n"})
+		assert.NoError(t, os.WriteFile(path.Join(root, "swagger.json"), []byte("{\"paths\": {}}\n"), 0o644))
+		assert.NoError(t, os.WriteFile(path.Join(root, "main.go"), []byte("package lib\n"), 0o644))
+
+		output, err := getAPISpecDiff(root)
+		assert.NoError(t, err)
+		assert.Contains(t, output, "+{\"paths\": {}}")
+		assert.NotContains(t, output, "package lib")
+	})
+}
+
+func TestExtractIssueReferences(t *testing.T) {
+	gitLog := "a1b2c3d\x1fFix login redirect\n\nCloses #12, refs #7\n\x1e\n" +
+		"e4f5a6b\x1fMerge pull request #30 from user/branch\x1e\n" +
+		"c7d8e9f\x1fAdd retries (#12)\n\nSee https://example.com/page#1 and &#39;\x1e\n"
+
+	references := extractIssueReferences(gitLog)
+
+	assert.Equal(t, []issueReference{
+		{number: 12, commit: "a1b2c3d"},
+		{number: 7, commit: "a1b2c3d"},
+		{number: 30, commit: "e4f5a6b"},
+	}, references)
+}
+
+func TestSummarizeBlameAuthors(t *testing.T) {
+	porcelain := "abc 1 1 1\nauthor bob\nauthor-mail <bob@example.com>\n\tline\n" +
+		"abc 2 2\nauthor alice\n\tline\n" +
+		"abc 3 3\nauthor bob\n\tline\n" +
+		"abc 4 4\nauthor carol\n\tline\n"
+
+	assert.Equal(t, "bob (2), alice (1), carol (1)", summarizeBlameAuthors(porcelain))
+	assert.Equal(t, "", summarizeBlameAuthors(""))
+}
+
+func TestGetRecentBlame(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n", "other.go": "package main\n"})
+
+	output, err := getRecentBlame(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "No uncommitted changes to blame.", output)
+
+	assert.NoError(t, os.WriteFile(path.Join(root, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644))
+	output, err = getRecentBlame(root)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "main.go: Not Committed Yet (2), xplane (1)")
+	assert.NotContains(t, output, "other.go")
+}
+
+func TestGetContainerDiff(t *testing.T) {
+	root := initTestRepo(t, map[string]string{
+		"Dockerfile":                "FROM golang:1.24\n",
+		"deploy/docker-compose.yml": "services: {}\n",
+		"main.go":                   "package main\n",
+	})
+
+	output, err := getContainerDiff(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "No uncommitted changes to container config files.", output)
+
+	assert.NoError(t, os.WriteFile(path.Join(root, "Dockerfile"), []byte("FROM golang:1.25\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path.Join(root, "deploy/docker-compose.yml"), []byte("services:\n  app: {}\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path.Join(root, "main.go"), []byte("package lib\n"), 0o644))
+
+	output, err = getContainerDiff(root)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "+FROM golang:1.25")
+	assert.Contains(t, output, "+  app: {}")
+	assert.NotContains(t, output, "package lib")
+}
+
+func TestGetDocsDiff(t *testing.T) {
+	root := initTestRepo(t, map[string]string{
+		"README.md":        "# Title\n",
+		"docs/guide.rst":   "Guide\n",
+		"docs/diagram.svg": "<svg/>\n",
+		"main.go":          "package main\n",
+	})
+
+	output, err := getDocsDiff(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "No uncommitted changes to documentation.", output)
+
+	assert.NoError(t, os.WriteFile(path.Join(root, "README.md"), []byte("# New title\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path.Join(root, "docs/diagram.svg"), []byte("<svg></svg>\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path.Join(root, "main.go"), []byte("package lib\n"), 0o644))
+
+	output, err = getDocsDiff(root)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "+# New title")
+	assert.Contains(t, output, "+<svg></svg>")
+	assert.NotContains(t, output, "package lib")
+
+	// xplane's own knowledge files aren't documentation changes
+	knowledgeRoot := initTestRepo(t, map[string]string{
+		".xplane/KNOWLEDGE.md": "# Knowledge\n",
+		"docs/KNOWLEDGE.md":    "# Knowledge\n",
+	})
+	assert.NoError(t, os.WriteFile(path.Join(knowledgeRoot, ".xplane/KNOWLEDGE.md"), []byte("# Knowledge\n- new entry\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path.Join(knowledgeRoot, "docs/KNOWLEDGE.md"), []byte("# Knowledge\n- new entry\n"), 0o644))
+	output, err = getDocsDiff(knowledgeRoot, "docs/KNOWLEDGE.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "No uncommitted changes to documentation.", output)
+}
+
+func TestGetTestDiff(t *testing.T) {
+	root := initTestRepo(t, map[string]string{
+		"auth.go":             "package auth\n",
+		"auth_test.go":        "package auth\n",
+		"web/login.test.ts":   "test('login')\n",
+		"spec/models_spec.rb": "describe User\n",
+	})
+
+	output, err := getTestDiff(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "No uncommitted changes to test files.", output)
+
+	assert.NoError(t, os.WriteFile(path.Join(root, "auth.go"), []byte("package auth\n\nfunc Login() {}\n"), 0o644))
+	output, err = getTestDiff(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "No uncommitted changes to test files, while 1 other files changed: auth.go", output)
+
+	assert.NoError(t, os.WriteFile(path.Join(root, "session store.go"), []byte("package auth\n"), 0o644))
+	output, err = getTestDiff(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "No uncommitted changes to test files, while 2 other files changed: auth.go, session store.go", output)
+
+	assert.NoError(t, os.WriteFile(path.Join(root, "session_test.go"), []byte("package auth\n"), 0o644))
+	output, err = getTestDiff(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "New untracked test files: session_test.go\n", output)
+
+	assert.NoError(t, os.WriteFile(path.Join(root, "auth_test.go"), []byte("package auth\n\nfunc TestLogin() {}\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path.Join(root, "web/login.test.ts"), []byte("test('logout')\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path.Join(root, "spec/models_spec.rb"), []byte("describe Admin\n"), 0o644))
+	output, err = getTestDiff(root)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "+func TestLogin() {}")
+	assert.Contains(t, output, "+test('logout')")
+	assert.Contains(t, output, "+describe Admin")
+	assert.NotContains(t, output, "func Login()")
+}
+
+func TestGetRerereStatus(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{"main.go": "package main"})
+		output, err := getRerereStatus(root)
+		assert.NoError(t, err)
+		assert.Equal(t, "git rerere is disabled.", output)
+	})
+
+	t.Run("enabled with recorded resolutions", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{"main.go": "package main"})
+		_, err := runCommand(root, "git", "config", "rerere.enabled", "true")
+		assert.NoError(t, err)
+
+		rrCache := path.Join(root, ".git", "rr-cache")
+		older := time.Now().Add(-time.Hour)
+		for _, entry := range []struct {
+			signature string
+			files     []string
+			modTime   time.Time
+		}{
+			{"aaa111", []string{"preimage", "postimage"}, older},
+			{"bbb222", []string{"preimage"}, time.Now()},
+		} {
+			assert.NoError(t, os.MkdirAll(path.Join(rrCache, entry.signature), 0o755))
+			for _, file := range entry.files {
+				filePath := path.Join(rrCache, entry.signature, file)
+				assert.NoError(t, os.WriteFile(filePath, []byte("conflict"), 0o644))
+				assert.NoError(t, os.Chtimes(filePath, entry.modTime, entry.modTime))
+			}
+		}
+
+		output, err := getRerereStatus(root)
+		assert.NoError(t, err)
+		assert.Contains(t, output, "git rerere is enabled.")
+		assert.Less(t, strings.Index(output, "- bbb222 (unresolved"), strings.Index(output, "- aaa111 (resolved"))
+	})
+}
+
+func TestParseSubmoduleStatus(t *testing.T) {
+	output := " 1a2b3c4d5e6f7a8b9c0d1a2b3c4d5e6f7a8b9c0d vendor/lib (v1.2.0)\n" +
+		"+0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f tools/gen (heads/main)\n" +
+		"-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa docs/theme\n"
+
+	assert.Equal(t, []submoduleStatus{
+		{path: "vendor/lib", commit: "1a2b3c4d5e6f7a8b9c0d1a2b3c4d5e6f7a8b9c0d", describe: "v1.2.0", state: "in sync"},
+		{path: "tools/gen", commit: "0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f0f", describe: "heads/main", state: "checked out commit differs from the recorded one"},
+		{path: "docs/theme", commit: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", state: "not initialized"},
+	}, parseSubmoduleStatus(output))
+}
+
+func TestGetGitSubmodulesWithoutSubmodules(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main"})
+	output, err := getGitSubmodules(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "No submodules in this repository.", output)
+}
+
+func TestLooksRewritten(t *testing.T) {
+	tests := []struct {
+		name     string
+		log      string
+		expected bool
+	}{
+		{"in sync", "", false},
+		{"only ahead", "<\x1fAdd feature\n<\x1fFix typo\n", false},
+		{"regular divergence", "<\x1fAdd feature\n>\x1fSomeone else's fix\n", false},
+		{"patch-equivalent commits", "=\x1fAdd feature\n=\x1fAdd feature\n", true},
+		{"amended commit", "<\x1fAdd feature\n>\x1fAdd feature\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, looksRewritten(tt.log))
+		})
+	}
+}
+
+func TestHasRewrittenHistory(t *testing.T) {
+	gitUser := []string{"-c", "user.name=xplane", "-c", "user.email=xplane@example.com"}
+	origin := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+	clone := path.Join(t.TempDir(), "clone")
+	_, err := runCommand(origin, "git", "clone", "-q", origin, clone)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(path.Join(clone, "main.go"), []byte("package lib\n"), 0o644))
+	_, err = runCommand(clone, "git", append(gitUser, "commit", "-qam", "new work")...)
+	assert.NoError(t, err)
+	rewritten, err := hasRewrittenHistory(clone)
+	assert.NoError(t, err)
+	assert.False(t, rewritten, "new commits on top of the remote are not a rewrite")
+
+	// rewriting the commit the remote already has
+	_, err = runCommand(clone, "git", "reset", "-q", "--hard", "HEAD~1")
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path.Join(clone, "main.go"), []byte("package amended\n"), 0o644))
+	_, err = runCommand(clone, "git", append(gitUser, "commit", "-qa", "--amend", "--no-edit")...)
+	assert.NoError(t, err)
+	rewritten, err = hasRewrittenHistory(clone)
+	assert.NoError(t, err)
+	assert.True(t, rewritten)
+}
+
+func TestParseNumstat(t *testing.T) {
+	numstat := "3\t1\tmain.go\n10\t20\tREADME.md\n-\t-\tlogo.png\n2\t2\tgo.mod\n"
+
+	assert.Equal(t, []fileChurn{
+		{path: "README.md", additions: 10, deletions: 20},
+		{path: "go.mod", additions: 2, deletions: 2},
+		{path: "main.go", additions: 3, deletions: 1},
+		{path: "logo.png", binary: true},
+	}, parseNumstat(numstat))
+}
+
+func TestGetDiffSummary(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n", "README.md": "# Title\n"})
+
+	output, err := getDiffSummary(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "No uncommitted changes found.", output)
+
+	assert.NoError(t, os.WriteFile(path.Join(root, "main.go"), []byte("package lib\n\nfunc A() {}\n"), 0o644))
+	output, err = getDiffSummary(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "main.go: +3/-1\nTotal: 1 files, +3/-1\n", output)
+}
+
+func TestGetLicense(t *testing.T) {
+	t.Run("no license file", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{"main.go": "package main"})
+		output, err := getLicense(root)
+		assert.NoError(t, err)
+		assert.Equal(t, "No license file found in this project.", output)
+	})
+
+	t.Run("unchanged license", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{"LICENSE.md": "\n# MIT License\n\nCopyright (c) 2024\n"})
+		output, err := getLicense(root)
+		assert.NoError(t, err)
+		assert.Equal(t, "License file: LICENSE.md\nLicense type: MIT License\nThe license file has no uncommitted changes.", output)
+	})
+
+	t.Run("changed license type", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{"LICENSE": "MIT License\n\nCopyright (c) 2024\n"})
+		assert.NoError(t, os.WriteFile(path.Join(root, "LICENSE"), []byte("Apache License\nVersion 2.0, January 2004\n"), 0o644))
+
+		output, err := getLicense(root)
+		assert.NoError(t, err)
+		assert.Contains(t, output, "License type: Apache License\n")
+		assert.Contains(t, output, "WARNING: the license file has uncommitted changes")
+		assert.Contains(t, output, "The license type changed from 'MIT License' to 'Apache License'.")
+		assert.NotContains(t, output, "Version 2.0")
+	})
+
+	t.Run("staged license change", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{"LICENSE": "MIT License\n\nCopyright (c) 2024\n"})
+		assert.NoError(t, os.WriteFile(path.Join(root, "LICENSE"), []byte("MIT License\n\nCopyright (c) 2025\n"), 0o644))
+		_, err := runCommand(root, "git", "add", "LICENSE")
+		assert.NoError(t, err)
+
+		output, err := getLicense(root)
+		assert.NoError(t, err)
+		assert.Contains(t, output, "WARNING: the license file has uncommitted changes")
+		assert.Contains(t, output, "Staged:\n LICENSE | 2 +-")
+		assert.NotContains(t, output, "Not staged:")
+		assert.NotContains(t, output, "license type changed")
+	})
+
+	t.Run("new license file", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{"main.go": "package main"})
+		assert.NoError(t, os.WriteFile(path.Join(root, "LICENSE"), []byte("MIT License\n"), 0o644))
+
+		output, err := getLicense(root)
+		assert.NoError(t, err)
+		assert.Equal(t, "License file: LICENSE\nLicense type: MIT License\nWARNING: the license file is new and not tracked yet, which may be legally significant.\n", output)
+
+		_, err = runCommand(root, "git", "add", "LICENSE")
+		assert.NoError(t, err)
+		output, err = getLicense(root)
+		assert.NoError(t, err)
+		assert.Contains(t, output, "Staged:\n LICENSE | 1 +")
+		assert.Contains(t, output, "The license file is new, it isn't in the last commit.")
+	})
+}
+
+func TestGetBranchChangedFiles(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n", "auth.go": "package main\n", "README.md": "# Title\n"})
+	for _, args := range [][]string{
+		{"update-ref", "refs/remotes/origin/main", "HEAD"},
+		{"symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/main"},
+	} {
+		_, err := runCommand(root, "git", args...)
+		assert.NoError(t, err)
+	}
+
+	files, err := getBranchChangedFiles(root)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	assert.NoError(t, os.WriteFile(path.Join(root, "auth.go"), []byte("package auth\n"), 0o644))
+	_, err = runCommand(root, "git", "-c", "user.name=xplane", "-c", "user.email=xplane@example.com", "commit", "-qam", "auth")
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path.Join(root, "main.go"), []byte("package lib\n"), 0o644))
+
+	files, err = getBranchChangedFiles(root)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"auth.go", "main.go"}, files)
+}
+
+func TestGetCIConfigDiff(t *testing.T) {
+	root := initTestRepo(t, map[string]string{
+		".github/workflows/ci.yml": "on: push\n",
+		"Makefile":                 "build:\n\tgo build\n",
+		".env.example":             "PORT=8080\n",
+		"main.go":                  "package main\n",
+	})
+
+	output, err := getCIConfigDiff(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "No uncommitted changes to CI and build config files.", output)
+
+	assert.NoError(t, os.WriteFile(path.Join(root, ".github/workflows/ci.yml"), []byte("on: [push, pull_request]\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path.Join(root, ".env.example"), []byte("PORT=8080\nDEPLOY_KEY=\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path.Join(root, "main.go"), []byte("package lib\n"), 0o644))
+
+	output, err = getCIConfigDiff(root)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "+on: [push, pull_request]")
+	assert.Contains(t, output, "+DEPLOY_KEY=")
+	assert.NotContains(t, output, "package lib")
+}
+
+func TestGetGitLogPatches(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+	commit := func(file, content, message string) {
+		assert.NoError(t, os.WriteFile(path.Join(root, file), []byte(content), 0o644))
+		for _, args := range [][]string{
+			{"add", "-A"},
+			{"-c", "user.name=xplane", "-c", "user.email=xplane@example.com", "commit", "-qm", message},
+		} {
+			_, err := runCommand(root, "git", args...)
+			assert.NoError(t, err)
+		}
+	}
+	commit("big.txt", strings.Repeat("line\n", maxCommitPatchLength), "add a big file")
+	commit("main.go", "package lib\n", "rename package")
+
+	output, err := getGitLogPatches(root, 2, "")
+	assert.NoError(t, err)
+
+	renameAt := strings.Index(output, "    rename package")
+	bigAt := strings.Index(output, "    add a big file")
+	assert.True(t, renameAt >= 0 && bigAt > renameAt, "newest commit first")
+	assert.Contains(t, output, "+package lib")
+	assert.Contains(t, output, "[patch truncated,")
+	assert.NotContains(t, output, "initial")
+}
+
+func TestGetPathHistory(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"auth/login.go": "package auth\n", "main.go": "package main\n"})
+	git := func(args ...string) {
+		_, err := runCommand(root, "git", append([]string{"-c", "user.name=xplane", "-c", "user.email=xplane@example.com"}, args...)...)
+		assert.NoError(t, err)
+	}
+	git("mv", "auth/login.go", "auth/session.go")
+	git("commit", "-qm", "rename login to session")
+	assert.NoError(t, os.WriteFile(path.Join(root, "main.go"), []byte("package app\n"), 0o644))
+	git("commit", "-qam", "unrelated change")
+
+	tests := []struct {
+		name        string
+		path        string
+		contains    []string
+		notContains []string
+	}{
+		{"unset", "", []string{"path_history needs XPLANE_HISTORY_PATH"}, nil},
+		{"follows renames", "auth/session.go", []string{"History of 'auth/session.go'", "    rename login to session", "    initial"}, []string{"unrelated change"}},
+		{"directory", "auth", []string{"    rename login to session"}, []string{"unrelated change"}},
+		{"no history", "missing.go", []string{"No commits touch 'missing.go'."}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := getPathHistory(root, tt.path, 5)
+			assert.NoError(t, err)
+			for _, s := range tt.contains {
+				assert.Contains(t, output, s)
+			}
+			for _, s := range tt.notContains {
+				assert.NotContains(t, output, s)
+			}
+		})
+	}
+}
+
+func TestGitLogScopedToAuthor(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+	for _, c := range []struct{ author, file, message string }{
+		{"Ada", "ada.go", "ada's change"},
+		{"Linus", "linus.go", "linus' change"},
+	} {
+		assert.NoError(t, os.WriteFile(path.Join(root, c.file), []byte("package main\n"), 0o644))
+		_, err := runCommand(root, "git", "add", "-A")
+		assert.NoError(t, err)
+		_, err = runCommand(root, "git", "-c", "user.name="+c.author, "-c", "user.email="+strings.ToLower(c.author)+"@example.com", "commit", "-qm", c.message)
+		assert.NoError(t, err)
+	}
+
+	log, err := getGitLog(root, 10, "Ada")
+	assert.NoError(t, err)
+	assert.Contains(t, log, "Only commits by 'Ada'")
+	assert.Contains(t, log, "ada's change")
+	assert.NotContains(t, log, "linus' change")
+
+	patches, err := getGitLogPatches(root, 10, "linus@example.com")
+	assert.NoError(t, err)
+	assert.Contains(t, patches, "linus.go")
+	assert.NotContains(t, patches, "ada.go")
+
+	log, err = getGitLog(root, 10, "nobody")
+	assert.NoError(t, err)
+	assert.Equal(t, "No recent commits by 'nobody'.", log)
+}
+
+func TestFormatTokeiJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expected  string
+		expectErr bool
+	}{
+		{
+			"tokei 12 format",
+			`{"Go": {"blanks": 10, "code": 300, "comments": 20, "reports": [{}, {}], "children": {}, "inaccurate": false},
+			  "Markdown": {"blanks": 5, "code": 0, "comments": 40, "reports": [{}], "children": {}, "inaccurate": false},
+			  "Total": {"blanks": 15, "code": 300, "comments": 60, "reports": [], "children": {}, "inaccurate": false}}`,
+			"Language  Files  Code  Comments  Blanks\nGo        2      300   20        10\nMarkdown  1      0     40        5\nTotal     3      300   60        15\n",
+			false,
+		},
+		{
+			"older format without total",
+			`{"Rust": {"blanks": 1, "code": 50, "comments": 2, "lines": 53, "stats": [{}]}}`,
+			"Language  Files  Code  Comments  Blanks\nRust      1      50    2         1\nTotal     1      50    2         1\n",
+			false,
+		},
+		{"nothing found", `{}`, "No code found by tokei.", false},
+		{"not json", "Language  Files  Lines", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := formatTokeiJSON(tt.input)
+			assert.Equal(t, tt.expectErr, err != nil)
+			assert.Equal(t, tt.expected, output)
+		})
+	}
+}
+
+func TestGetConflictMarkers(t *testing.T) {
+	root := initTestRepo(t, map[string]string{
+		"main.go":   "package main\n",
+		"README.md": "Title\n=======\n",
+	})
+
+	output, err := getConflictMarkers(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "No conflict markers found.", output, "heading underlines aren't markers")
+
+	conflicted := "package main\n<<<<<<< HEAD\nvar x = 1\n=======\nvar x = 2\n>>>>>>> feature\n"
+	assert.NoError(t, os.WriteFile(path.Join(root, "main.go"), []byte(conflicted), 0o644))
+
+	output, err = getConflictMarkers(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "Files with unresolved conflict markers:\n- main.go (lines 2, 4, 6)\n", output)
+}
+
+func TestGetDiffSinceRelease(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+	commit := func(content, message string) {
+		assert.NoError(t, os.WriteFile(path.Join(root, "main.go"), []byte(content), 0o644))
+		_, err := runCommand(root, "git", "-c", "user.name=xplane", "-c", "user.email=xplane@example.com", "commit", "-qam", message)
+		assert.NoError(t, err)
+	}
+
+	output, err := getDiffSinceRelease(root, defaultDiffBudget)
+	assert.NoError(t, err)
+	assert.Equal(t, "No release tags found, nothing to compare against.", output)
+
+	_, err = runCommand(root, "git", "tag", "v1.0.0")
+	assert.NoError(t, err)
+	output, err = getDiffSinceRelease(root, defaultDiffBudget)
+	assert.NoError(t, err)
+	assert.Equal(t, "No changes since the latest release 'v1.0.0'.", output)
+
+	commit("package main\n\nfunc main() {}\n", "add main")
+	commit("package main\n\nfunc main() { println(\"hi\") }\n", "say hi")
+	output, err = getDiffSinceRelease(root, defaultDiffBudget)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Changes since the latest release 'v1.0.0' (2 commits):")
+	assert.Contains(t, output, "main.go | ")
+	assert.Contains(t, output, `+func main() { println("hi") }`)
+
+	output, err = getDiffSinceRelease(root, 10)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "[diff truncated,")
+}
+
+func TestGetBranchDiff(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+	_, err := runCommand(root, "git", "branch", "-M", "main")
+	assert.NoError(t, err)
+
+	output, err := getBranchDiff(root, "", defaultDiffBudget)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Could not find the branch to compare against", "no origin/HEAD in a fresh repo")
+
+	_, err = runCommand(root, "git", "checkout", "-q", "-b", "feature")
+	assert.NoError(t, err)
+	output, err = getBranchDiff(root, "main", defaultDiffBudget)
+	assert.NoError(t, err)
+	assert.Equal(t, "No commits on the current branch since it forked off 'refs/heads/main'.", output)
+
+	assert.NoError(t, os.WriteFile(path.Join(root, "feature.go"), []byte("package main\n\nfunc feature() {}\n"), 0o644))
+	_, err = runCommand(root, "git", "add", "-A")
+	assert.NoError(t, err)
+	_, err = runCommand(root, "git", "-c", "user.name=xplane", "-c", "user.email=xplane@example.com", "commit", "-qm", "add feature")
+	assert.NoError(t, err)
+	// uncommitted work isn't part of the branch diff
+	assert.NoError(t, os.WriteFile(path.Join(root, "main.go"), []byte("package main\n\n// wip\n"), 0o644))
+
+	output, err = getBranchDiff(root, "main", defaultDiffBudget)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "since it forked off 'refs/heads/main' at ")
+	assert.Contains(t, output, "(1 commits):")
+	assert.Contains(t, output, "+func feature() {}")
+	assert.NotContains(t, output, "// wip")
+}
+
+func TestGetReverts(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"cache.go": "package main\n"})
+	git := func(args ...string) string {
+		output, err := runCommand(root, "git", append([]string{"-c", "user.name=xplane", "-c", "user.email=xplane@example.com"}, args...)...)
+		assert.NoError(t, err)
+		return strings.TrimSpace(output)
+	}
+
+	output, err := getReverts(root, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "No recent reverts.", output)
+
+	assert.NoError(t, os.WriteFile(path.Join(root, "cache.go"), []byte("package main\n// caching\n"), 0o644))
+	git("commit", "-qam", "Add caching")
+	reverted := git("rev-parse", "--short=7", "HEAD")
+	git("revert", "--no-edit", "HEAD")
+
+	output, err = getReverts(root, 10)
+	assert.NoError(t, err)
+	assert.Contains(t, output, `Revert "Add caching" (xplane, `)
+	assert.Contains(t, output, ", reverts "+reverted+"\n")
+	assert.Equal(t, 1, strings.Count(output, "\n"))
+}
+
+func TestXplaneDirExcludedFromDiff(t *testing.T) {
+	root := initTestRepo(t, map[string]string{
+		"main.go":              "package main\n",
+		".xplane/KNOWLEDGE.md": "# Knowledge\n",
+	})
+	assert.NoError(t, os.WriteFile(path.Join(root, "main.go"), []byte("package main\n// changed\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path.Join(root, ".xplane/KNOWLEDGE.md"), []byte("# Knowledge\nupdated\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path.Join(root, ".xplane/dynamic_context.txt"), []byte("stored\n"), 0o644))
+
+	status, err := getGitStatus(root)
+	assert.NoError(t, err)
+	assert.Equal(t, " M main.go\n", status)
+
+	diff, err := getGitDiff(root)
+	assert.NoError(t, err)
+	assert.Contains(t, diff, "main.go")
+	assert.NotContains(t, diff, "KNOWLEDGE.md")
+
+	summary, err := getDiffSummary(root)
+	assert.NoError(t, err)
+	assert.NotContains(t, summary, ".xplane")
+}
+
+func TestTruncateAtRune(t *testing.T) {
+	assert.Equal(t, "short", truncateAtRune("short", 10))
+	assert.Equal(t, "caf", truncateAtRune("café", 4), "é takes two bytes, it's not split")
+	assert.Equal(t, "café", truncateAtRune("café!", 5))
+	assert.Equal(t, "", truncateAtRune("日本", 2))
+}
@@ -0,0 +1,53 @@
+package xplane
+
+import (
+	"fmt"
+	"strings"
+)
+
+const condensePrompt = `You are preparing project context for another model that will compare two snapshots of the same project.
+
+Condense the context below. Keep every concrete fact that could matter in that comparison: file names, versions, counts, branch names, PR/issue titles and numbers, authors, errors and warnings. Drop boilerplate, repetition and anything that can't change between snapshots. Keep the per-command structure, one short section per command.
+
+Answer with the condensed context only, no preamble.
+
+--- CONTEXT ---
+{{CONTEXT}}`
+
+// the first phase model, same provider as the final one but running XPLANE_CONDENSE_MODEL
+func pickCondenseLLM(cfg *Config) (LLMProvider, error) {
+	condenseCfg := *cfg
+	condenseCfg.Model = cfg.CondenseModel
+	return pickLLM(&condenseCfg)
+}
+
+// shrinks both contexts with the cheaper model so the final model only sees the condensed versions,
+// identical contexts are only condensed once
+func condenseContexts(condenser LLMProvider, previous, current string) (string, string, error) {
+	condensedCurrent, err := condenser.summarizeContext(strings.ReplaceAll(condensePrompt, "{{CONTEXT}}", current))
+	if err != nil {
+		return "", "", fmt.Errorf("could not condense the current context: %w", err)
+	}
+	if previous == current {
+		return condensedCurrent, condensedCurrent, nil
+	}
+
+	condensedPrevious, err := condenser.summarizeContext(strings.ReplaceAll(condensePrompt, "{{CONTEXT}}", previous))
+	if err != nil {
+		return "", "", fmt.Errorf("could not condense the previous context: %w", err)
+	}
+	return condensedPrevious, condensedCurrent, nil
+}
+
+// runs the condensing phase when XPLANE_CONDENSE_MODEL is set, otherwise hands the contexts back untouched
+func maybeCondenseContexts(cfg *Config, previous, current string) (string, string, error) {
+	if cfg.CondenseModel == "" {
+		return previous, current, nil
+	}
+	condenser, err := pickCondenseLLM(cfg)
+	if err != nil {
+		return "", "", fmt.Errorf("could not load the condense model: %w", err)
+	}
+	fmt.Printf(cfg.msg(MsgCondensingContext), condenser.getName(), condenser.getModel())
+	return condenseContexts(condenser, previous, current)
+}
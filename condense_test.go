@@ -0,0 +1,34 @@
+package xplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCondenseContexts(t *testing.T) {
+	t.Run("both contexts are condensed", func(t *testing.T) {
+		condenser := &fakeLLM{}
+		previous, current, err := condenseContexts(condenser, "old context", "new context")
+		assert.NoError(t, err)
+		assert.Equal(t, "summary", previous)
+		assert.Equal(t, "summary", current)
+		assert.Len(t, condenser.prompts, 2)
+		assert.Contains(t, condenser.prompts[0], "--- CONTEXT ---\nnew context")
+		assert.Contains(t, condenser.prompts[1], "--- CONTEXT ---\nold context")
+	})
+
+	t.Run("identical contexts are condensed once", func(t *testing.T) {
+		condenser := &fakeLLM{}
+		_, _, err := condenseContexts(condenser, "same", "same")
+		assert.NoError(t, err)
+		assert.Len(t, condenser.prompts, 1)
+	})
+}
+
+func TestMaybeCondenseContextsWithoutModel(t *testing.T) {
+	previous, current, err := maybeCondenseContexts(&Config{}, "old", "new")
+	assert.NoError(t, err)
+	assert.Equal(t, "old", previous)
+	assert.Equal(t, "new", current)
+}
@@ -1,27 +1,91 @@
-package main
+package xplane
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
+	"maps"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 )
 
 const defaultCommands = "git_status,git_log,readme,git_exclude,gitignore,git_diff,github_prs,gitlab_mrs,release,git_branch_status,tokei,ripsecrets"
 
 var specialCommandToBinMap = map[string]string{
-	"git_status":        "git",
-	"git_log":           "git",
-	"git_exclude":       "",
-	"gitignore":         "",
-	"git_diff":          "git",
-	"tokei":             "tokei",
-	"ripsecrets":        "ripsecrets",
-	"github_prs":        "",
-	"gitlab_mrs":        "",
-	"git_branch_status": "",
-	"release":           "",
-	"readme":            "",
+	"git_status":         "git",
+	"git_log":            "git",
+	"git_log_patches":    "git",
+	"git_exclude":        "",
+	"gitignore":          "",
+	"git_diff":           "git",
+	"tokei":              "tokei",
+	"ripsecrets":         "ripsecrets",
+	"github_prs":         "",
+	"gitlab_mrs":         "",
+	"git_branch_status":  "",
+	"release":            "",
+	"readme":             "",
+	"api_spec_diff":      "git",
+	"shipped_issues":     "git",
+	"recent_blame":       "git",
+	"container_diff":     "git",
+	"ci_config_diff":     "git",
+	"coverage":           "",
+	"build_size":         "",
+	"merge_status":       "git",
+	"rerere_status":      "git",
+	"github_discussions": "",
+	"git_submodules":     "git",
+	"docs_diff":          "git",
+	"test_diff":          "git",
+	"diff_summary":       "git",
+	"license":            "git",
+	"pr_overlap":         "git",
+	"stale_branches":     "",
+	"conflict_markers":   "git",
+	"mergeable_prs":      "",
+	"recent_releases":    "",
+	"diff_since_release": "git",
+	"branch_diff":        "git",
+	"reverts":            "git",
+	"path_history":       "git",
+}
+
+// commands that need a remote git provider to be initialized
+var gitProviderCommands = map[string]bool{
+	"github_prs":         true,
+	"gitlab_mrs":         true,
+	"release":            true,
+	"git_branch_status":  true,
+	"shipped_issues":     true,
+	"merge_status":       true,
+	"github_discussions": true,
+	"pr_overlap":         true,
+	"stale_branches":     true,
+	"mergeable_prs":      true,
+	"recent_releases":    true,
+}
+
+// every environment variable LoadConfig reads, as reported by GetCapabilities
+var recognizedEnvVars = []string{
+	"XPLANE_PROVIDER", "XPLANE_MODEL", "XPLANE_MODEL_ALIASES", "XPLANE_API_KEY", "XPLANE_COMMANDS", "XPLANE_COMMAND_ALIASES",
+	"GITHUB_TOKEN", "GITLAB_TOKEN", "OLLAMA_HOST", "XPLANE_OLLAMA_ENDPOINT", "XPLANE_OLLAMA_OPTIONS", "XPLANE_OLLAMA_KEEP_ALIVE",
+	"XPLANE_MODEL_PARAMS",
+	"USE_PROJECT_KNOWLEDGE", "XPLANE_KNOWLEDGE_PROVENANCE", "XPLANE_KNOWLEDGE_MAX_ENTRIES", "XPLANE_COMMIT_KNOWLEDGE", "XPLANE_KNOWLEDGE_FILE",
+	"XPLANE_COMPACT_CONTEXT", "XPLANE_CONTEXT_FORMAT", "XPLANE_CONDENSE_MODEL", "XPLANE_OUTPUT_FORMAT", "XPLANE_STREAM",
+	"XPLANE_PROGRESS", "XPLANE_ANONYMIZE_AUTHORS", "XPLANE_LANGUAGE", "XPLANE_PROMPT_PREFIX", "XPLANE_PROMPT_SUFFIX", "XPLANE_SAVE_PROMPT",
+	"XPLANE_REMOTE_CACHE_TTL", "XPLANE_MAX_RUNTIME", "XPLANE_GROUP_PRS_BY_LABEL", "XPLANE_GROUP_PRS_BY_BRANCH", "XPLANE_PRS_BY_AUTHOR", "XPLANE_PR_TEMPLATE", "XPLANE_RELEASE_TEMPLATE", "XPLANE_PR_CI_STATUS", "XPLANE_PR_THREADS", "XPLANE_PR_INTENT",
+	"XPLANE_SUMMARIZE_FIRST_RUN", "XPLANE_PER_FILE_DIFF_SUMMARY", "XPLANE_DIFF_BUDGET", "XPLANE_COMPARE_BRANCH", "XPLANE_OMIT_IDENTICAL_BRANCH_STATUS",
+	"XPLANE_STALE_BRANCH_DAYS", "XPLANE_STALE_PR_DAYS", "XPLANE_BUILD_CMD", "XPLANE_BUILD_ARTIFACT", "XPLANE_INCLUDE_FILES", "XPLANE_PREFETCH", "XPLANE_AUTHOR",
+	"XPLANE_HISTORY_PATH",
+	"XPLANE_COMPRESS_CONTEXT", "XPLANE_CHECK_UPDATES", "XPLANE_USER_AGENT", "XPLANE_ASCII_ONLY", "XPLANE_ADVANCE_ON_FAILURE",
 }
 
 type Config struct {
@@ -32,7 +96,129 @@ type Config struct {
 	APIKey              string
 	Model               string
 	OllamaServerAddress string
+	OllamaEndpoint      string
+	OllamaOptions       map[string]any
+	OllamaKeepAlive     any            // see parseOllamaKeepAlive
+	ModelParams         map[string]any // generic generation params, each provider maps them to its own format
 	UseProjectKnowledge bool
+	KnowledgeProvenance bool
+	CommitKnowledge     bool
+	KnowledgeMaxEntries int    // zero keeps the whole timeline
+	KnowledgeFile       string // relative to the git root, empty means .xplane/KNOWLEDGE.md
+	CompactContext      bool
+	ForceSummary        bool
+	PostComment         bool
+	CopyToClipboard     bool
+	ShowDiff            bool // print the uncommitted diff, highlighted, after the summary
+	Explain             bool // describe each configured command before running them
+	ShowProgress        bool
+	StreamSummary       bool
+	AnonymizeAuthors    bool
+	Language            string // the natural language summaries are written in, empty leaves it to the model
+	PromptPrefix        string
+	PromptSuffix        string
+	RemoteCacheTTL      time.Duration // zero disables caching of remote commands
+	MaxRuntime          time.Duration // commands not started by then are skipped, zero means no limit
+	RefreshCache        bool
+	GroupPRsByLabel     bool
+	GroupPRsByBranch    bool
+	PRsByAuthor         bool
+	PRTemplate          *template.Template // XPLANE_PR_TEMPLATE, nil keeps PullRequest.Format()
+	ReleaseTemplate     *template.Template // XPLANE_RELEASE_TEMPLATE, nil keeps Release.Format()
+	PRCIStatus          bool
+	PRThreads           bool
+	IncludePRIntent     bool
+	SummarizeFirstRun   bool
+	AdvanceOnFailure    bool   // store the new context even when the summary failed, skipping those changes
+	ContextFormat       string // one of contextFormats, empty means the default
+	CondenseModel       string // optional cheaper model that condenses the contexts before the final summary
+	SavePrompt          bool
+	PerFileDiffSummary  bool
+	DiffBudget          int                 // zero means defaultDiffBudget
+	StaleBranchDays     int                 // zero means defaultStaleBranchDays
+	StalePRDays         int                 // zero turns off the stale PRs section of github_prs/gitlab_mrs
+	CompareBranch       string              // git_branch_status base, empty means the remote default branch
+	OmitIdenticalBranch bool                // leave git_branch_status out when the branch is identical to its base
+	IncludeFiles        []string            // paths relative to the git root, each added as a 'file:<path>' block
+	BuildCmd            string              // run by build_size, split into arguments like the aliases
+	BuildArtifact       string              // file or directory measured by build_size, relative to the git root
+	Prefetch            bool                // git fetch --prune before gathering
+	CommandAliases      map[string]string   // friendly name -> full command line, usable in XPLANE_COMMANDS
+	ASCIIOnly           bool                // plain ASCII instead of glyphs, for terminals without the fonts
+	CheckUpdates        bool                // notify about newer xplane releases, checked at most once a day
+	UserAgent           string              // sent on every API call, empty means xplane/<version>
+	CompressContext     bool                // stores dynamic_context.txt gzipped
+	Author              string              // scopes git_log and git_log_patches to one author's commits
+	HistoryPath         string              // file or directory followed by path_history, relative to the git root
+	OnProgress          func(ProgressEvent) // for embedders, nil prints the usual progress messages
+	OutputFormat        string              // one of summaryRenderers, empty means the default
+	Renderer            SummaryRenderer     // takes precedence over OutputFormat, for library users with their own renderer
+}
+
+const defaultStaleBranchDays = 90
+
+func (c *Config) staleBranchDays() int {
+	if c.StaleBranchDays > 0 {
+		return c.StaleBranchDays
+	}
+	return defaultStaleBranchDays
+}
+
+func (c *Config) diffBudget() int {
+	if c.DiffBudget > 0 {
+		return c.DiffBudget
+	}
+	return defaultDiffBudget
+}
+
+// where the project knowledge lives, relative to the git root
+func (c *Config) knowledgeFilePath() string {
+	if c.KnowledgeFile != "" {
+		return c.KnowledgeFile
+	}
+	return filepath.Join(contextDir, knowledgeFile)
+}
+
+// files xplane writes to outside of .xplane/, left out of the diffs so a run doesn't summarize the previous one's output
+func (c *Config) ownFilesOutsideContextDir() []string {
+	knowledgePath := c.knowledgeFilePath()
+	if !c.UseProjectKnowledge || strings.HasPrefix(knowledgePath, contextDir+string(filepath.Separator)) {
+		return nil
+	}
+	return []string{knowledgePath}
+}
+
+// the User-Agent of the github, gitlab and ollama calls, so api gateways can tell xplane's traffic apart
+func (c *Config) userAgent() string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+	version := buildVersion()
+	if version == "" {
+		version = "dev"
+	}
+	return "xplane/" + version
+}
+
+func (c *Config) summaryRenderer() SummaryRenderer {
+	if c.Renderer != nil {
+		return c.Renderer
+	}
+	renderer, ok := summaryRenderers[c.OutputFormat]
+	if !ok {
+		renderer = summaryRenderers[defaultOutputFormat]
+	}
+	if _, isGlamour := renderer.(glamourRenderer); isGlamour && c.ASCIIOnly {
+		return glamourRenderer{ascii: true}
+	}
+	return renderer
+}
+
+func (c *Config) contextFormat() contextFormat {
+	if format, ok := contextFormats[c.ContextFormat]; ok {
+		return format
+	}
+	return contextFormats[defaultContextFormat]
 }
 
 func ensureBinaryInstalled(bin string) error {
@@ -48,7 +234,169 @@ func ensureBinaryInstalled(bin string) error {
 	return nil
 }
 
-func loadConfig() (*Config, error) {
+// reads one command per line from .xplane/commands.txt, skipping blank lines and '#' comments
+func readCommandsFile(gitRoot string) ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(gitRoot, contextDir, commandsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", commandsFile, err)
+	}
+
+	var commands []string
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmedLine := strings.TrimSpace(line)
+		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "#") {
+			continue
+		}
+		commands = append(commands, trimmedLine)
+	}
+	return commands, nil
+}
+
+// XPLANE_COMMANDS wins over .xplane/commands.txt, which in turn wins over the defaults
+func resolveCommands() ([]string, error) {
+	if commandsStr := os.Getenv("XPLANE_COMMANDS"); commandsStr != "" {
+		return strings.Split(commandsStr, ","), nil
+	}
+
+	if gitRoot, err := findGitRoot(); err == nil {
+		fileCommands, err := readCommandsFile(gitRoot)
+		if err != nil {
+			return nil, err
+		}
+		if len(fileCommands) > 0 {
+			return fileCommands, nil
+		}
+	}
+
+	return strings.Split(defaultCommands, ","), nil
+}
+
+// turns user provided server addresses like 'localhost:11434' or 'http://host:11434/' into 'http://host:11434'
+func normalizeServerAddress(raw string) (string, error) {
+	address := strings.TrimSpace(raw)
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	address = strings.TrimRight(address, "/")
+
+	parsed, err := url.Parse(address)
+	if err != nil {
+		return "", fmt.Errorf("could not parse server address '%s': %w", raw, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("server address '%s' must use http or https", raw)
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("server address '%s' has no host", raw)
+	}
+	return address, nil
+}
+
+// the server address and the api path are joined as is, so 'http://host:11434/api' with '/api/chat' or an
+// endpoint with a trailing slash would make urls like '.../api/api/chat'. the '/api' goes with the endpoint
+func normalizeOllamaEndpoint(serverAddress, endpoint string) (string, string) {
+	serverAddress = strings.TrimRight(serverAddress, "/")
+	serverAddress = strings.TrimRight(strings.TrimSuffix(serverAddress, "/api"), "/")
+	endpoint = strings.Trim(strings.TrimSpace(endpoint), "/")
+	if endpoint == "" {
+		return serverAddress, defaultOllamaEndpoint
+	}
+	return serverAddress, "/" + endpoint
+}
+
+// parses XPLANE_MODEL_ALIASES, e.g. "fast=gemini-2.5-flash,best=claude-opus-4"
+func parseModelAliases(raw string) (map[string]string, error) {
+	return parseAliases(raw, "model")
+}
+
+// parses XPLANE_COMMAND_ALIASES, e.g. "lint=golangci-lint run,todos=git grep -n 'TODO\|FIXME'"
+func parseCommandAliases(raw string) (map[string]string, error) {
+	aliases, err := parseAliases(raw, "command")
+	if err != nil {
+		return nil, err
+	}
+	for alias, expansion := range aliases {
+		// a built-in would silently win in gatherContext
+		if _, isSpecial := specialCommandToBinMap[alias]; isSpecial {
+			return nil, fmt.Errorf("alias '%s' is the name of a built-in command", alias)
+		}
+		if _, err := splitCommandLine(expansion); err != nil {
+			return nil, fmt.Errorf("alias '%s': %w", alias, err)
+		}
+	}
+	return aliases, nil
+}
+
+// comma-separated alias=value pairs, only the first = splits so values can contain more.
+// a comma inside quotes or escaped with a backslash belongs to the value
+func parseAliases(raw, valueName string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for _, entry := range splitOutsideQuotes(raw, ',') {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		alias, value, found := strings.Cut(entry, "=")
+		alias, value = strings.TrimSpace(alias), strings.TrimSpace(value)
+		if !found || alias == "" || value == "" {
+			return nil, fmt.Errorf("expected alias=%s, got '%s'", valueName, strings.TrimSpace(entry))
+		}
+		aliases[alias] = value
+	}
+	return aliases, nil
+}
+
+// unknown names are taken as literal model names
+func resolveModelAlias(aliases map[string]string, model string) string {
+	if resolved, ok := aliases[model]; ok {
+		return resolved
+	}
+	return model
+}
+
+// the --set key of an environment variable, its name lowercased without the XPLANE_ prefix, e.g. 'provider'
+func overrideKey(envVar string) string {
+	return strings.ToLower(strings.TrimPrefix(envVar, "XPLANE_"))
+}
+
+// ApplyOverrides takes the 'key=value' pairs of --set and sets the matching environment variables,
+// so the values go through LoadConfig's defaults and validation like any other setting and win over the env
+func ApplyOverrides(overrides []string) error {
+	envVarsByKey := make(map[string]string, len(recognizedEnvVars))
+	for _, envVar := range recognizedEnvVars {
+		envVarsByKey[overrideKey(envVar)] = envVar
+	}
+
+	for _, override := range overrides {
+		key, value, found := strings.Cut(override, "=")
+		if !found {
+			return fmt.Errorf("invalid override '%s', expected key=value, e.g. 'provider=ollama'", override)
+		}
+		key = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "-", "_")
+		envVar, ok := envVarsByKey[key]
+		if !ok {
+			keys := slices.Sorted(maps.Keys(envVarsByKey))
+			return fmt.Errorf("unknown override key '%s', expected one of: %s", key, strings.Join(keys, ", "))
+		}
+		if err := os.Setenv(envVar, value); err != nil {
+			return fmt.Errorf("could not apply override '%s': %w", key, err)
+		}
+	}
+	return nil
+}
+
+func LoadConfig() (*Config, error) {
+	if err := ensureGitInstalled(); err != nil {
+		return nil, err
+	}
+
+	if gitRoot, err := findGitRoot(); err == nil {
+		if err := loadDotEnvFiles(gitRoot); err != nil {
+			return nil, err
+		}
+	}
+
 	cfg := &Config{
 		GithubToken:         os.Getenv("GITHUB_TOKEN"),
 		GitlabToken:         os.Getenv("GITLAB_TOKEN"),
@@ -56,13 +404,160 @@ func loadConfig() (*Config, error) {
 		APIKey:              os.Getenv("XPLANE_API_KEY"),
 		Model:               os.Getenv("XPLANE_MODEL"),
 		OllamaServerAddress: os.Getenv("OLLAMA_HOST"),
+		OllamaEndpoint:      os.Getenv("XPLANE_OLLAMA_ENDPOINT"),
 		UseProjectKnowledge: os.Getenv("USE_PROJECT_KNOWLEDGE") == "true",
+		KnowledgeProvenance: os.Getenv("XPLANE_KNOWLEDGE_PROVENANCE") == "true",
+		CommitKnowledge:     os.Getenv("XPLANE_COMMIT_KNOWLEDGE") == "true",
+		CompactContext:      os.Getenv("XPLANE_COMPACT_CONTEXT") == "true",
+		ShowProgress:        os.Getenv("XPLANE_PROGRESS") != "false",
+		StreamSummary:       os.Getenv("XPLANE_STREAM") == "true",
+		AnonymizeAuthors:    os.Getenv("XPLANE_ANONYMIZE_AUTHORS") == "true",
+		PromptPrefix:        os.Getenv("XPLANE_PROMPT_PREFIX"),
+		PromptSuffix:        os.Getenv("XPLANE_PROMPT_SUFFIX"),
+		Language:            strings.TrimSpace(os.Getenv("XPLANE_LANGUAGE")),
+		GroupPRsByLabel:     os.Getenv("XPLANE_GROUP_PRS_BY_LABEL") == "true",
+		GroupPRsByBranch:    os.Getenv("XPLANE_GROUP_PRS_BY_BRANCH") == "true",
+		PRsByAuthor:         os.Getenv("XPLANE_PRS_BY_AUTHOR") == "true",
+		PRCIStatus:          os.Getenv("XPLANE_PR_CI_STATUS") == "true",
+		PRThreads:           os.Getenv("XPLANE_PR_THREADS") == "true",
+		IncludePRIntent:     os.Getenv("XPLANE_PR_INTENT") == "true",
+		SummarizeFirstRun:   os.Getenv("XPLANE_SUMMARIZE_FIRST_RUN") == "true",
+		AdvanceOnFailure:    os.Getenv("XPLANE_ADVANCE_ON_FAILURE") == "true",
+		ContextFormat:       os.Getenv("XPLANE_CONTEXT_FORMAT"),
+		CondenseModel:       os.Getenv("XPLANE_CONDENSE_MODEL"),
+		SavePrompt:          os.Getenv("XPLANE_SAVE_PROMPT") == "true",
+		PerFileDiffSummary:  os.Getenv("XPLANE_PER_FILE_DIFF_SUMMARY") == "true",
+		CompareBranch:       strings.TrimSpace(os.Getenv("XPLANE_COMPARE_BRANCH")),
+		BuildCmd:            strings.TrimSpace(os.Getenv("XPLANE_BUILD_CMD")),
+		BuildArtifact:       strings.TrimSpace(os.Getenv("XPLANE_BUILD_ARTIFACT")),
+		OmitIdenticalBranch: os.Getenv("XPLANE_OMIT_IDENTICAL_BRANCH_STATUS") == "true",
+		Prefetch:            os.Getenv("XPLANE_PREFETCH") == "true",
+		Author:              strings.TrimSpace(os.Getenv("XPLANE_AUTHOR")),
+		HistoryPath:         strings.TrimSpace(os.Getenv("XPLANE_HISTORY_PATH")),
+		CompressContext:     os.Getenv("XPLANE_COMPRESS_CONTEXT") == "true",
+		CheckUpdates:        os.Getenv("XPLANE_CHECK_UPDATES") == "true",
+		UserAgent:           strings.TrimSpace(os.Getenv("XPLANE_USER_AGENT")),
+		ASCIIOnly:           detectASCIIOnly(os.Getenv("XPLANE_ASCII_ONLY")),
+		OutputFormat:        os.Getenv("XPLANE_OUTPUT_FORMAT"),
 	}
 
 	if cfg.Provider == "" {
 		cfg.Provider = "gemini_cli"
 	}
 
+	if maxEntriesStr := os.Getenv("XPLANE_KNOWLEDGE_MAX_ENTRIES"); maxEntriesStr != "" {
+		maxEntries, err := strconv.Atoi(maxEntriesStr)
+		if err != nil || maxEntries < 0 {
+			return nil, fmt.Errorf("XPLANE_KNOWLEDGE_MAX_ENTRIES must be a non-negative number, got '%s'", maxEntriesStr)
+		}
+		cfg.KnowledgeMaxEntries = maxEntries
+	}
+
+	if knowledgeFile := strings.TrimSpace(os.Getenv("XPLANE_KNOWLEDGE_FILE")); knowledgeFile != "" {
+		// it gets committed with XPLANE_COMMIT_KNOWLEDGE, so it has to live inside the repo
+		if !filepath.IsLocal(knowledgeFile) {
+			return nil, fmt.Errorf("XPLANE_KNOWLEDGE_FILE must be a path inside the repository, relative to its root, got '%s'", knowledgeFile)
+		}
+		cfg.KnowledgeFile = filepath.Clean(knowledgeFile)
+	}
+
+	for _, path := range strings.Split(os.Getenv("XPLANE_INCLUDE_FILES"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			cfg.IncludeFiles = append(cfg.IncludeFiles, path)
+		}
+	}
+
+	if daysStr := os.Getenv("XPLANE_STALE_BRANCH_DAYS"); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("XPLANE_STALE_BRANCH_DAYS must be a positive number of days, got '%s'", daysStr)
+		}
+		cfg.StaleBranchDays = days
+	}
+
+	if daysStr := os.Getenv("XPLANE_STALE_PR_DAYS"); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("XPLANE_STALE_PR_DAYS must be a positive number of days, got '%s'", daysStr)
+		}
+		cfg.StalePRDays = days
+	}
+
+	if budgetStr := os.Getenv("XPLANE_DIFF_BUDGET"); budgetStr != "" {
+		budget, err := strconv.Atoi(budgetStr)
+		if err != nil || budget <= 0 {
+			return nil, fmt.Errorf("XPLANE_DIFF_BUDGET must be a positive number of characters, got '%s'", budgetStr)
+		}
+		cfg.DiffBudget = budget
+	}
+
+	modelAliases, err := parseModelAliases(os.Getenv("XPLANE_MODEL_ALIASES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid XPLANE_MODEL_ALIASES: %w", err)
+	}
+	cfg.Model = resolveModelAlias(modelAliases, cfg.Model)
+	cfg.CondenseModel = resolveModelAlias(modelAliases, cfg.CondenseModel)
+
+	if cfg.CommandAliases, err = parseCommandAliases(os.Getenv("XPLANE_COMMAND_ALIASES")); err != nil {
+		return nil, fmt.Errorf("invalid XPLANE_COMMAND_ALIASES: %w", err)
+	}
+	if cfg.BuildCmd != "" {
+		if _, err := splitCommandLine(cfg.BuildCmd); err != nil {
+			return nil, fmt.Errorf("invalid XPLANE_BUILD_CMD: %w", err)
+		}
+	}
+
+	cfg.RemoteCacheTTL = defaultRemoteCacheTTL
+	if ttlStr := os.Getenv("XPLANE_REMOTE_CACHE_TTL"); ttlStr != "" {
+		ttl, err := time.ParseDuration(ttlStr)
+		if err != nil {
+			return nil, fmt.Errorf("XPLANE_REMOTE_CACHE_TTL must be a duration like '5m' or '0' to disable: %w", err)
+		}
+		cfg.RemoteCacheTTL = ttl
+	}
+
+	if runtimeStr := os.Getenv("XPLANE_MAX_RUNTIME"); runtimeStr != "" {
+		runtime, err := time.ParseDuration(runtimeStr)
+		if err != nil || runtime < 0 {
+			return nil, fmt.Errorf("XPLANE_MAX_RUNTIME must be a duration like '2m', got '%s'", runtimeStr)
+		}
+		cfg.MaxRuntime = runtime
+	}
+
+	if paramsStr := os.Getenv("XPLANE_MODEL_PARAMS"); paramsStr != "" {
+		if err := json.Unmarshal([]byte(paramsStr), &cfg.ModelParams); err != nil {
+			return nil, fmt.Errorf("XPLANE_MODEL_PARAMS must be a JSON object, e.g. '{\"temperature\": 0.2, \"top_p\": 0.9}': %w", err)
+		}
+		if cfg.Provider != "ollama" {
+			log.Printf("Warning: XPLANE_MODEL_PARAMS is only applied by the 'ollama' provider, '%s' ignores it", cfg.Provider)
+		}
+	}
+
+	if cfg.GroupPRsByBranch && cfg.GroupPRsByLabel {
+		log.Printf("Warning: XPLANE_GROUP_PRS_BY_BRANCH and XPLANE_GROUP_PRS_BY_LABEL are both set, PRs are only grouped by branch")
+	}
+
+	if cfg.PRTemplate, err = parseEntityTemplate("XPLANE_PR_TEMPLATE", os.Getenv("XPLANE_PR_TEMPLATE")); err != nil {
+		return nil, fmt.Errorf("invalid XPLANE_PR_TEMPLATE: %w", err)
+	}
+	if cfg.ReleaseTemplate, err = parseEntityTemplate("XPLANE_RELEASE_TEMPLATE", os.Getenv("XPLANE_RELEASE_TEMPLATE")); err != nil {
+		return nil, fmt.Errorf("invalid XPLANE_RELEASE_TEMPLATE: %w", err)
+	}
+
+	if cfg.ContextFormat == "" {
+		cfg.ContextFormat = defaultContextFormat
+	}
+	if _, ok := contextFormats[cfg.ContextFormat]; !ok {
+		return nil, fmt.Errorf("unknown XPLANE_CONTEXT_FORMAT '%s', expected 'plain', 'markdown' or 'xml'", cfg.ContextFormat)
+	}
+
+	if cfg.OutputFormat == "" {
+		cfg.OutputFormat = defaultOutputFormat
+	}
+	if _, ok := summaryRenderers[cfg.OutputFormat]; !ok {
+		return nil, fmt.Errorf("unknown XPLANE_OUTPUT_FORMAT '%s', expected 'glamour', 'plain', 'json' or 'html'", cfg.OutputFormat)
+	}
+
 	if cfg.Model == "" && cfg.Provider == "gemini_cli" {
 		cfg.Model = "gemini-2.5-pro"
 	}
@@ -76,16 +571,30 @@ func loadConfig() (*Config, error) {
 			fmt.Println("No 'OLLAMA_HOST' provided, defaulting to 'http://localhost:11434'...")
 			cfg.OllamaServerAddress = "http://localhost:11434"
 		}
+		serverAddress, err := normalizeServerAddress(cfg.OllamaServerAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OLLAMA_HOST: %w", err)
+		}
+		cfg.OllamaServerAddress, cfg.OllamaEndpoint = normalizeOllamaEndpoint(serverAddress, cfg.OllamaEndpoint)
 		if cfg.Model == "" {
-			fmt.Println("No 'XPLANE_MODEL' provided, defaulting to 'gemma3n'...")
+			fmt.Printf("No 'XPLANE_MODEL' provided, picking a model pulled on the server (preferring '%s')...\n", preferredOllamaModel)
+		}
+		keepAlive, err := parseOllamaKeepAlive(os.Getenv("XPLANE_OLLAMA_KEEP_ALIVE"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid XPLANE_OLLAMA_KEEP_ALIVE: %w", err)
+		}
+		cfg.OllamaKeepAlive = keepAlive
+		if optionsStr := os.Getenv("XPLANE_OLLAMA_OPTIONS"); optionsStr != "" {
+			if err := json.Unmarshal([]byte(optionsStr), &cfg.OllamaOptions); err != nil {
+				return nil, fmt.Errorf("XPLANE_OLLAMA_OPTIONS must be a JSON object, e.g. '{\"num_ctx\": 8192}': %w", err)
+			}
 		}
 	}
 
-	commandsStr := os.Getenv("XPLANE_COMMANDS")
-	if commandsStr == "" {
-		commandsStr = defaultCommands
+	listOfCommands, err := resolveCommands()
+	if err != nil {
+		return nil, err
 	}
-	listOfCommands := strings.Split(commandsStr, ",")
 	hasBeenChecked := make(map[string]bool) // I'll avoid checking repeating pkgs more than once
 	missingBinaries := make([]string, 0)
 
@@ -94,6 +603,12 @@ func loadConfig() (*Config, error) {
 		binaryToCheck, isSpecial := specialCommandToBinMap[trimmedCommand]
 		if !isSpecial {
 			binaryToCheck = trimmedCommand
+			if expansion, isAlias := cfg.CommandAliases[trimmedCommand]; isAlias {
+				// already validated by parseCommandAliases
+				if fields, err := splitCommandLine(expansion); err == nil {
+					binaryToCheck = fields[0]
+				}
+			}
 		}
 
 		if binaryToCheck != "" && !hasBeenChecked[binaryToCheck] {
@@ -1,9 +1,10 @@
-package main
+package xplane
 
 import (
 	"bytes"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -153,6 +154,22 @@ func TestLoadConfig(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "ollama host without scheme",
+			envVars: map[string]string{
+				"XPLANE_PROVIDER": "ollama",
+				"OLLAMA_HOST":     "localhost:11434/",
+				"XPLANE_MODEL":    "llama2",
+			},
+			expectedConfig: &Config{
+				Provider:            "ollama",
+				Model:               "llama2",
+				OllamaServerAddress: "http://localhost:11434",
+				UseProjectKnowledge: false,
+			},
+			expectError: false,
+			expectPrint: true,
+		},
 		{
 			name: "USE_PROJECT_KNOWLEDGE variations",
 			envVars: map[string]string{
@@ -190,7 +207,7 @@ func TestLoadConfig(t *testing.T) {
 				r, w, _ := os.Pipe()
 				os.Stdout = w
 
-				cfg, err := loadConfig()
+				cfg, err := LoadConfig()
 
 				w.Close()
 				os.Stdout = old
@@ -223,7 +240,7 @@ func TestLoadConfig(t *testing.T) {
 					assert.Equal(t, tt.expectedConfig.APIKey, cfg.APIKey)
 				}
 			} else {
-				cfg, err := loadConfig()
+				cfg, err := LoadConfig()
 				
 				if tt.expectError {
 					assert.Error(t, err)
@@ -247,4 +264,131 @@ func TestLoadConfig(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestReadCommandsFile(t *testing.T) {
+	t.Run("one command per line with comments", func(t *testing.T) {
+		root := t.TempDir()
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, contextDir), 0o755))
+		content := "# local state\ngit_status\n\n  git_diff  \n# remote\ngithub_prs\n"
+		assert.NoError(t, os.WriteFile(filepath.Join(root, contextDir, commandsFile), []byte(content), 0o644))
+
+		commands, err := readCommandsFile(root)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"git_status", "git_diff", "github_prs"}, commands)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		commands, err := readCommandsFile(t.TempDir())
+		assert.NoError(t, err)
+		assert.Nil(t, commands)
+	})
+}
+
+func TestNormalizeServerAddress(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		expected  string
+		expectErr bool
+	}{
+		{"already normalized", "http://localhost:11434", "http://localhost:11434", false},
+		{"missing scheme", "localhost:11434", "http://localhost:11434", false},
+		{"trailing slashes", "https://ollama.example.com:8443//", "https://ollama.example.com:8443", false},
+		{"surrounding whitespace", "  192.168.1.10:11434/ ", "http://192.168.1.10:11434", false},
+		{"unsupported scheme", "ftp://localhost:11434", "", true},
+		{"no host", "http://", "", true},
+		{"unparseable", "http://local host:11434", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, err := normalizeServerAddress(tt.raw)
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, address)
+			}
+		})
+	}
+}
+
+func TestNormalizeOllamaEndpoint(t *testing.T) {
+	tests := []struct {
+		name             string
+		serverAddress    string
+		endpoint         string
+		expectedAddress  string
+		expectedEndpoint string
+	}{
+		{"defaults", "http://localhost:11434", "", "http://localhost:11434", "/api/generate"},
+		{"chat", "http://localhost:11434", "/api/chat", "http://localhost:11434", "/api/chat"},
+		{"trailing slash", "http://localhost:11434", "/api/chat/", "http://localhost:11434", "/api/chat"},
+		{"missing leading slash", "http://localhost:11434", "api/generate", "http://localhost:11434", "/api/generate"},
+		{"address ending in /api", "http://localhost:11434/api", "/api/chat", "http://localhost:11434", "/api/chat"},
+		{"address ending in /api/", "http://localhost:11434/api/", "", "http://localhost:11434", "/api/generate"},
+		{"proxied path", "https://gateway.example.com/ollama", "/api/chat", "https://gateway.example.com/ollama", "/api/chat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, endpoint := normalizeOllamaEndpoint(tt.serverAddress, tt.endpoint)
+			assert.Equal(t, tt.expectedAddress, address)
+			assert.Equal(t, tt.expectedEndpoint, endpoint)
+			assert.NotContains(t, address+endpoint, "/api/api")
+		})
+	}
+}
+
+func TestModelAliases(t *testing.T) {
+	aliases, err := parseModelAliases("fast=gemini-2.5-flash, best = claude-opus-4,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"fast": "gemini-2.5-flash", "best": "claude-opus-4"}, aliases)
+
+	assert.Equal(t, "claude-opus-4", resolveModelAlias(aliases, "best"))
+	assert.Equal(t, "llama3", resolveModelAlias(aliases, "llama3"))
+	assert.Equal(t, "", resolveModelAlias(aliases, ""))
+
+	_, err = parseModelAliases("fast")
+	assert.Error(t, err)
+	_, err = parseModelAliases("fast=")
+	assert.Error(t, err)
+}
+
+func TestApplyOverrides(t *testing.T) {
+	// registers the variables for cleanup, ApplyOverrides sets them for real
+	t.Setenv("XPLANE_PROVIDER", "claude_code")
+	t.Setenv("XPLANE_MODEL", "")
+	t.Setenv("GITHUB_TOKEN", "")
+
+	assert.NoError(t, ApplyOverrides([]string{"provider=ollama", "Model=llama3:8b", "github-token=abc=def"}))
+	assert.Equal(t, "ollama", os.Getenv("XPLANE_PROVIDER"), "flags win over env vars")
+	assert.Equal(t, "llama3:8b", os.Getenv("XPLANE_MODEL"))
+	assert.Equal(t, "abc=def", os.Getenv("GITHUB_TOKEN"), "only the first = splits")
+
+	err := ApplyOverrides([]string{"providr=ollama"})
+	assert.ErrorContains(t, err, "unknown override key 'providr'")
+	assert.ErrorContains(t, err, "provider,")
+
+	assert.ErrorContains(t, ApplyOverrides([]string{"provider"}), "expected key=value")
+}
+
+func TestParseCommandAliases(t *testing.T) {
+	aliases, err := parseCommandAliases("lint=golangci-lint run, tests = go test -run=Integration ./...")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"lint": "golangci-lint run", "tests": "go test -run=Integration ./..."}, aliases)
+
+	_, err = parseCommandAliases("git_log=git log --all")
+	assert.ErrorContains(t, err, "name of a built-in command")
+
+	_, err = parseCommandAliases("lint")
+	assert.ErrorContains(t, err, "expected alias=command")
+
+	aliases, err = parseCommandAliases(`fields=cut -d ',' -f1 data.csv,todos=git grep -n "TODO\|FIXME"`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"fields": "cut -d ',' -f1 data.csv", "todos": `git grep -n "TODO\|FIXME"`}, aliases)
+
+	_, err = parseCommandAliases(`todos=git grep -n "TODO`)
+	assert.ErrorContains(t, err, "unterminated \" quote")
+}
@@ -1,206 +1,719 @@
-package main
+package xplane
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 )
 
 var llm = os.Getenv("LLM")
 
+const unchangedBlockPlaceholder = "(unchanged, see CURRENT STATE)"
+
+type contextBlock struct {
+	source  string
+	content string
+}
+
+// how each command's output is framed in the dynamic context, some models pick up xml tags or markdown headers better
+type contextFormat struct {
+	header string // printf format taking the command name
+	footer string
+}
+
+const defaultContextFormat = "plain"
+
+var contextFormats = map[string]contextFormat{
+	"plain": {
+		header: "---CONTEXT FROM: %s ---\n",
+		footer: "\n\n",
+	},
+	"markdown": {
+		header: "## Context from: %s\n\n",
+		footer: "\n\n",
+	},
+	"xml": {
+		header: "<context source=\"%s\">\n",
+		footer: "\n</context>\n\n",
+	},
+}
+
+// formats a single command's output the way it's stored in the dynamic context
+func (f contextFormat) formatBlock(source, content string) string {
+	return fmt.Sprintf(f.header, source) + content + f.footer
+}
+
+// splits a dynamic context back into its per-command blocks. only the exact headers of the given sources count,
+// right after the previous block's footer and in the order of sources, the one gatherContext writes them in. so
+// a line of output that merely looks like a header, e.g. a markdown heading in docs_diff or a header quoted in
+// a commit message, doesn't start a new block
+func (f contextFormat) parseBlocks(raw string, sources []string) []contextBlock {
+	blocks, _ := f.splitBlocks(raw, sources)
+	return blocks
+}
+
+// parseBlocks, along with whatever came before the first block
+func (f contextFormat) splitBlocks(raw string, sources []string) ([]contextBlock, string) {
+	if len(sources) == 0 {
+		return nil, raw
+	}
+	headerIndexes := make(map[string]int, len(sources))
+	patterns := make([]string, 0, len(sources))
+	for i, source := range sources {
+		header := fmt.Sprintf(f.header, source)
+		headerIndexes[header] = i
+		patterns = append(patterns, regexp.QuoteMeta(header))
+	}
+	headerRegex := regexp.MustCompile(`(?m)^(?:` + strings.Join(patterns, "|") + `)`)
+
+	var headers [][]int
+	var headerSources []string
+	lastIndex := -1
+	for _, header := range headerRegex.FindAllStringIndex(raw, -1) {
+		index := headerIndexes[raw[header[0]:header[1]]]
+		if index <= lastIndex || (header[0] > 0 && !strings.HasSuffix(raw[:header[0]], f.footer)) {
+			continue
+		}
+		headers = append(headers, header)
+		headerSources = append(headerSources, sources[index])
+		lastIndex = index
+	}
+	if len(headers) == 0 {
+		return nil, raw
+	}
+	blocks := make([]contextBlock, 0, len(headers))
+	for i, header := range headers {
+		end := len(raw)
+		if i < len(headers)-1 {
+			end = headers[i+1][0]
+		}
+		blocks = append(blocks, contextBlock{
+			source:  headerSources[i],
+			content: strings.TrimSuffix(raw[header[1]:end], f.footer),
+		})
+	}
+	return blocks, raw[:headers[0][0]]
+}
+
+// the block names gatherContext writes for cfg, in order
+func (c *Config) contextSources() []string {
+	sources := make([]string, 0, len(c.Commands)+len(c.IncludeFiles)+1)
+	for _, command := range c.Commands {
+		sources = append(sources, strings.TrimSpace(command))
+	}
+	for _, path := range c.IncludeFiles {
+		sources = append(sources, "file:"+path)
+	}
+	return append(sources, "time_budget")
+}
+
+// replaces previous blocks that are identical in the current context with a short placeholder, so only the
+// blocks that differ are sent twice to the LLM. a block of a command that's no longer configured stays part
+// of the one before it, which then never counts as unchanged
+func compactPreviousContext(previous, current string, format contextFormat, sources []string) string {
+	currentBlocks := make(map[string]string)
+	for _, block := range format.parseBlocks(current, sources) {
+		currentBlocks[block.source] = block.content
+	}
+
+	previousBlocks, leading := format.splitBlocks(previous, sources)
+	if len(previousBlocks) == 0 {
+		return previous
+	}
+
+	var builder strings.Builder
+	builder.WriteString(leading)
+	for _, block := range previousBlocks {
+		content := block.content
+		if currentContent, ok := currentBlocks[block.source]; ok && currentContent == content {
+			content = unchangedBlockPlaceholder
+		}
+		builder.WriteString(format.formatBlock(block.source, content))
+	}
+	return builder.String()
+}
+
 func createPlaceHolderContext(cfg *Config) string {
 	var placeholderBuilder strings.Builder
+	format := cfg.contextFormat()
 	for _, command := range cfg.Commands {
 		trimmedCmd := strings.TrimSpace(command)
-		placeholderBuilder.WriteString(fmt.Sprintf("---CONTEXT FROM: %s ---\n%s\n\n",
-			trimmedCmd, "First run, no context available yet."))
+		placeholderBuilder.WriteString(format.formatBlock(trimmedCmd, "First run, no context available yet."))
 	}
 	return placeholderBuilder.String()
 }
 
-// wraps around various special commands, as well as custom commands, to gather context for an LLM
-func gatherContext(cfg *Config, gitRoot string) (string, error) {
-	fmt.Println(MsgFetchingContext)
+// wraps around various special commands, as well as custom commands, to gather context for an LLM. a dry run
+// leaves .xplane alone: cached remote results are read but not written, and the commands that can't run
+// without side effects are noted as not run
+func gatherContext(cfg *Config, gitRoot string, dryRun bool) (string, error) {
+	cfg.reportProgress(ProgressEvent{Kind: ProgressGatheringStarted})
 	var contextBuilder strings.Builder
 
+	if cfg.Prefetch {
+		prefetchRemoteState(cfg, gitRoot)
+	}
+
 	gatherer := NewContextGatherer(gitRoot, cfg)
 	initErr := gatherer.initProvider()
 
-	commandHandlersMap := map[string]func() (string, error){
-		"git_status":        func() (string, error) { return getGitStatus(gitRoot) },
-		"git_log":           func() (string, error) { return getGitLog(gitRoot, 15) },
-		"tokei":             func() (string, error) { return getTokeiStats(gitRoot) },
-		"ripsecrets":        func() (string, error) { return getRipSecrets(gitRoot) },
-		"readme":            func() (string, error) { return getReadme(gitRoot) },
-		"git_exclude":       func() (string, error) { return getGitExclude(gitRoot) },
-		"gitignore":         func() (string, error) { return getGitignore(gitRoot) },
-		"git_diff":          func() (string, error) { return getGitDiff(gitRoot) },
-		"github_prs":        gatherer.getOpenPRS,
-		"gitlab_mrs":        gatherer.getOpenPRS,
-		"release":           gatherer.getLatestRelease,
-		"git_branch_status": gatherer.getGitBranchStatus,
+	if cfg.AnonymizeAuthors {
+		anonymizer, err := newRepoAnonymizer(gitRoot, !dryRun)
+		if err != nil {
+			return "", err
+		}
+		gatherer.anonymizer = anonymizer
 	}
 
+	commandHandlersMap := commandHandlers(cfg, gitRoot, gatherer, dryRun)
+
+	// XPLANE_MAX_RUNTIME, commands that haven't started when it passes are skipped instead of run
+	var deadline time.Time
+	if cfg.MaxRuntime > 0 {
+		deadline = time.Now().Add(cfg.MaxRuntime)
+	}
+	var skipped []string
+
 	for _, command := range cfg.Commands {
 		var output string
 		var err error
 		trimmedCmd := strings.TrimSpace(command)
+		started := ProgressEvent{Kind: ProgressCommandStarted, Command: trimmedCmd}
 
-		isGitProviderBasedCommand := trimmedCmd == "github_prs" || trimmedCmd == "gitlab_mrs" || trimmedCmd == "release" || trimmedCmd == "git_branch_status"
-
-		if isGitProviderBasedCommand {
+		if gitProviderCommands[trimmedCmd] {
 			if initErr != nil {
-				fmt.Printf("    - ⚠️  Skipping command '%s': could not initialize git provider (%v)\n", trimmedCmd, initErr)
+				fmt.Printf(cfg.msg(MsgSkippingCommand), trimmedCmd, redactError(initErr))
 				continue
 			}
 			providerName := gatherer.gitProvider.GetProviderName()
-			if providerName == "github" && trimmedCmd == "gitlab_mrs" {
+			if skipForProvider(providerName, trimmedCmd) {
 				continue
 			}
-			if providerName == "gitlab" && trimmedCmd == "github_prs" {
-				continue
+			started.Provider = providerName
+		}
+
+		useCache := gitProviderCommands[trimmedCmd] && cfg.RemoteCacheTTL > 0
+		cacheKey := trimmedCmd
+		if useCache {
+			cacheKey, useCache = remoteCacheKey(cfg, gitRoot, trimmedCmd)
+		}
+		cachedOutput, isCached := "", false
+		if useCache && !cfg.RefreshCache {
+			cachedOutput, isCached = readCachedOutput(gitRoot, cacheKey, cfg.RemoteCacheTTL)
+		}
+		if isCached {
+			// the names a fresh run would have noted, so the block below is anonymized the same way
+			authors, _ := readCachedOutput(gitRoot, cachedAuthorsKey(cacheKey), cfg.RemoteCacheTTL)
+			for _, author := range outputLines(authors) {
+				gatherer.noteAuthor(author)
 			}
-			fmt.Println(buildRemoteInfoMsg(providerName, trimmedCmd))
 		}
 
-		if handler, ok := commandHandlersMap[trimmedCmd]; ok {
-			output, err = handler()
+		// a cached result costs nothing, so it's still used past the deadline
+		if !isCached && pastDeadline(deadline) {
+			skipped = append(skipped, trimmedCmd)
+			continue
+		}
+
+		started.Cached = isCached
+		cfg.reportProgress(started)
+		startedAt := time.Now()
+
+		omitted := false
+		if isCached {
+			output = cachedOutput
 		} else {
-			fmt.Printf(MsgGenericCommand, trimmedCmd)
-			output, err = runCommand(gitRoot, trimmedCmd, gitRoot)
+			gatherer.authorsSeen = nil
+			output, err = runContextCommand(cfg, gitRoot, trimmedCmd, commandHandlersMap)
+			if errors.Is(err, errOmitBlock) {
+				omitted, err = true, nil
+			} else if errors.Is(err, errSkippedInDryRun) {
+				output, err = fmt.Sprintf("Skipped: %s.", err), nil
+			} else if err == nil && useCache && !dryRun {
+				// cached before anonymizing, along with the names to anonymize
+				cacheErr := writeCachedOutput(gitRoot, cacheKey, output)
+				if cacheErr == nil {
+					cacheErr = writeCachedOutput(gitRoot, cachedAuthorsKey(cacheKey), strings.Join(gatherer.authorsSeen, "\n"))
+				}
+				if cacheErr != nil {
+					log.Printf("Warning: Could not cache output of '%s': %s", trimmedCmd, redactError(cacheErr))
+				}
+				pruneCachedOutputs(gitRoot, trimmedCmd+"_", cfg.RemoteCacheTTL)
+			}
 		}
+		cfg.reportProgress(ProgressEvent{
+			Kind: ProgressCommandFinished, Command: trimmedCmd, Cached: isCached, Provider: started.Provider,
+			Duration: time.Since(startedAt), Err: err,
+		})
 
 		if err != nil {
 			return "", fmt.Errorf("error running command '%s': %w", trimmedCmd, err)
 		}
-		contextBuilder.WriteString(fmt.Sprintf("---CONTEXT FROM: %s ---\n%s\n\n", trimmedCmd, output))
+		if omitted {
+			continue
+		}
+		if strings.TrimSpace(output) == "" {
+			output = emptyOutputPlaceholder(trimmedCmd)
+		}
+		if gatherer.anonymizer != nil {
+			output = gatherer.anonymizer.anonymize(output)
+		}
+		contextBuilder.WriteString(cfg.contextFormat().formatBlock(trimmedCmd, output))
+	}
+
+	for _, path := range cfg.IncludeFiles {
+		if pastDeadline(deadline) {
+			skipped = append(skipped, "file:"+path)
+			continue
+		}
+		cfg.reportProgress(ProgressEvent{Kind: ProgressCommandStarted, Command: "file:" + path})
+		startedAt := time.Now()
+		output, err := readIncludedFile(gitRoot, path)
+		cfg.reportProgress(ProgressEvent{Kind: ProgressCommandFinished, Command: "file:" + path, Duration: time.Since(startedAt), Err: err})
+		if err != nil {
+			return "", fmt.Errorf("error including file '%s': %w", path, err)
+		}
+		if gatherer.anonymizer != nil {
+			output = gatherer.anonymizer.anonymize(output)
+		}
+		contextBuilder.WriteString(cfg.contextFormat().formatBlock("file:"+path, output))
+	}
+
+	if len(skipped) > 0 {
+		fmt.Printf(cfg.msg(MsgSkippedForTimeBudget), len(skipped), strings.Join(skipped, ", "))
+		// tells the llm the missing blocks weren't run, rather than having gone empty
+		note := fmt.Sprintf("Skipped %d commands due to time budget: %s", len(skipped), strings.Join(skipped, ", "))
+		contextBuilder.WriteString(cfg.contextFormat().formatBlock("time_budget", note))
 	}
 
 	return contextBuilder.String(), nil
 }
 
-func contextCompare(llm LLMProvider, cfg *Config, gitRoot string) {
-	dynamicContextPath := filepath.Join(gitRoot, contextDir, dynamicContextFile)
-	staticContextPath := filepath.Join(gitRoot, contextDir, staticContextFile)
-	// reading the static prompt template
-	staticPromptBytes, err := os.ReadFile(staticContextPath)
+// returned by a built-in command with nothing worth a block, gatherContext then leaves the command out entirely
+var errOmitBlock = errors.New("nothing to report")
+
+// returned in a dry run by the commands that can't run without side effects
+var errSkippedInDryRun = errors.New("not run in a dry run")
+
+// the built-in commands by name, shared by gatherContext and the audit. a dry run neither writes to .xplane,
+// calls the llm nor runs the build
+func commandHandlers(cfg *Config, gitRoot string, gatherer *ContextGatherer, dryRun bool) map[string]func() (string, error) {
+	buildSize := func() (string, error) { return getBuildSize(gitRoot, cfg.BuildCmd, cfg.BuildArtifact) }
+	if dryRun {
+		buildSize = func() (string, error) { return "", fmt.Errorf("%w, it runs XPLANE_BUILD_CMD", errSkippedInDryRun) }
+	}
+	return map[string]func() (string, error){
+		"git_status":         func() (string, error) { return getGitStatus(gitRoot, cfg.ownFilesOutsideContextDir()...) },
+		"git_log":            func() (string, error) { return getGitLog(gitRoot, 15, cfg.Author) },
+		"git_log_patches":    func() (string, error) { return getGitLogPatches(gitRoot, 5, cfg.Author) },
+		"diff_since_release": func() (string, error) { return getDiffSinceRelease(gitRoot, cfg.diffBudget()) },
+		"branch_diff":        func() (string, error) { return getBranchDiff(gitRoot, cfg.CompareBranch, cfg.diffBudget()) },
+		"tokei":              func() (string, error) { return getTokeiStats(gitRoot) },
+		"ripsecrets":         func() (string, error) { return getRipSecrets(gitRoot) },
+		"readme":             func() (string, error) { return getReadme(gitRoot) },
+		"git_exclude":        func() (string, error) { return getGitExclude(gitRoot) },
+		"gitignore":          func() (string, error) { return getGitignore(gitRoot) },
+		"git_diff":           func() (string, error) { return getBudgetedGitDiff(cfg, gitRoot, !dryRun) },
+		"diff_summary":       func() (string, error) { return getDiffSummary(gitRoot, cfg.ownFilesOutsideContextDir()...) },
+		"api_spec_diff":      func() (string, error) { return getAPISpecDiff(gitRoot) },
+		"recent_blame":       func() (string, error) { return getRecentBlame(gitRoot) },
+		"container_diff":     func() (string, error) { return getContainerDiff(gitRoot) },
+		"ci_config_diff":     func() (string, error) { return getCIConfigDiff(gitRoot) },
+		"docs_diff":          func() (string, error) { return getDocsDiff(gitRoot, cfg.ownFilesOutsideContextDir()...) },
+		"test_diff":          func() (string, error) { return getTestDiff(gitRoot) },
+		"coverage":           func() (string, error) { return getCoverage(gitRoot, !dryRun) },
+		"build_size":         buildSize,
+		"rerere_status":      func() (string, error) { return getRerereStatus(gitRoot) },
+		"git_submodules":     func() (string, error) { return getGitSubmodules(gitRoot) },
+		"license":            func() (string, error) { return getLicense(gitRoot) },
+		"conflict_markers":   func() (string, error) { return getConflictMarkers(gitRoot) },
+		"reverts":            func() (string, error) { return getReverts(gitRoot, 10) },
+		"path_history":       func() (string, error) { return getPathHistory(gitRoot, cfg.HistoryPath, 5) },
+		"github_prs":         gatherer.getOpenPRS,
+		"gitlab_mrs":         gatherer.getOpenPRS,
+		"release":            gatherer.getLatestRelease,
+		"recent_releases":    func() (string, error) { return gatherer.getRecentReleases(10) },
+		"git_branch_status":  gatherer.getGitBranchStatus,
+		"shipped_issues":     func() (string, error) { return gatherer.getShippedIssues(10) },
+		"merge_status":       gatherer.getMergeStatus,
+		"pr_overlap":         gatherer.getPROverlap,
+		"mergeable_prs":      gatherer.getMergeablePRs,
+		"stale_branches":     func() (string, error) { return gatherer.getStaleBranches(cfg.staleBranchDays()) },
+		"github_discussions": func() (string, error) { return gatherer.getDiscussions(10) },
+	}
+}
+
+// runs a command that isn't cached: a built-in, an alias from XPLANE_COMMAND_ALIASES or any binary on PATH
+func runContextCommand(cfg *Config, gitRoot, command string, handlers map[string]func() (string, error)) (string, error) {
+	if handler, ok := handlers[command]; ok {
+		return handler()
+	}
+	if expansion, isAlias := cfg.CommandAliases[command]; isAlias {
+		// run as given, the block keeps the friendly name
+		fields, err := splitCommandLine(expansion)
+		if err != nil {
+			return "", fmt.Errorf("invalid alias '%s': %w", command, err)
+		}
+		return runCommand(gitRoot, fields[0], fields[1:]...)
+	}
+	return runCommand(gitRoot, command, gitRoot)
+}
+
+// whether a git provider command doesn't apply to the remote's provider, e.g. gitlab_mrs on a github repo
+func skipForProvider(providerName, command string) bool {
+	switch providerName {
+	case "github":
+		return command == "gitlab_mrs"
+	case "gitlab":
+		return command == "github_prs" || command == "github_discussions"
+	}
+	return false
+}
+
+// what a command that printed nothing gets in its block instead, so the llm reads "nothing to report"
+// rather than guessing at a blank block. commands without an entry get a generic line
+var emptyOutputPlaceholders = map[string]string{
+	"git_status":  "Working tree clean, no uncommitted or untracked changes.",
+	"git_log":     "No commits yet.",
+	"readme":      "README.md is empty.",
+	"gitignore":   ".gitignore is empty.",
+	"git_exclude": ".git/info/exclude is empty.",
+}
+
+func emptyOutputPlaceholder(command string) string {
+	if placeholder, ok := emptyOutputPlaceholders[command]; ok {
+		return placeholder
+	}
+	return fmt.Sprintf("No output from '%s'.", command)
+}
+
+func pastDeadline(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// updates remote tracking refs and drops the ones deleted on the remote, so tracking checks and branch
+// comparisons don't run on stale local knowledge; being offline shouldn't stop a summary, hence only a warning
+func prefetchRemoteState(cfg *Config, gitRoot string) {
+	fmt.Println(cfg.msg(MsgPrefetchingRemote))
+	if _, err := runCommand(gitRoot, "git", "fetch", "--prune", "--quiet"); err != nil {
+		log.Printf("Warning: Could not fetch remote state, it may be stale: %s", redactError(err))
+	}
+}
+
+// files added with XPLANE_INCLUDE_FILES are cut past this, like any command output they share the prompt
+const maxIncludedFileLength = 20000
+
+// reads a file to include as context, a missing file gets a placeholder so a moved doc doesn't break every run
+func readIncludedFile(gitRoot, path string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(gitRoot, path))
 	if os.IsNotExist(err) {
-		fmt.Println("xplane: static_context.txt not found, creating default.")
-		if err := os.MkdirAll(filepath.Dir(staticContextPath), 0o755); err != nil {
-			log.Fatalf("xplane: could not create .xplane directory: %v", err)
+		return fmt.Sprintf("File '%s' not found.", path), nil
+	} else if err != nil {
+		return "", err
+	}
+	if len(content) > maxIncludedFileLength {
+		kept := truncateAtRune(string(content), maxIncludedFileLength)
+		return fmt.Sprintf("%s\n[file truncated, %d more characters]", kept, len(content)-len(kept)), nil
+	}
+	return string(content), nil
+}
+
+// surrounds the assembled prompt with the standing instructions from XPLANE_PROMPT_PREFIX/SUFFIX
+func wrapPrompt(prompt, prefix, suffix string) string {
+	if prefix != "" {
+		prompt = prefix + "\n\n" + prompt
+	}
+	if suffix != "" {
+		prompt = prompt + "\n\n" + suffix
+	}
+	return prompt
+}
+
+// wraps the existing project knowledge with the instructions to grow it
+func buildKnowledgeSection(knowledgeContent, instructions, language string) string {
+	languageNote := ""
+	if language != "" {
+		// the header is how the update gets found in the response, translating it would lose the update
+		languageNote = fmt.Sprintf("\n\nWrite the KNOWLEDGE UPDATE in %s, like the rest of the knowledge base, but keep the 'KNOWLEDGE UPDATE' header itself in English.", language)
+	}
+	return fmt.Sprintf("\n\n--- PROJECT KNOWLEDGE ---\n%s\n\n%s%s", knowledgeContent, strings.TrimRight(instructions, "\n"), languageNote)
+}
+
+// assembles the prompt sent to the LLM from the static template, the optional knowledge section and both contexts
+func buildFinalPrompt(staticPrompt, knowledgeSection, previousContext, currentContext string, cfg *Config) string {
+	staticPrompt = staticPrompt + knowledgeSection
+
+	// collapsing blocks that didn't change keeps the prompt focused on what actually moved
+	if cfg.CompactContext {
+		previousContext = compactPreviousContext(previousContext, currentContext, cfg.contextFormat(), cfg.contextSources())
+	}
+
+	finalPrompt := strings.ReplaceAll(staticPrompt, "{{CURRENT_CONTEXT}}", currentContext)
+	finalPrompt = strings.ReplaceAll(finalPrompt, "{{PREVIOUS_CONTEXT}}", previousContext)
+	return wrapPrompt(withLanguage(finalPrompt, cfg.Language), cfg.PromptPrefix, cfg.PromptSuffix)
+}
+
+// XPLANE_LANGUAGE, asked for last so it isn't lost above a long context
+func withLanguage(prompt, language string) string {
+	if language == "" {
+		return prompt
+	}
+	return fmt.Sprintf("%s\n\nRespond in %s.", prompt, language)
+}
+
+// puts the current branch's PR description on top of the prompt, a lookup failure only costs the hint
+func prependStatedIntent(staticPrompt, gitRoot string, cfg *Config) string {
+	intentSection, err := NewContextGatherer(gitRoot, cfg).getStatedIntent()
+	if err != nil {
+		log.Printf("Warning: Could not fetch the current branch's pull/merge request description: %s", redactError(err))
+		return staticPrompt
+	}
+	return intentSection + staticPrompt
+}
+
+// reads the user's prompt template with its includes resolved, falling back to the default one when there's none yet
+func readStaticPrompt(gitRoot string) (string, error) {
+	return readTemplate(gitRoot, staticContextFile)
+}
+
+var includeDirectiveRegex = regexp.MustCompile(`\{\{INCLUDE:\s*([^}]+?)\s*\}\}`)
+
+// replaces {{INCLUDE:path}} directives with the file's content, recursively. relative paths are resolved against
+// the including file's directory, so .xplane/ for the static context. chain holds the files being resolved, to catch cycles
+func resolveIncludes(content, baseDir string, chain []string) (string, error) {
+	var resolveErr error
+	resolved := includeDirectiveRegex.ReplaceAllStringFunc(content, func(directive string) string {
+		if resolveErr != nil {
+			return directive
+		}
+		includePath := includeDirectiveRegex.FindStringSubmatch(directive)[1]
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(baseDir, includePath)
 		}
-		if err := os.WriteFile(staticContextPath, []byte(defaultStaticContext), 0o644); err != nil {
-			log.Fatalf("xplane: could not write default static context: %v", err)
+		includePath = filepath.Clean(includePath)
+
+		if slices.Contains(chain, includePath) {
+			resolveErr = fmt.Errorf("circular include: %s", strings.Join(append(chain, includePath), " -> "))
+			return directive
 		}
-		staticPromptBytes, err = os.ReadFile(staticContextPath)
+		includedBytes, err := os.ReadFile(includePath)
+		if err != nil {
+			resolveErr = fmt.Errorf("could not include '%s': %w", includePath, err)
+			return directive
+		}
+
+		included, err := resolveIncludes(string(includedBytes), filepath.Dir(includePath), append(slices.Clone(chain), includePath))
+		if err != nil {
+			resolveErr = err
+			return directive
+		}
+		return strings.TrimSuffix(included, "\n")
+	})
+	if resolveErr != nil {
+		return "", resolveErr
 	}
+	return resolved, nil
+}
 
-	fetchedDynamicContext, err := gatherContext(cfg, gitRoot)
+func contextCompare(llm LLMProvider, cfg *Config, gitRoot string) (err error) {
+	staticPrompt, err := readStaticPrompt(gitRoot)
 	if err != nil {
-		log.Fatalf("xplane: Error gathering context: %v", err)
+		return err
 	}
 
-	previousDynamicContext, err := os.ReadFile(dynamicContextPath)
-	if os.IsNotExist(err) {
-		fmt.Println("xplane: Initializing project. No summary will be generated on this first run.")
+	fetchedDynamicContext, err := gatherContext(cfg, gitRoot, false)
+	if err != nil {
+		return fmt.Errorf("error gathering context: %w", err)
+	}
+
+	previousDynamicContext, migrate, err := readDynamicContext(gitRoot, cfg.CompressContext)
+	if errors.Is(err, fs.ErrNotExist) {
 		placeholderContext := createPlaceHolderContext(cfg)
-		os.MkdirAll(filepath.Dir(dynamicContextPath), 0o755)
-		os.WriteFile(dynamicContextPath, []byte(placeholderContext), 0o644)
-		return
+		if cfg.SummarizeFirstRun {
+			// the placeholder acts as an empty baseline, the deferred write below stores the real context
+			fmt.Println("xplane: Initializing project, summarizing the current state.")
+			previousDynamicContext = placeholderContext
+		} else {
+			fmt.Println("xplane: Initializing project. No summary will be generated on this first run.")
+			if err := writeDynamicContext(gitRoot, placeholderContext, cfg.CompressContext); err != nil {
+				return fmt.Errorf("could not store the initial context: %w", err)
+			}
+			return nil
+		}
+	} else if err != nil {
+		return fmt.Errorf("could not read %s: %w", dynamicContextFile, err)
+	} else if migrate {
+		// compression was toggled, storing it in the new format right away so it happens even without updates
+		if err := writeDynamicContext(gitRoot, previousDynamicContext, cfg.CompressContext); err != nil {
+			log.Printf("Warning: Could not migrate %s: %s", dynamicContextFile, redactError(err))
+		}
 	}
 
-	if fetchedDynamicContext == string(previousDynamicContext) {
-		fmt.Println("✅ xplane: No new updates.")
-		return
+	if fetchedDynamicContext == previousDynamicContext && !cfg.ForceSummary {
+		fmt.Println(cfg.msg(MsgNoNewUpdates))
+		return nil
 	}
-	fmt.Printf(MsgAnalyzingContext, llm.getName(), cfg.Model)
+	cfg.reportProgress(ProgressEvent{Kind: ProgressLLMStarted, Provider: llm.getName(), Model: llm.getModel()})
 
-	// always writing to the file if there are changes in dynamic context
+	// the baseline only advances once the changes were summarized, a failed llm call would otherwise
+	// swallow them for good, unless XPLANE_ADVANCE_ON_FAILURE asks for the old behavior
+	summarized := false
 	defer func() {
-		os.MkdirAll(filepath.Dir(dynamicContextPath), 0o755)
-		os.WriteFile(dynamicContextPath, []byte(fetchedDynamicContext), 0o644)
+		if !summarized && !cfg.AdvanceOnFailure {
+			fmt.Println(cfg.msg(MsgContextNotAdvanced))
+			return
+		}
+		if writeErr := writeDynamicContext(gitRoot, fetchedDynamicContext, cfg.CompressContext); writeErr != nil {
+			// the summary is out already, but the next run has to know these changes were never stored
+			if err == nil {
+				err = fmt.Errorf("could not store the new context, the previous one was kept and the same changes will be summarized again on the next run: %w", writeErr)
+			}
+			return
+		}
 		fmt.Println("xplane: Context updated.")
 	}()
 
-	// reading the static prompt template and ensuring it's built
-	staticPrompt := string(staticPromptBytes)
-
-	// inject project knowledge instructions if enabled
+	// inject project knowledge instructions if enabled, this only ever touches the prompt,
+	// never the gathered contexts used for the change check above
+	knowledgeSection := ""
 	if cfg.UseProjectKnowledge {
-		knowledgeContent, knowledgeErr := readKnowledgeFile()
+		knowledgeContent, knowledgeErr := readKnowledgeFile(cfg)
 		if knowledgeErr != nil {
-			log.Printf("Warning: Could not read knowledge file: %v", knowledgeErr)
+			log.Printf("Warning: Could not read knowledge file: %s", redactError(knowledgeErr))
 			knowledgeContent = "No existing project knowledge found."
 		}
+		instructions, err := readTemplate(gitRoot, knowledgeInstructionsFile)
+		if err != nil {
+			return err
+		}
+		knowledgeSection = buildKnowledgeSection(knowledgeContent, instructions, cfg.Language)
+	}
 
-		knowledgeSection := fmt.Sprintf(`
-
---- PROJECT KNOWLEDGE ---
-%s
-
-CRITICAL KNOWLEDGE MANAGEMENT INSTRUCTIONS:
-This project maintains a living knowledge base at .xplane/KNOWLEDGE.md that must grow over time.
-
-Current knowledge above represents the institutional memory of this project. Your task is to:
-
-1. READ the existing knowledge carefully - it contains important context about the project's evolution
-2. ANALYZE the current changes in relation to this existing knowledge
-3. If this session reveals any of the following, you MUST include a 'KNOWLEDGE UPDATE' section:
-   - New architectural decisions or technology stack changes
-   - Important bug fixes or patterns discovered
-   - Significant feature additions or modifications
-   - Development workflow changes
-   - Dependencies or configuration changes
-   - Any insights that would help future development sessions
-
-KNOWLEDGE UPDATE format:
-- Include a 'KNOWLEDGE UPDATE' section in your response containing ONLY NEW insights
-- Focus on what's NEW or CHANGED since the last session
-- DO NOT repeat existing knowledge - the system will preserve it automatically
-- Organize new insights by: Architecture, Recent Changes, Important Patterns, Development Notes
-- Be comprehensive about NEW information that would help future development sessions
-
-Your KNOWLEDGE UPDATE should contain only fresh insights - existing knowledge will be preserved automatically in a timeline format.`, knowledgeContent)
-
-		staticPrompt = staticPrompt + knowledgeSection
+	if cfg.IncludePRIntent {
+		staticPrompt = prependStatedIntent(staticPrompt, gitRoot, cfg)
 	}
 
-	finalPrompt := strings.ReplaceAll(staticPrompt, "{{CURRENT_CONTEXT}}", fetchedDynamicContext)
-	finalPrompt = strings.ReplaceAll(finalPrompt, "{{PREVIOUS_CONTEXT}}", string(previousDynamicContext))
+	// the stored context is always the raw one, condensing only ever shapes the prompt
+	promptPrevious, promptCurrent, err := maybeCondenseContexts(cfg, previousDynamicContext, fetchedDynamicContext)
+	if err != nil {
+		return err
+	}
+	finalPrompt := buildFinalPrompt(staticPrompt, knowledgeSection, promptPrevious, promptCurrent, cfg)
+	if cfg.SavePrompt {
+		// keeps exactly what was sent around, for when a summary is surprising
+		// .xplane/ may not exist yet, the stored context is only written after the summary
+		promptPath := filepath.Join(gitRoot, contextDir, lastPromptFile)
+		saveErr := os.MkdirAll(filepath.Dir(promptPath), 0o755)
+		if saveErr == nil {
+			saveErr = os.WriteFile(promptPath, []byte(finalPrompt), 0o644)
+		}
+		if saveErr != nil {
+			log.Printf("Warning: Could not save the prompt: %s", redactError(saveErr))
+		}
+	}
 
-	// getting summary from LLM
-	summary, err := llm.summarizeContext(finalPrompt)
+	summary, streamed, err := generateSummary(cfg, llm, finalPrompt)
 	if err != nil {
-		fmt.Printf("⚠️ xplane: Could not generate summary: %v\n", err)
+		fmt.Printf(cfg.msg(MsgSummaryFailed), redactError(err))
 	} else {
+		summarized = true
+
 		// handle knowledge updates if enabled
 		if cfg.UseProjectKnowledge {
 			if updatedKnowledge := extractKnowledgeUpdate(summary); updatedKnowledge != "" {
-				if err := writeKnowledgeFile(updatedKnowledge); err != nil {
-					log.Printf("Warning: Could not update knowledge file: %v", err)
+				provenance := ""
+				if cfg.KnowledgeProvenance {
+					provenance = knowledgeProvenance(llm.getName(), llm.getModel(), fetchedDynamicContext)
+				}
+				if err := writeKnowledgeFile(cfg, updatedKnowledge, provenance, cfg.KnowledgeMaxEntries); err != nil {
+					log.Printf("Warning: Could not update knowledge file: %s", redactError(err))
 				} else {
-					fmt.Println(MsgKnowledgeUpdated)
+					fmt.Println(cfg.msg(MsgKnowledgeUpdated))
+					if cfg.CommitKnowledge {
+						if err := commitKnowledgeFile(gitRoot, cfg.knowledgeFilePath()); err != nil {
+							log.Printf("Warning: Could not commit knowledge file: %s", redactError(err))
+						} else {
+							fmt.Println(cfg.msg(MsgKnowledgeCommitted))
+						}
+					}
 				}
 			}
 		}
 
-		renderedSummary, renderErr := renderMarkdown(summary)
-		if renderErr != nil {
-			// fallback to printing
-			fmt.Println("Error rendering markdown, printing raw output:")
-			fmt.Println(summary)
-		} else {
-			fmt.Println(renderedSummary)
+		if cfg.PostComment {
+			if err := NewContextGatherer(gitRoot, cfg).postSummaryComment(summary); err != nil {
+				log.Printf("Warning: Could not post summary comment: %s", redactError(err))
+			}
+		}
+
+		if !streamed {
+			printSummary(cfg, summary)
+		}
+
+		if cfg.ShowDiff {
+			printDiff(cfg, gitRoot)
+		}
+
+		// after the summary so it doesn't scroll away
+		if hasUnpersistedKnowledgeUpdate(cfg, summary) {
+			fmt.Printf(cfg.msg(MsgKnowledgeDisabledHint), cfg.knowledgeFilePath())
+		}
+
+		if cfg.CopyToClipboard {
+			if err := copyToClipboard(summary); err != nil {
+				log.Printf("Warning: Could not copy the summary to the clipboard: %s", redactError(err))
+			} else {
+				fmt.Println(cfg.msg(MsgSummaryCopied))
+			}
 		}
 	}
+	return nil
+}
+
+// gets the summary from the llm, with a ticker so long calls don't look stuck. when the provider and the output
+// format allow it the summary is rendered as it arrives, streamed tells that it was already printed then
+func generateSummary(cfg *Config, llm LLMProvider, finalPrompt string) (summary string, streamed bool, err error) {
+	stopProgress := func() {}
+	if cfg.ShowProgress && isTerminal(os.Stdout) {
+		stopProgress = startProgressIndicator(os.Stdout, cfg, llm.getName())
+	}
+	llmStartedAt := time.Now()
+	streamed = canStreamSummary(cfg, llm)
+	if streamed {
+		// the full summary is still returned for knowledge and comments
+		summary, err = streamSummary(cfg, llm.(streamingLLMProvider), finalPrompt, os.Stdout, stopProgress)
+	} else {
+		summary, err = llm.summarizeContext(finalPrompt)
+	}
+	stopProgress()
+	cfg.reportProgress(ProgressEvent{Kind: ProgressLLMFinished, Provider: llm.getName(), Model: llm.getModel(), Duration: time.Since(llmStartedAt), Err: err})
+	return summary, streamed, err
+}
+
+// generateSummary for the subcommands that only print the summary, like pr and --compare
+func printGeneratedSummary(cfg *Config, llm LLMProvider, finalPrompt string) error {
+	summary, streamed, err := generateSummary(cfg, llm, finalPrompt)
+	if err != nil {
+		return fmt.Errorf("could not generate summary: %w", err)
+	}
+	if !streamed {
+		printSummary(cfg, summary)
+	}
+	return nil
 }
 
 // readKnowledgeFile reads the project knowledge file content
-func readKnowledgeFile() (string, error) {
-	knowledgePath, err := getKnowledgeFilePath()
+func readKnowledgeFile(cfg *Config) (string, error) {
+	knowledgePath, err := getKnowledgeFilePath(cfg)
 	if err != nil {
 		return "", err
 	}
@@ -209,10 +722,10 @@ func readKnowledgeFile() (string, error) {
 	if os.IsNotExist(err) {
 		// Initialize empty knowledge file on first run
 		initialContent := "*This file will be automatically updated with project insights and important context.*"
-		if err := writeKnowledgeFile(initialContent); err != nil {
+		if err := writeKnowledgeFile(cfg, initialContent, "", 0); err != nil {
 			return "", fmt.Errorf("failed to initialize knowledge file: %v", err)
 		}
-		fmt.Println(MsgKnowledgeInitialized)
+		fmt.Printf(cfg.msg(MsgKnowledgeInitialized), cfg.knowledgeFilePath())
 		// Return the timestamped content that was actually written
 		return fmt.Sprintf("# Project Knowledge\n\n*Last updated: %s*\n\n%s", time.Now().Format("2006-01-02 15:04:05"), initialContent), nil
 	}
@@ -223,24 +736,77 @@ func readKnowledgeFile() (string, error) {
 	return string(content), nil
 }
 
-// writeKnowledgeFile prepends new content to the project knowledge file with timestamp
-func writeKnowledgeFile(newContent string) error {
-	knowledgePath, err := getKnowledgeFilePath()
+// records which model produced a knowledge entry and from which repo state, so reviewers can trace it back
+func knowledgeProvenance(provider, model, sourceContext string) string {
+	if model == "" {
+		model = "default model"
+	}
+	hash := sha256.Sum256([]byte(sourceContext))
+	return fmt.Sprintf("*Generated by %s (%s) from context %s*", provider, model, hex.EncodeToString(hash[:6]))
+}
+
+// separates each timeline entry from the older ones, see writeKnowledgeFile
+const knowledgeTimelineSeparator = "\n\n---\n\n## Previous Knowledge\n\n"
+
+// keeps the newest keep entries of a knowledge timeline (without its header), dropping the oldest ones
+func pruneKnowledgeTimeline(timeline string, keep int) string {
+	entries := strings.Split(timeline, knowledgeTimelineSeparator)
+	if keep >= len(entries) {
+		return timeline
+	}
+	return strings.Join(entries[:keep], knowledgeTimelineSeparator)
+}
+
+const defaultKnowledgeHeader = "# Project Knowledge"
+
+// sits between the text of a file xplane didn't create and the knowledge xplane adds under it
+const knowledgePreambleMarker = "<!-- xplane: the text above is kept as is, the project knowledge below is generated -->"
+
+// splits a knowledge file into its title, its preamble and its timeline, dropping the last updated line.
+// files xplane didn't create (XPLANE_KNOWLEDGE_FILE pointing at existing docs) keep their own title and their
+// text becomes the preamble, which is never pruned
+func splitKnowledgeFile(content string) (header, preamble, timeline string) {
+	lines := strings.Split(content, "\n")
+	if !strings.HasPrefix(lines[0], "# ") {
+		return defaultKnowledgeHeader, strings.TrimSpace(content), ""
+	}
+	header = strings.TrimSpace(lines[0])
+	body := strings.TrimSpace(strings.Join(lines[1:], "\n"))
+	if before, after, found := strings.Cut(body, knowledgePreambleMarker); found {
+		preamble, body = strings.TrimSpace(before), strings.TrimSpace(after)
+	} else if !strings.HasPrefix(body, "*Last updated:") {
+		return header, body, ""
+	}
+	if strings.HasPrefix(body, "*Last updated:") {
+		_, body, _ = strings.Cut(body, "\n")
+	}
+	return header, preamble, strings.TrimSpace(body)
+}
+
+// writeKnowledgeFile prepends new content to the project knowledge file with timestamp,
+// provenance is an optional line annotating the new entry and maxEntries caps the timeline length (zero means unlimited)
+func writeKnowledgeFile(cfg *Config, newContent, provenance string, maxEntries int) error {
+	knowledgePath, err := getKnowledgeFilePath(cfg)
 	if err != nil {
 		return err
 	}
 
 	// Read existing content if file exists
-	var existingContent string
+	header, preamble, existingContent := defaultKnowledgeHeader, "", ""
 	if existingData, err := os.ReadFile(knowledgePath); err == nil {
-		existingContent = string(existingData)
-		// Remove the header and last updated line from existing content for clean prepending
-		lines := strings.Split(existingContent, "\n")
-		if len(lines) >= 3 && strings.HasPrefix(lines[0], "# Project Knowledge") {
-			// Skip header (line 0), empty line (line 1), and last updated line (line 2)
-			existingContent = strings.Join(lines[3:], "\n")
-			existingContent = strings.TrimSpace(existingContent)
-		}
+		header, preamble, existingContent = splitKnowledgeFile(string(existingData))
+	}
+	if preamble != "" {
+		header = fmt.Sprintf("%s\n\n%s\n\n%s", header, preamble, knowledgePreambleMarker)
+	}
+
+	if maxEntries > 0 {
+		// the new entry takes one of the slots
+		existingContent = pruneKnowledgeTimeline(existingContent, maxEntries-1)
+	}
+
+	if provenance != "" {
+		newContent = provenance + "\n\n" + newContent
 	}
 
 	// Create the new timestamped entry
@@ -249,16 +815,51 @@ func writeKnowledgeFile(newContent string) error {
 
 	if existingContent == "" || existingContent == "*This file will be automatically updated with project insights and important context.*" {
 		// First real content - no existing knowledge to preserve
-		finalContent = fmt.Sprintf("# Project Knowledge\n\n*Last updated: %s*\n\n%s", timestamp, newContent)
+		finalContent = fmt.Sprintf("%s\n\n*Last updated: %s*\n\n%s", header, timestamp, newContent)
 	} else {
 		// Prepend new content to existing content
-		finalContent = fmt.Sprintf("# Project Knowledge\n\n*Last updated: %s*\n\n## Latest Update (%s)\n\n%s\n\n---\n\n## Previous Knowledge\n\n%s", 
-			timestamp, timestamp, newContent, existingContent)
+		finalContent = fmt.Sprintf("%s\n\n*Last updated: %s*\n\n## Latest Update (%s)\n\n%s%s%s",
+			header, timestamp, timestamp, newContent, knowledgeTimelineSeparator, existingContent)
 	}
 
 	return os.WriteFile(knowledgePath, []byte(finalContent), 0644)
 }
 
+const knowledgeCommitMessage = "chore(xplane): update project knowledge"
+
+// commits the knowledge file on its own, refusing to when other changes are staged so they don't get mixed in
+func commitKnowledgeFile(gitRoot, knowledgePath string) error {
+	if _, err := runCommand(gitRoot, "git", "check-ignore", "-q", knowledgePath); err == nil {
+		return fmt.Errorf("%s is ignored by git, stop ignoring it to have it committed", knowledgePath)
+	}
+
+	staged, err := runCommand(gitRoot, "git", "diff", "--cached", "--name-only")
+	if err != nil {
+		return err
+	}
+	for _, file := range strings.Split(strings.TrimSpace(staged), "\n") {
+		if file != "" && file != knowledgePath {
+			return fmt.Errorf("other changes are staged (%s), not committing %s alongside them", file, knowledgePath)
+		}
+	}
+
+	if _, err := runCommand(gitRoot, "git", "add", "--", knowledgePath); err != nil {
+		return err
+	}
+	_, err = runCommand(gitRoot, "git", "commit", "-q", "-m", knowledgeCommitMessage, "--", knowledgePath)
+	return err
+}
+
+// a line that is only a KNOWLEDGE UPDATE header, e.g. '## Knowledge Update' or '**KNOWLEDGE UPDATE:**'
+var knowledgeUpdateHeaderRegex = regexp.MustCompile(`(?im)^[ \t]*(?:#{1,6}[ \t]*)?(?:\*\*)?KNOWLEDGE UPDATE:?(?:\*\*)?:?[ \t]*$`)
+
+// a custom template can ask for a KNOWLEDGE UPDATE section while knowledge is off, which silently drops it.
+// only looks for the header line rather than calling extractKnowledgeUpdate, whose length safeguard would log
+// noise here, and a summary merely mentioning knowledge updates in a sentence doesn't count
+func hasUnpersistedKnowledgeUpdate(cfg *Config, summary string) bool {
+	return !cfg.UseProjectKnowledge && knowledgeUpdateHeaderRegex.MatchString(summary)
+}
+
 // extractKnowledgeUpdate extracts knowledge update from LLM response
 func extractKnowledgeUpdate(response string) string {
 	lines := strings.Split(response, "\n")
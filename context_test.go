@@ -0,0 +1,564 @@
+package xplane
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseContextBlocks(t *testing.T) {
+	for name, format := range contextFormats {
+		t.Run(name, func(t *testing.T) {
+			// a readme quoting a header, of a known command or not, is still one block
+			readme := "# Title\n\n" + format.formatBlock("git_log", "not a block") + format.formatBlock("notes", "nor this")
+			raw := format.formatBlock("git_status", " M main.go\n") + format.formatBlock("readme", readme)
+
+			blocks := format.parseBlocks(raw, []string{"git_status", "readme"})
+
+			assert.Len(t, blocks, 2)
+			assert.Equal(t, contextBlock{source: "git_status", content: " M main.go\n"}, blocks[0])
+			assert.Equal(t, contextBlock{source: "readme", content: readme}, blocks[1])
+		})
+	}
+}
+
+func TestContextFormatBlock(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"plain", "---CONTEXT FROM: readme ---\n# Title\n\n"},
+		{"markdown", "## Context from: readme\n\n# Title\n\n"},
+		{"xml", "<context source=\"readme\">\n# Title\n</context>\n\n"},
+		{"", "---CONTEXT FROM: readme ---\n# Title\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			cfg := &Config{ContextFormat: tt.format}
+			assert.Equal(t, tt.expected, cfg.contextFormat().formatBlock("readme", "# Title"))
+		})
+	}
+}
+
+func TestCompactPreviousContext(t *testing.T) {
+	formatContextBlock := contextFormats[defaultContextFormat].formatBlock
+	tests := []struct {
+		name     string
+		previous string
+		current  string
+		expected string
+	}{
+		{
+			"unchanged blocks are collapsed",
+			formatContextBlock("readme", "same") + formatContextBlock("git_status", "old"),
+			formatContextBlock("readme", "same") + formatContextBlock("git_status", "new"),
+			formatContextBlock("readme", unchangedBlockPlaceholder) + formatContextBlock("git_status", "old"),
+		},
+		{
+			"blocks missing from current are kept",
+			formatContextBlock("tokei", "{}"),
+			formatContextBlock("readme", "same"),
+			formatContextBlock("tokei", "{}"),
+		},
+		{
+			"unparseable previous context is returned as is",
+			"legacy context",
+			formatContextBlock("readme", "same"),
+			"legacy context",
+		},
+		{
+			"output that looks like a header doesn't split a block",
+			formatContextBlock("readme", "same") + formatContextBlock("docs_diff", "+## Usage\n\n"+formatContextBlock("readme", "same")),
+			formatContextBlock("readme", "same") + formatContextBlock("docs_diff", "+## Usage\n\n"),
+			formatContextBlock("readme", unchangedBlockPlaceholder) + formatContextBlock("docs_diff", "+## Usage\n\n"+formatContextBlock("readme", "same")),
+		},
+		{
+			"blocks of commands no longer configured are kept as is",
+			formatContextBlock("removed", "old") + formatContextBlock("readme", "same"),
+			formatContextBlock("readme", "same"),
+			formatContextBlock("removed", "old") + formatContextBlock("readme", unchangedBlockPlaceholder),
+		},
+	}
+
+	sources := (&Config{Commands: []string{"readme", "git_status", "tokei", "docs_diff"}}).contextSources()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, compactPreviousContext(tt.previous, tt.current, contextFormats[defaultContextFormat], sources))
+		})
+	}
+}
+
+func TestWrapPrompt(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		suffix   string
+		expected string
+	}{
+		{"no wrapping", "", "", "prompt"},
+		{"prefix only", "Focus on security.", "", "Focus on security.\n\nprompt"},
+		{"suffix only", "", "Respond in French.", "prompt\n\nRespond in French."},
+		{"both", "Be brief.", "Respond in French.", "Be brief.\n\nprompt\n\nRespond in French."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, wrapPrompt("prompt", tt.prefix, tt.suffix))
+		})
+	}
+}
+
+func TestProjectKnowledgeDoesNotAffectContextComparison(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"README.md": "# Project\n", ".gitignore": "bin/\n"})
+	commands := []string{"readme", "gitignore"}
+
+	withoutKnowledge, err := gatherContext(&Config{Commands: commands, UseProjectKnowledge: false}, root, false)
+	assert.NoError(t, err)
+	withKnowledge, err := gatherContext(&Config{Commands: commands, UseProjectKnowledge: true}, root, false)
+	assert.NoError(t, err)
+
+	assert.Equal(t, withoutKnowledge, withKnowledge, "toggling knowledge must not look like a context change")
+	assert.NotContains(t, withKnowledge, "PROJECT KNOWLEDGE")
+
+	// the knowledge only ever lands in the prompt
+	template := "PREVIOUS:\n{{PREVIOUS_CONTEXT}}\nCURRENT:\n{{CURRENT_CONTEXT}}"
+	prompt := buildFinalPrompt(template, buildKnowledgeSection("# Project Knowledge", defaultKnowledgeInstructions, ""), withoutKnowledge, withKnowledge, &Config{})
+	assert.Contains(t, prompt, "--- PROJECT KNOWLEDGE ---\n# Project Knowledge")
+	assert.Contains(t, prompt, "PREVIOUS:\n"+withoutKnowledge)
+	assert.Contains(t, prompt, "CURRENT:\n"+withKnowledge)
+}
+
+func TestBuildFinalPromptLanguage(t *testing.T) {
+	template := "CURRENT:\n{{CURRENT_CONTEXT}}"
+	cfg := &Config{Language: "Spanish", PromptSuffix: "Be brief."}
+
+	prompt := buildFinalPrompt(template, buildKnowledgeSection("# Project Knowledge", defaultKnowledgeInstructions, cfg.Language), "old", "new", cfg)
+	assert.True(t, strings.HasSuffix(prompt, "Respond in Spanish.\n\nBe brief."))
+	assert.Contains(t, prompt, "Write the KNOWLEDGE UPDATE in Spanish")
+
+	prompt = buildFinalPrompt(template, buildKnowledgeSection("# Project Knowledge", defaultKnowledgeInstructions, ""), "old", "new", &Config{})
+	assert.NotContains(t, prompt, "Respond in")
+	assert.NotContains(t, prompt, "Write the KNOWLEDGE UPDATE in")
+}
+
+// records the prompts it gets instead of calling a real model
+type fakeLLM struct {
+	prompts     []string
+	err         error
+	onSummarize func()
+}
+
+func (f *fakeLLM) summarizeContext(finalPrompt string) (string, error) {
+	f.prompts = append(f.prompts, finalPrompt)
+	if f.onSummarize != nil {
+		f.onSummarize()
+	}
+	if f.err != nil {
+		return "", f.err
+	}
+	return "summary", nil
+}
+
+func (f *fakeLLM) getName() string {
+	return "fake"
+}
+
+func (f *fakeLLM) getModel() string {
+	return "fake-model"
+}
+
+func TestContextCompareFirstRun(t *testing.T) {
+	tests := []struct {
+		name              string
+		summarizeFirstRun bool
+		expectedPrompts   int
+		expectedStored    string
+	}{
+		{"only initializes by default", false, 0, "First run, no context available yet."},
+		{"summarizes against an empty baseline", true, 1, "# Project"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := initTestRepo(t, map[string]string{"README.md": "# Project\n"})
+			llm := &fakeLLM{}
+			cfg := &Config{Commands: []string{"readme"}, SummarizeFirstRun: tt.summarizeFirstRun}
+
+			assert.NoError(t, contextCompare(llm, cfg, root))
+
+			assert.Len(t, llm.prompts, tt.expectedPrompts)
+			if tt.expectedPrompts > 0 {
+				assert.Contains(t, llm.prompts[0], "First run, no context available yet.")
+			}
+			stored, err := os.ReadFile(filepath.Join(root, contextDir, dynamicContextFile))
+			assert.NoError(t, err)
+			assert.Contains(t, string(stored), tt.expectedStored)
+		})
+	}
+}
+
+func TestContextCompareLLMFailure(t *testing.T) {
+	tests := []struct {
+		name             string
+		advanceOnFailure bool
+		expectedStored   string
+	}{
+		{"keeps the baseline by default", false, "First run, no context available yet."},
+		{"advances when asked to", true, "# Project"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := initTestRepo(t, map[string]string{"README.md": "# Project\n"})
+			llm := &fakeLLM{err: errors.New("rate limited")}
+			cfg := &Config{Commands: []string{"readme"}, SummarizeFirstRun: true, AdvanceOnFailure: tt.advanceOnFailure}
+			assert.NoError(t, writeDynamicContext(root, createPlaceHolderContext(cfg), false))
+
+			assert.NoError(t, contextCompare(llm, cfg, root))
+
+			assert.Len(t, llm.prompts, 1)
+			stored, err := os.ReadFile(filepath.Join(root, contextDir, dynamicContextFile))
+			assert.NoError(t, err)
+			assert.Contains(t, string(stored), tt.expectedStored)
+		})
+	}
+}
+
+func TestContextCompareSavesPrompt(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"README.md": "# Project\n"})
+	llm := &fakeLLM{}
+	cfg := &Config{Commands: []string{"readme"}, SummarizeFirstRun: true, SavePrompt: true}
+
+	assert.NoError(t, contextCompare(llm, cfg, root))
+
+	saved, err := os.ReadFile(filepath.Join(root, contextDir, lastPromptFile))
+	assert.NoError(t, err)
+	assert.Equal(t, llm.prompts[0], string(saved))
+}
+
+func TestResolveIncludes(t *testing.T) {
+	dir := t.TempDir()
+	shared := filepath.Join(t.TempDir(), "org.txt")
+	assert.NoError(t, os.WriteFile(shared, []byte("Org rules.\n"), 0o644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "fragments"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "fragments", "style.txt"), []byte("Style {{INCLUDE:tone.txt}}\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "fragments", "tone.txt"), []byte("be brief\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("{{INCLUDE:b.txt}}"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("{{INCLUDE:a.txt}}"), 0o644))
+
+	t.Run("relative, nested and absolute includes", func(t *testing.T) {
+		template := "{{INCLUDE:" + shared + "}}\n{{INCLUDE: fragments/style.txt }}\n{{CURRENT_CONTEXT}}"
+		resolved, err := resolveIncludes(template, dir, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "Org rules.\nStyle be brief\n{{CURRENT_CONTEXT}}", resolved)
+	})
+
+	t.Run("circular include", func(t *testing.T) {
+		_, err := resolveIncludes("{{INCLUDE:a.txt}}", dir, nil)
+		assert.ErrorContains(t, err, "circular include")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := resolveIncludes("{{INCLUDE:missing.txt}}", dir, nil)
+		assert.ErrorContains(t, err, "could not include")
+	})
+}
+
+func TestWriteKnowledgeFileProvenance(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"README.md": "# Project\n"})
+	t.Chdir(root)
+
+	provenance := knowledgeProvenance("ollama", "llama3", "context")
+	assert.Regexp(t, `^\*Generated by ollama \(llama3\) from context [0-9a-f]{12}\*$`, provenance)
+	assert.Equal(t, provenance, knowledgeProvenance("ollama", "llama3", "context"), "same context, same hash")
+	assert.Contains(t, knowledgeProvenance("claude_code", "", "context"), "(default model)")
+
+	assert.NoError(t, writeKnowledgeFile(&Config{}, "- first insight", "", 0))
+	assert.NoError(t, writeKnowledgeFile(&Config{}, "- second insight", provenance, 0))
+
+	written, err := os.ReadFile(filepath.Join(root, contextDir, knowledgeFile))
+	assert.NoError(t, err)
+	assert.Regexp(t, `## Latest Update \([^)]+\)\n\n`+regexp.QuoteMeta(provenance)+"\n\n- second insight\n", string(written))
+	assert.Equal(t, 1, strings.Count(string(written), "Generated by"))
+}
+
+func TestWriteKnowledgeFileMaxEntries(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"README.md": "# Project\n"})
+	t.Chdir(root)
+	knowledgePath := filepath.Join(root, contextDir, knowledgeFile)
+
+	for _, insight := range []string{"- first", "- second", "- third", "- fourth"} {
+		assert.NoError(t, writeKnowledgeFile(&Config{}, insight, "", 0))
+	}
+	unlimited, err := os.ReadFile(knowledgePath)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(unlimited), "## Previous Knowledge"))
+
+	assert.NoError(t, writeKnowledgeFile(&Config{}, "- fifth", "", 3))
+	pruned, err := os.ReadFile(knowledgePath)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(pruned), "## Previous Knowledge"))
+	assert.Contains(t, string(pruned), "- fifth")
+	assert.Contains(t, string(pruned), "- third")
+	assert.NotContains(t, string(pruned), "- second")
+	assert.NotContains(t, string(pruned), "- first")
+
+	assert.NoError(t, writeKnowledgeFile(&Config{}, "- sixth", "", 1))
+	latestOnly, err := os.ReadFile(knowledgePath)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^# Project Knowledge\n\n\*Last updated: [^*]+\*\n\n- sixth$`, string(latestOnly))
+}
+
+func TestWriteKnowledgeFileCustomPath(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"README.md": "# Project\n"})
+	t.Chdir(root)
+	cfg := &Config{KnowledgeFile: filepath.Join("docs", "ARCHITECTURE.md")}
+
+	// the directory doesn't exist yet
+	assert.NoError(t, writeKnowledgeFile(cfg, "- first insight", "", 0))
+	written, err := os.ReadFile(filepath.Join(root, "docs", "ARCHITECTURE.md"))
+	assert.NoError(t, err)
+	assert.Regexp(t, `^# Project Knowledge\n\n\*Last updated: [^*]+\*\n\n- first insight$`, string(written))
+	assert.NoFileExists(t, filepath.Join(root, contextDir, knowledgeFile))
+
+	// an existing doc keeps its title and its text, which pruning never drops
+	existing := "# Architecture\n\nThe API talks to Postgres.\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "docs", "ARCHITECTURE.md"), []byte(existing), 0o644))
+	assert.NoError(t, writeKnowledgeFile(cfg, "- added a cache", "", 1))
+	assert.NoError(t, writeKnowledgeFile(cfg, "- added a queue", "", 1))
+	written, err = os.ReadFile(filepath.Join(root, "docs", "ARCHITECTURE.md"))
+	assert.NoError(t, err)
+	prefix := "# Architecture\n\nThe API talks to Postgres.\n\n" + knowledgePreambleMarker + "\n\n"
+	assert.Regexp(t, "^"+regexp.QuoteMeta(prefix)+`\*Last updated: [^*]+\*\n\n- added a queue$`, string(written))
+}
+
+func TestSplitKnowledgeFile(t *testing.T) {
+	tests := []struct {
+		name             string
+		content          string
+		expectedHeader   string
+		expectedPreamble string
+		expectedBody     string
+	}{
+		{
+			name:           "written by xplane",
+			content:        "# Project Knowledge\n\n*Last updated: 2025-08-27 20:25:35*\n\n- insight\n",
+			expectedHeader: "# Project Knowledge",
+			expectedBody:   "- insight",
+		},
+		{
+			name:             "existing doc with a title",
+			content:          "# Architecture\n\nThe API talks to Postgres.\n",
+			expectedHeader:   "# Architecture",
+			expectedPreamble: "The API talks to Postgres.",
+		},
+		{
+			name:             "existing doc xplane wrote to",
+			content:          "# Architecture\n\nThe API talks to Postgres.\n\n" + knowledgePreambleMarker + "\n\n*Last updated: 2025-08-27 20:25:35*\n\n- insight\n",
+			expectedHeader:   "# Architecture",
+			expectedPreamble: "The API talks to Postgres.",
+			expectedBody:     "- insight",
+		},
+		{
+			name:             "no title",
+			content:          "Some notes.\n\nMore notes.\n",
+			expectedHeader:   "# Project Knowledge",
+			expectedPreamble: "Some notes.\n\nMore notes.",
+		},
+		{
+			name:           "empty file",
+			content:        "",
+			expectedHeader: "# Project Knowledge",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, preamble, body := splitKnowledgeFile(tt.content)
+			assert.Equal(t, tt.expectedHeader, header)
+			assert.Equal(t, tt.expectedPreamble, preamble)
+			assert.Equal(t, tt.expectedBody, body)
+		})
+	}
+}
+
+func TestOwnFilesOutsideContextDir(t *testing.T) {
+	assert.Empty(t, (&Config{UseProjectKnowledge: true}).ownFilesOutsideContextDir())
+	assert.Empty(t, (&Config{KnowledgeFile: "docs/ARCHITECTURE.md"}).ownFilesOutsideContextDir(), "not written while knowledge is off")
+	cfg := &Config{UseProjectKnowledge: true, KnowledgeFile: "docs/ARCHITECTURE.md"}
+	assert.Equal(t, []string{"docs/ARCHITECTURE.md"}, cfg.ownFilesOutsideContextDir())
+
+	root := initTestRepo(t, map[string]string{"README.md": "# Project\n", "docs/ARCHITECTURE.md": "# Architecture\n"})
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "docs", "ARCHITECTURE.md"), []byte("# Architecture\nupdated\n"), 0o644))
+	status, err := getGitStatus(root, cfg.ownFilesOutsideContextDir()...)
+	assert.NoError(t, err)
+	assert.Empty(t, status)
+}
+
+func TestHasUnpersistedKnowledgeUpdate(t *testing.T) {
+	withUpdate := "## Summary\nAll good.\n\n## KNOWLEDGE UPDATE\n- the cache lives in .xplane/cache"
+	withoutUpdate := "## Summary\nAll good."
+	mentioningUpdate := "## Summary\nThe README now documents the knowledge update flow."
+
+	assert.True(t, hasUnpersistedKnowledgeUpdate(&Config{}, withUpdate))
+	assert.True(t, hasUnpersistedKnowledgeUpdate(&Config{}, "All good.\n\n**Knowledge Update:**\n- a fact"))
+	assert.False(t, hasUnpersistedKnowledgeUpdate(&Config{UseProjectKnowledge: true}, withUpdate))
+	assert.False(t, hasUnpersistedKnowledgeUpdate(&Config{}, withoutUpdate))
+	assert.False(t, hasUnpersistedKnowledgeUpdate(&Config{}, mentioningUpdate))
+}
+
+func TestCommitKnowledgeFile(t *testing.T) {
+	t.Run("commits only the knowledge file", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+		t.Setenv("GIT_AUTHOR_NAME", "xplane")
+		t.Setenv("GIT_AUTHOR_EMAIL", "xplane@example.com")
+		t.Setenv("GIT_COMMITTER_NAME", "xplane")
+		t.Setenv("GIT_COMMITTER_EMAIL", "xplane@example.com")
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, contextDir), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(root, contextDir, knowledgeFile), []byte("# Project Knowledge\n"), 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(root, "main.go"), []byte("package lib\n"), 0o644))
+
+		assert.NoError(t, commitKnowledgeFile(root, filepath.Join(contextDir, knowledgeFile)))
+
+		committed, err := runCommand(root, "git", "show", "--name-only", "--format=%s", "HEAD")
+		assert.NoError(t, err)
+		assert.Equal(t, knowledgeCommitMessage+"\n\n.xplane/KNOWLEDGE.md\n", committed)
+	})
+
+	t.Run("refuses with other staged changes", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, contextDir), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(root, contextDir, knowledgeFile), []byte("# Project Knowledge\n"), 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(root, "main.go"), []byte("package lib\n"), 0o644))
+		_, err := runCommand(root, "git", "add", "main.go")
+		assert.NoError(t, err)
+
+		assert.ErrorContains(t, commitKnowledgeFile(root, filepath.Join(contextDir, knowledgeFile)), "other changes are staged (main.go)")
+	})
+
+	t.Run("refuses when ignored", func(t *testing.T) {
+		root := initTestRepo(t, map[string]string{".gitignore": ".xplane/\n"})
+		assert.ErrorContains(t, commitKnowledgeFile(root, filepath.Join(contextDir, knowledgeFile)), "is ignored by git")
+	})
+}
+
+func TestGatherContextIncludesFiles(t *testing.T) {
+	root := initTestRepo(t, map[string]string{
+		"docs/adr.md": "# ADR 7\nUse tokens.\n",
+		"big.txt":     strings.Repeat("x", maxIncludedFileLength+5),
+	})
+	cfg := &Config{IncludeFiles: []string{"docs/adr.md", "missing.md", "big.txt"}}
+
+	context, err := gatherContext(cfg, root, false)
+	assert.NoError(t, err)
+
+	format := cfg.contextFormat()
+	assert.Contains(t, context, format.formatBlock("file:docs/adr.md", "# ADR 7\nUse tokens.\n"))
+	assert.Contains(t, context, format.formatBlock("file:missing.md", "File 'missing.md' not found."))
+	assert.Contains(t, context, "[file truncated, 5 more characters]")
+}
+
+func TestPrefetchRemoteState(t *testing.T) {
+	remote := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+	_, err := runCommand(remote, "git", "branch", "merged-feature")
+	assert.NoError(t, err)
+	local := t.TempDir()
+	_, err = runCommand(local, "git", "clone", "-q", remote, ".")
+	assert.NoError(t, err)
+
+	_, err = runCommand(remote, "git", "branch", "-D", "merged-feature")
+	assert.NoError(t, err)
+	_, err = runCommand(local, "git", "rev-parse", "--verify", "--quiet", "refs/remotes/origin/merged-feature")
+	assert.NoError(t, err, "still known locally before the fetch")
+
+	prefetchRemoteState(&Config{}, local)
+
+	_, err = runCommand(local, "git", "rev-parse", "--verify", "--quiet", "refs/remotes/origin/merged-feature")
+	assert.Error(t, err, "pruned after the fetch")
+
+	// an unreachable remote is only a warning
+	_, err = runCommand(local, "git", "remote", "set-url", "origin", filepath.Join(t.TempDir(), "gone"))
+	assert.NoError(t, err)
+	prefetchRemoteState(&Config{}, local)
+}
+
+func TestGatherContextExpandsCommandAliases(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n// TODO: handle errors\n"})
+	cfg := &Config{
+		Commands:       []string{"todos"},
+		CommandAliases: map[string]string{"todos": "git grep -n 'TODO: handle'"},
+	}
+
+	output, err := gatherContext(cfg, root, false)
+	assert.NoError(t, err)
+	blocks := cfg.contextFormat().parseBlocks(output, cfg.contextSources())
+	assert.Len(t, blocks, 1)
+	assert.Equal(t, "todos", blocks[0].source)
+	assert.Contains(t, blocks[0].content, "main.go:2:// TODO: handle errors")
+}
+
+func TestGatherContextTimeBudget(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"README.md": "# Project\n"})
+	cfg := &Config{Commands: []string{"readme", "git_status"}, IncludeFiles: []string{"README.md"}, MaxRuntime: time.Nanosecond}
+
+	output, err := gatherContext(cfg, root, false)
+	assert.NoError(t, err)
+	blocks := cfg.contextFormat().parseBlocks(output, cfg.contextSources())
+	assert.Len(t, blocks, 1)
+	assert.Equal(t, "time_budget", blocks[0].source)
+	assert.Equal(t, "Skipped 3 commands due to time budget: readme, git_status, file:README.md", strings.TrimSpace(blocks[0].content))
+
+	cfg.MaxRuntime = time.Hour
+	output, err = gatherContext(cfg, root, false)
+	assert.NoError(t, err)
+	assert.NotContains(t, output, "time_budget")
+}
+
+func TestGatherContextEmptyOutput(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"README.md": "# Project\n"})
+	cfg := &Config{
+		Commands:       []string{"git_status", "quiet"},
+		CommandAliases: map[string]string{"quiet": "true"},
+	}
+
+	output, err := gatherContext(cfg, root, false)
+	assert.NoError(t, err)
+	blocks := cfg.contextFormat().parseBlocks(output, cfg.contextSources())
+	assert.Len(t, blocks, 2)
+	assert.Equal(t, "Working tree clean, no uncommitted or untracked changes.", strings.TrimSpace(blocks[0].content))
+	assert.Equal(t, "No output from 'quiet'.", strings.TrimSpace(blocks[1].content))
+}
+
+func TestGatherContextAnonymizesCachedOutput(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n"})
+	_, err := runCommand(root, "git", "remote", "add", "origin", "https://github.com/acme/app.git")
+	assert.NoError(t, err)
+	cfg := &Config{Commands: []string{"github_prs"}, GithubToken: "token", RemoteCacheTTL: time.Minute}
+
+	// cached by a run without anonymization
+	key, ok := remoteCacheKey(cfg, root, "github_prs")
+	assert.True(t, ok)
+	assert.NoError(t, writeCachedOutput(root, key, "- Fix login (by octocat)\n"))
+	assert.NoError(t, writeCachedOutput(root, cachedAuthorsKey(key), "octocat"))
+	output, err := gatherContext(cfg, root, false)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "(by octocat)")
+
+	// turning anonymization on doesn't serve that entry, and the one it does is anonymized once read
+	cfg.AnonymizeAuthors = true
+	anonymizedKey, _ := remoteCacheKey(cfg, root, "github_prs")
+	assert.NotEqual(t, key, anonymizedKey)
+	assert.NoError(t, writeCachedOutput(root, anonymizedKey, "- Fix login (by octocat)\n"))
+	assert.NoError(t, writeCachedOutput(root, cachedAuthorsKey(anonymizedKey), "octocat"))
+	output, err = gatherContext(cfg, root, false)
+	assert.NoError(t, err)
+	assert.NotContains(t, output, "octocat")
+	assert.Contains(t, output, "(by Author ")
+}
@@ -0,0 +1,128 @@
+package xplane
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const coverageCacheFile = "coverage_cache.txt"
+
+// report locations probed in order, paired with the parser understanding their format
+var coverageReports = []struct {
+	path  string
+	parse func(content string) (float64, error)
+}{
+	{"coverage.out", parseGoCoverProfile},
+	{"cover.out", parseGoCoverProfile},
+	{"coverage.xml", parseCoberturaLineRate},
+	{filepath.Join("coverage", "cobertura-coverage.xml"), parseCoberturaLineRate},
+	{"lcov.info", parseLcov},
+	{filepath.Join("coverage", "lcov.info"), parseLcov},
+}
+
+var coberturaLineRateRegex = regexp.MustCompile(`<coverage[^>]*\sline-rate="([\d.]+)"`)
+
+// computes the statement coverage percentage of a `go test -coverprofile` output
+func parseGoCoverProfile(content string) (float64, error) {
+	var total, covered int
+	for _, line := range strings.Split(content, "\n") {
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// file.go:startLine.startCol,endLine.endCol numStatements hitCount
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return 0, fmt.Errorf("unexpected cover profile line: %q", line)
+		}
+		statements, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, fmt.Errorf("unexpected cover profile line: %q", line)
+		}
+		hits, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, fmt.Errorf("unexpected cover profile line: %q", line)
+		}
+		total += statements
+		if hits > 0 {
+			covered += statements
+		}
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("cover profile has no statements")
+	}
+	return float64(covered) / float64(total) * 100, nil
+}
+
+// reads the overall line-rate attribute of a Cobertura xml report
+func parseCoberturaLineRate(content string) (float64, error) {
+	matches := coberturaLineRateRegex.FindStringSubmatch(content)
+	if matches == nil {
+		return 0, fmt.Errorf("no line-rate attribute found in cobertura report")
+	}
+	rate, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	return rate * 100, nil
+}
+
+// sums the lines found (LF) and hit (LH) records of an lcov tracefile
+func parseLcov(content string) (float64, error) {
+	var found, hit int
+	for _, line := range strings.Split(content, "\n") {
+		if value, ok := strings.CutPrefix(line, "LF:"); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return 0, err
+			}
+			found += n
+		} else if value, ok := strings.CutPrefix(line, "LH:"); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return 0, err
+			}
+			hit += n
+		}
+	}
+	if found == 0 {
+		return 0, fmt.Errorf("lcov report has no lines")
+	}
+	return float64(hit) / float64(found) * 100, nil
+}
+
+// reports the total coverage of the first report found, along with the delta from the previous total.
+// without record the cached totals are only read, for dry runs
+func getCoverage(gitRoot string, record bool) (string, error) {
+	for _, report := range coverageReports {
+		content, err := os.ReadFile(filepath.Join(gitRoot, report.path))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		percentage, err := report.parse(string(content))
+		if err != nil {
+			return fmt.Sprintf("Could not parse coverage report '%s': %v", report.path, err), nil
+		}
+		current := strconv.FormatFloat(percentage, 'f', 1, 64)
+
+		previous, err := trackValue(gitRoot, coverageCacheFile, current, record)
+		if err != nil {
+			return "", fmt.Errorf("could not cache coverage: %w", err)
+		}
+
+		output := fmt.Sprintf("Total coverage from '%s': %s%%", report.path, current)
+		if previous != "" {
+			previousPercentage, _ := strconv.ParseFloat(previous, 64)
+			output += fmt.Sprintf(" (previously %s%%, delta %+.1f points)", previous, percentage-previousPercentage)
+		}
+		return output, nil
+	}
+
+	return "No coverage report found (looked for coverage.out, coverage.xml, lcov.info).", nil
+}
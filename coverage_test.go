@@ -0,0 +1,66 @@
+package xplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCoverageReports(t *testing.T) {
+	tests := []struct {
+		name      string
+		parse     func(string) (float64, error)
+		content   string
+		expected  float64
+		errorsOut bool
+	}{
+		{"go cover profile", parseGoCoverProfile, "mode: set\na.go:1.1,2.2 3 1\na.go:3.1,4.2 1 0\n", 75, false},
+		{"empty go cover profile", parseGoCoverProfile, "mode: set\n", 0, true},
+		{"malformed go cover profile", parseGoCoverProfile, "mode: set\nnot a profile\n", 0, true},
+		{"cobertura", parseCoberturaLineRate, `<?xml version="1.0"?><coverage branch-rate="0" line-rate="0.782" version="1">`, 78.2, false},
+		{"cobertura without rate", parseCoberturaLineRate, `<coverage version="1">`, 0, true},
+		{"lcov", parseLcov, "SF:a.js\nLF:10\nLH:5\nend_of_record\nSF:b.js\nLF:10\nLH:10\nend_of_record\n", 75, false},
+		{"empty lcov", parseLcov, "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			percentage, err := tt.parse(tt.content)
+			if tt.errorsOut {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.InDelta(t, tt.expected, percentage, 0.001)
+			}
+		})
+	}
+}
+
+func TestGetCoverage(t *testing.T) {
+	root := t.TempDir()
+
+	output, err := getCoverage(root, true)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "No coverage report found")
+
+	writeProfile := func(profile string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, "coverage.out"), []byte(profile), 0o644))
+	}
+
+	writeProfile("mode: set\na.go:1.1,2.2 4 1\na.go:3.1,4.2 1 0\n")
+	output, err = getCoverage(root, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "Total coverage from 'coverage.out': 80.0%", output)
+
+	writeProfile("mode: set\na.go:1.1,2.2 3 1\na.go:3.1,4.2 2 0\n")
+	output, err = getCoverage(root, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "Total coverage from 'coverage.out': 60.0% (previously 80.0%, delta -20.0 points)", output)
+
+	// unchanged coverage keeps reporting the last delta, so the context stays stable between runs
+	output, err = getCoverage(root, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "Total coverage from 'coverage.out': 60.0% (previously 80.0%, delta -20.0 points)", output)
+}
@@ -0,0 +1,76 @@
+package xplane
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const dotEnvFile = ".env"
+
+// variables xplane reads besides the XPLANE_ prefixed ones, anything else in a .env file belongs to the project
+var dotEnvVariables = map[string]bool{
+	"GITHUB_TOKEN":          true,
+	"GITLAB_TOKEN":          true,
+	"OLLAMA_HOST":           true,
+	"USE_PROJECT_KNOWLEDGE": true,
+}
+
+// minimal KEY=VALUE parser: blank lines and '#' comments are skipped, 'export ' prefixes and matching quotes are dropped
+func parseDotEnv(content string) (map[string]string, error) {
+	values := make(map[string]string)
+	for i, line := range strings.Split(content, "\n") {
+		trimmedLine := strings.TrimSpace(line)
+		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "#") {
+			continue
+		}
+		trimmedLine = strings.TrimPrefix(trimmedLine, "export ")
+
+		key, value, found := strings.Cut(trimmedLine, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", i+1)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		} else if comment := strings.Index(value, " #"); comment != -1 {
+			value = strings.TrimSpace(value[:comment])
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// loads .xplane/.env and then the repo root .env into the environment, only for variables xplane reads.
+// variables that are already set win, and so does .xplane/.env over the root one
+func loadDotEnvFiles(gitRoot string) error {
+	for _, path := range []string{
+		filepath.Join(gitRoot, contextDir, dotEnvFile),
+		filepath.Join(gitRoot, dotEnvFile),
+	} {
+		content, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("could not read %s: %w", path, err)
+		}
+
+		values, err := parseDotEnv(string(content))
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", path, err)
+		}
+		for key, value := range values {
+			if !strings.HasPrefix(key, "XPLANE_") && !dotEnvVariables[key] {
+				continue
+			}
+			if _, isSet := os.LookupEnv(key); isSet {
+				continue
+			}
+			os.Setenv(key, value)
+		}
+	}
+	return nil
+}
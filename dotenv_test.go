@@ -0,0 +1,71 @@
+package xplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expected    map[string]string
+		expectError bool
+	}{
+		{
+			"plain values, comments and blank lines",
+			"# tokens\nGITHUB_TOKEN=abc\n\nXPLANE_PROVIDER = ollama\n",
+			map[string]string{"GITHUB_TOKEN": "abc", "XPLANE_PROVIDER": "ollama"},
+			false,
+		},
+		{
+			"quotes and export",
+			"export XPLANE_MODEL=\"llama3 8b\"\nXPLANE_PROMPT_PREFIX='Be # brief'\n",
+			map[string]string{"XPLANE_MODEL": "llama3 8b", "XPLANE_PROMPT_PREFIX": "Be # brief"},
+			false,
+		},
+		{
+			"inline comment",
+			"XPLANE_PROVIDER=gemini # the api one\n",
+			map[string]string{"XPLANE_PROVIDER": "gemini"},
+			false,
+		},
+		{"missing equals", "GITHUB_TOKEN\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := parseDotEnv(tt.content)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, values)
+		})
+	}
+}
+
+func TestLoadDotEnvFiles(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, contextDir), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, contextDir, dotEnvFile), []byte("XPLANE_MODEL=from-xplane-dir\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, dotEnvFile), []byte("XPLANE_MODEL=from-root\nXPLANE_PROVIDER=ollama\nGITLAB_TOKEN=from-root\nDATABASE_URL=postgres://\n"), 0o644))
+
+	t.Setenv("GITLAB_TOKEN", "from-shell")
+	for _, key := range []string{"XPLANE_MODEL", "XPLANE_PROVIDER", "DATABASE_URL"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	assert.NoError(t, loadDotEnvFiles(root))
+
+	assert.Equal(t, "from-xplane-dir", os.Getenv("XPLANE_MODEL"))
+	assert.Equal(t, "ollama", os.Getenv("XPLANE_PROVIDER"))
+	assert.Equal(t, "from-shell", os.Getenv("GITLAB_TOKEN"))
+	_, isSet := os.LookupEnv("DATABASE_URL")
+	assert.False(t, isSet, "unrelated project variables are left alone")
+}
@@ -0,0 +1,95 @@
+package xplane
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// where the stored context lives, with XPLANE_COMPRESS_CONTEXT it's gzipped next to where the plain one would be
+func dynamicContextPaths(gitRoot string, compressed bool) (preferred, other string) {
+	plain := filepath.Join(gitRoot, contextDir, dynamicContextFile)
+	if compressed {
+		return plain + ".gz", plain
+	}
+	return plain, plain + ".gz"
+}
+
+// reads the stored context from wherever it is, so toggling compression doesn't lose it.
+// migrate is true when it was found in the format that isn't configured, errors wrap fs.ErrNotExist when there's none
+func readDynamicContext(gitRoot string, compressed bool) (content string, migrate bool, err error) {
+	preferred, other := dynamicContextPaths(gitRoot, compressed)
+	content, err = readContextFile(preferred)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return content, false, err
+	}
+	content, err = readContextFile(other)
+	return content, err == nil, err
+}
+
+func readContextFile(path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil || filepath.Ext(path) != ".gz" {
+		return string(raw), err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	return string(content), err
+}
+
+// writes the stored context in the configured format and drops the one in the other format, if any.
+// the new content goes to a temporary file first, so a failed write leaves the previous context whole
+func writeDynamicContext(gitRoot, content string, compressed bool) error {
+	preferred, other := dynamicContextPaths(gitRoot, compressed)
+	if err := os.MkdirAll(filepath.Dir(preferred), 0o755); err != nil {
+		return err
+	}
+
+	data := []byte(content)
+	if compressed {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	if err := replaceFile(preferred, data); err != nil {
+		return err
+	}
+	if err := os.Remove(other); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// writes data next to path and renames it over path, a rename within a directory doesn't leave a half written file
+func replaceFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // a no-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
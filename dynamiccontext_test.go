@@ -0,0 +1,91 @@
+package xplane
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamicContextCompression(t *testing.T) {
+	root := t.TempDir()
+	plainPath := filepath.Join(root, contextDir, dynamicContextFile)
+
+	_, _, err := readDynamicContext(root, true)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	assert.NoError(t, writeDynamicContext(root, "plain context", false))
+	content, migrate, err := readDynamicContext(root, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "plain context", content, "an existing plain file is still read")
+	assert.True(t, migrate)
+
+	assert.NoError(t, writeDynamicContext(root, content, true))
+	assert.NoFileExists(t, plainPath, "replaced by the compressed one")
+	compressed, err := os.ReadFile(plainPath + ".gz")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x1f, 0x8b}, compressed[:2], "gzip magic number")
+
+	content, migrate, err = readDynamicContext(root, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "plain context", content)
+	assert.False(t, migrate)
+
+	content, migrate, err = readDynamicContext(root, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "plain context", content, "turning compression off keeps the stored context")
+	assert.True(t, migrate)
+}
+
+func TestWriteDynamicContextFailureKeepsPrevious(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, writeDynamicContext(root, "previous context", false))
+	// a directory where the compressed file should go makes the final rename fail
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, contextDir, dynamicContextFile+".gz", "blocker"), 0o755))
+
+	assert.Error(t, writeDynamicContext(root, "new context", true))
+
+	content, _, err := readDynamicContext(root, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "previous context", content)
+	leftovers, err := filepath.Glob(filepath.Join(root, contextDir, "*.tmp"))
+	assert.NoError(t, err)
+	assert.Empty(t, leftovers, "the temporary file is cleaned up")
+}
+
+func TestContextCompareSurfacesWriteFailure(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"README.md": "# Project\n"})
+	cfg := &Config{Commands: []string{"readme"}}
+	assert.NoError(t, writeDynamicContext(root, createPlaceHolderContext(cfg), false))
+	storedPath := filepath.Join(root, contextDir, dynamicContextFile)
+	llm := &fakeLLM{onSummarize: func() {
+		// swapped for a directory once read, so storing the new context fails
+		assert.NoError(t, os.Remove(storedPath))
+		assert.NoError(t, os.MkdirAll(filepath.Join(storedPath, "blocker"), 0o755))
+	}}
+
+	err := contextCompare(llm, cfg, root)
+	assert.ErrorContains(t, err, "could not store the new context")
+	leftovers, globErr := filepath.Glob(filepath.Join(root, contextDir, "*.tmp"))
+	assert.NoError(t, globErr)
+	assert.Empty(t, leftovers)
+}
+
+func TestContextCompareMigratesToCompressed(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"README.md": "# Project\n"})
+	cfg := &Config{Commands: []string{"readme"}}
+	assert.NoError(t, contextCompare(&fakeLLM{}, cfg, root))
+	assert.FileExists(t, filepath.Join(root, contextDir, dynamicContextFile))
+
+	cfg.CompressContext = true
+	llm := &fakeLLM{}
+	assert.NoError(t, contextCompare(llm, cfg, root))
+
+	assert.Len(t, llm.prompts, 1, "the placeholder from the first run was read back")
+	assert.NoFileExists(t, filepath.Join(root, contextDir, dynamicContextFile))
+	stored, _, err := readDynamicContext(root, true)
+	assert.NoError(t, err)
+	assert.Contains(t, stored, "# Project")
+}
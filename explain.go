@@ -0,0 +1,86 @@
+package xplane
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// one line per built-in command, shown by --explain. every entry of specialCommandToBinMap needs one
+var commandDescriptions = map[string]string{
+	"git_status":         "uncommitted and untracked files",
+	"git_log":            "the last 15 commits",
+	"git_log_patches":    "the last 5 commits with their patches",
+	"diff_since_release": "everything that changed since the latest tag",
+	"branch_diff":        "everything the current branch added since it forked off its base",
+	"git_diff":           "the full uncommitted diff",
+	"diff_summary":       "uncommitted line changes per file",
+	"git_exclude":        "local git exclusions from .git/info/exclude",
+	"gitignore":          "project-wide git exclusions from .gitignore",
+	"readme":             "the project README",
+	"recent_blame":       "who owns the lines of the files with uncommitted changes",
+	"container_diff":     "uncommitted changes to Dockerfiles and compose files",
+	"ci_config_diff":     "uncommitted changes to CI pipelines and build config",
+	"docs_diff":          "uncommitted changes to documentation",
+	"test_diff":          "uncommitted changes to test files",
+	"api_spec_diff":      "uncommitted changes to OpenAPI/Swagger specs",
+	"git_submodules":     "submodule pointers and whether they're in sync",
+	"rerere_status":      "recorded merge conflict resolutions",
+	"reverts":            "the last 10 revert commits",
+	"path_history":       "the last 5 commits touching XPLANE_HISTORY_PATH, with their patches",
+	"github_prs":         "open GitHub pull requests",
+	"gitlab_mrs":         "open GitLab merge requests",
+	"release":            "the latest release",
+	"recent_releases":    "the last 10 releases, for the release cadence",
+	"git_branch_status":  "how far the current branch is ahead of or behind its base",
+	"shipped_issues":     "closed issues referenced by recent commits",
+	"merge_status":       "whether the current branch's PR/MR is ready to merge",
+	"pr_overlap":         "open PRs/MRs changing the same files as the current branch",
+	"stale_branches":     "remote branches without recent commits",
+	"mergeable_prs":      "open PRs/MRs cleared for merge",
+	"github_discussions": "recently active GitHub Discussions",
+	"tokei":              "code statistics per language",
+	"ripsecrets":         "potentially leaked secrets",
+	"license":            "the project license and changes to it",
+	"conflict_markers":   "tracked files still holding merge conflict markers",
+	"coverage":           "total test coverage and its change since the last run",
+	"build_size":         "runs XPLANE_BUILD_CMD and reports the artifact size and its change",
+}
+
+// what each configured command and included file is about to add to the context, and what it needs to run
+func explainCommands(cfg *Config) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("xplane: Gathering context from %d sources:\n", len(cfg.Commands)+len(cfg.IncludeFiles)))
+	writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+	for _, command := range cfg.Commands {
+		trimmedCmd := strings.TrimSpace(command)
+		fmt.Fprintf(writer, "  %s\t%s\n", trimmedCmd, explainCommand(cfg, trimmedCmd))
+	}
+	for _, path := range cfg.IncludeFiles {
+		fmt.Fprintf(writer, "  file:%s\tthe contents of %s (XPLANE_INCLUDE_FILES)\n", path, path)
+	}
+	writer.Flush()
+	return builder.String()
+}
+
+func explainCommand(cfg *Config, command string) string {
+	description, isBuiltIn := commandDescriptions[command]
+	if !isBuiltIn {
+		if expansion, isAlias := cfg.CommandAliases[command]; isAlias {
+			return fmt.Sprintf("the output of '%s' (XPLANE_COMMAND_ALIASES)", expansion)
+		}
+		return fmt.Sprintf("the output of '%s <git root>', a custom command", command)
+	}
+
+	var needs []string
+	if bin := specialCommandToBinMap[command]; bin != "" {
+		needs = append(needs, "'"+bin+"'")
+	}
+	if gitProviderCommands[command] {
+		needs = append(needs, "a GitHub/GitLab remote and token")
+	}
+	if len(needs) > 0 {
+		description += fmt.Sprintf(" (needs %s)", strings.Join(needs, " and "))
+	}
+	return description
+}
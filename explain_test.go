@@ -0,0 +1,32 @@
+package xplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandDescriptions(t *testing.T) {
+	for command := range specialCommandToBinMap {
+		assert.NotEmpty(t, commandDescriptions[command], "'%s' has no --explain description", command)
+	}
+	assert.Len(t, commandDescriptions, len(specialCommandToBinMap))
+}
+
+func TestExplainCommands(t *testing.T) {
+	cfg := &Config{
+		Commands:       []string{"git_status", " github_prs", "readme", "lint", "todo-tool"},
+		CommandAliases: map[string]string{"lint": "golangci-lint run"},
+		IncludeFiles:   []string{"TODO.md"},
+	}
+
+	expected := `xplane: Gathering context from 6 sources:
+  git_status    uncommitted and untracked files (needs 'git')
+  github_prs    open GitHub pull requests (needs a GitHub/GitLab remote and token)
+  readme        the project README
+  lint          the output of 'golangci-lint run' (XPLANE_COMMAND_ALIASES)
+  todo-tool     the output of 'todo-tool <git root>', a custom command
+  file:TODO.md  the contents of TODO.md (XPLANE_INCLUDE_FILES)
+`
+	assert.Equal(t, expected, explainCommands(cfg))
+}
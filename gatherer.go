@@ -1,20 +1,44 @@
-package main
+package xplane
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"slices"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
 )
 
+// how many recent commits are scanned for issue references
+const shippedIssuesCommitWindow = "50"
+
+// pr_overlap lists the files of each open PR with its own API call, only the most recently updated ones are checked
+const prOverlapMaxPRs = 30
+
 type ContextGatherer struct {
 	gitRoot     string
 	cfg         *Config
 	gitProvider GitProvider
+	anonymizer  *authorAnonymizer // nil unless author anonymization is enabled
+	authorsSeen []string          // names noted by the command being run, cached along with its output
 }
 
 func NewContextGatherer(gitRoot string, cfg *Config) *ContextGatherer {
 	return &ContextGatherer{gitRoot: gitRoot, cfg: cfg}
 }
 
+// registers an author name a command prints, so that it's anonymized along with the rest of the block. names are
+// left as is in the output itself, which may be cached and has to stay usable whether anonymization is on or not
+func (cg *ContextGatherer) noteAuthor(name string) {
+	if cg.anonymizer == nil || strings.TrimSpace(name) == "" {
+		return
+	}
+	cg.anonymizer.pseudonym(name)
+	cg.authorsSeen = append(cg.authorsSeen, name)
+}
+
 func (cg *ContextGatherer) initProvider() error {
 	if cg.gitProvider == nil {
 		provider, err := getGitProvider(cg.gitRoot, cg.cfg)
@@ -50,15 +74,350 @@ func (cg *ContextGatherer) getOpenPRS() (string, error) {
 		return "No open pull/merge requests found.", nil
 	}
 
+	for _, pr := range openPRS {
+		cg.noteAuthor(pr.Author)
+	}
+
+	// one extra api call per PR, hence opt-in
+	if cg.cfg.PRCIStatus {
+		for i := range openPRS {
+			ciStatus, err := cg.gitProvider.GetPullRequestCIStatus(owner, repo, openPRS[i])
+			if err != nil {
+				log.Printf("Warning: Could not fetch CI status of #%d: %s", openPRS[i].Number, redactError(err))
+				ciStatus = "unknown"
+			}
+			openPRS[i].CIStatus = ciStatus
+		}
+	}
+
+	// same, one extra api call per PR
+	if cg.cfg.PRThreads {
+		for i := range openPRS {
+			unresolved, err := cg.gitProvider.GetPullRequestUnresolvedThreads(owner, repo, openPRS[i].Number)
+			if err != nil {
+				log.Printf("Warning: Could not fetch review threads of #%d: %s", openPRS[i].Number, redactError(err))
+				continue
+			}
+			openPRS[i].UnresolvedThreads = unresolved
+			openPRS[i].threadsFetched = true
+		}
+	}
+
+	var output string
+	switch {
+	case cg.cfg.GroupPRsByBranch:
+		output = groupPullRequestsByBranch(openPRS, cg.cfg.PRTemplate)
+	case cg.cfg.GroupPRsByLabel:
+		output = groupPullRequestsByLabel(openPRS, cg.cfg.PRTemplate)
+	default:
+		output = formatPullRequests(openPRS, cg.cfg.PRTemplate)
+	}
+	if cg.cfg.PRsByAuthor {
+		output = countPullRequestsByAuthor(openPRS) + "\n\n" + output
+	}
+	if cg.cfg.StalePRDays > 0 {
+		if stale := formatStalePullRequests(openPRS, cg.cfg.StalePRDays, time.Now()); stale != "" {
+			output += "\n\n" + stale
+		}
+	}
+	return output, nil
+}
+
+// a "Stale PRs" section listing the PRs open for more than days, oldest first, empty when there are none
+func formatStalePullRequests(prs []PullRequest, days int, now time.Time) string {
+	cutoff := now.AddDate(0, 0, -days)
+	var stale []PullRequest
+	for _, pr := range prs {
+		// without a creation date there's no telling
+		if !pr.CreatedAt.IsZero() && pr.CreatedAt.Before(cutoff) {
+			stale = append(stale, pr)
+		}
+	}
+	if len(stale) == 0 {
+		return ""
+	}
+	sort.SliceStable(stale, func(i, j int) bool { return stale[i].CreatedAt.Before(stale[j].CreatedAt) })
+
 	var builder strings.Builder
-	for i, pr := range openPRS {
-		builder.WriteString(pr.Format())
-		if i < len(openPRS)-1 {
+	builder.WriteString(fmt.Sprintf("## Stale PRs (open longer than %d days)\n", days))
+	for _, pr := range stale {
+		openDays := int(now.Sub(pr.CreatedAt).Hours() / 24)
+		line := fmt.Sprintf("- #%d %s by %s, open %d days", pr.Number, pr.Title, pr.Author, openDays)
+		if !pr.UpdatedAt.IsZero() {
+			line += fmt.Sprintf(", last updated %d days ago", int(now.Sub(pr.UpdatedAt).Hours()/24))
+		}
+		builder.WriteString(line + "\n")
+	}
+	return builder.String()
+}
+
+// a one-line "PRs by author: alice (3), bob (1)" tally, busiest authors first
+func countPullRequestsByAuthor(prs []PullRequest) string {
+	counts := make(map[string]int)
+	for _, pr := range prs {
+		counts[pr.Author]++
+	}
+
+	authors := make([]string, 0, len(counts))
+	for author := range counts {
+		authors = append(authors, author)
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		if counts[authors[i]] != counts[authors[j]] {
+			return counts[authors[i]] > counts[authors[j]]
+		}
+		return authors[i] < authors[j]
+	})
+
+	tallies := make([]string, len(authors))
+	for i, author := range authors {
+		tallies[i] = fmt.Sprintf("%s (%d)", author, counts[author])
+	}
+	return "PRs by author: " + strings.Join(tallies, ", ")
+}
+
+// lists only the open PRs that are approved, pass their checks and have nothing else blocking the merge
+func (cg *ContextGatherer) getMergeablePRs() (string, error) {
+	if err := cg.initProvider(); err != nil {
+		return "", err
+	}
+
+	url, err := findPrimaryRemoteRepoURL(cg.gitRoot)
+	if err != nil {
+		return "", err
+	}
+
+	_, owner, repo, err := parseGitURL(url)
+	if err != nil {
+		return "", err
+	}
+
+	openPRS, err := cg.gitProvider.GetOpenPullRequestsWithReadiness(owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	var ready []PullRequest
+	for _, pr := range openPRS {
+		if !pr.ReadyToMerge {
+			continue
+		}
+		cg.noteAuthor(pr.Author)
+		ready = append(ready, pr)
+	}
+	if len(ready) == 0 {
+		return fmt.Sprintf("None of the %d open pull/merge requests are approved and ready to merge.", len(openPRS)), nil
+	}
+	return fmt.Sprintf("%d of %d open pull/merge requests are approved and ready to merge:\n%s", len(ready), len(openPRS), formatPullRequests(ready, cg.cfg.PRTemplate)), nil
+}
+
+// tmpl is XPLANE_PR_TEMPLATE, nil for the built-in layout
+func formatPullRequests(prs []PullRequest, tmpl *template.Template) string {
+	var builder strings.Builder
+	for i, pr := range prs {
+		builder.WriteString(formatEntity(&pr, tmpl))
+		if i < len(prs)-1 {
 			builder.WriteString("\n---\n")
 		}
 	}
-	output := builder.String()
-	return output, nil
+	return builder.String()
+}
+
+// renders PRs in one section per label, e.g. "bug (3)", biggest groups first and unlabeled ones last,
+// PRs with several labels show up in each of their sections
+func groupPullRequestsByLabel(prs []PullRequest, tmpl *template.Template) string {
+	groups := make(map[string][]PullRequest)
+	var unlabeled []PullRequest
+	for _, pr := range prs {
+		if len(pr.Labels) == 0 {
+			unlabeled = append(unlabeled, pr)
+			continue
+		}
+		for _, label := range pr.Labels {
+			groups[label] = append(groups[label], pr)
+		}
+	}
+
+	if len(groups) == 0 {
+		return formatPullRequests(prs, tmpl)
+	}
+
+	labels := make([]string, 0, len(groups))
+	for label := range groups {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if len(groups[labels[i]]) != len(groups[labels[j]]) {
+			return len(groups[labels[i]]) > len(groups[labels[j]])
+		}
+		return labels[i] < labels[j]
+	})
+
+	var builder strings.Builder
+	for _, label := range labels {
+		builder.WriteString(fmt.Sprintf("## %s (%d)\n\n%s\n", label, len(groups[label]), formatPullRequests(groups[label], tmpl)))
+	}
+	if len(unlabeled) > 0 {
+		builder.WriteString(fmt.Sprintf("## unlabeled (%d)\n\n%s\n", len(unlabeled), formatPullRequests(unlabeled, tmpl)))
+	}
+	return builder.String()
+}
+
+// renders PRs in one section per target branch, e.g. "into develop (3)", biggest groups first,
+// so release flows with several integration branches stay visible
+func groupPullRequestsByBranch(prs []PullRequest, tmpl *template.Template) string {
+	groups := make(map[string][]PullRequest)
+	for _, pr := range prs {
+		branch := pr.BaseBranch
+		if branch == "" {
+			branch = "unknown branch"
+		}
+		groups[branch] = append(groups[branch], pr)
+	}
+
+	branches := make([]string, 0, len(groups))
+	for branch := range groups {
+		branches = append(branches, branch)
+	}
+	sort.Slice(branches, func(i, j int) bool {
+		if len(groups[branches[i]]) != len(groups[branches[j]]) {
+			return len(groups[branches[i]]) > len(groups[branches[j]])
+		}
+		return branches[i] < branches[j]
+	})
+
+	var builder strings.Builder
+	for _, branch := range branches {
+		builder.WriteString(fmt.Sprintf("## into %s (%d)\n\n%s\n", branch, len(groups[branch]), formatPullRequests(groups[branch], tmpl)))
+	}
+	return builder.String()
+}
+
+// lists the open pull/merge requests touching files the current branch also changes, a merge conflict risk
+func (cg *ContextGatherer) getPROverlap() (string, error) {
+	if err := cg.initProvider(); err != nil {
+		return "", err
+	}
+
+	localFiles, err := getBranchChangedFiles(cg.gitRoot)
+	if err != nil {
+		return "", err
+	}
+	if len(localFiles) == 0 {
+		return "No changes on the current branch to compare with open pull/merge requests.", nil
+	}
+
+	localBranch, err := runCommand(cg.gitRoot, "git", "branch", "--show-current")
+	if err != nil {
+		return "", err
+	}
+	localBranch = strings.TrimSpace(localBranch)
+
+	url, err := findPrimaryRemoteRepoURL(cg.gitRoot)
+	if err != nil {
+		return "", err
+	}
+
+	_, owner, repo, err := parseGitURL(url)
+	if err != nil {
+		return "", err
+	}
+
+	openPRS, err := cg.gitProvider.GetOpenPullRequests(owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	// the current branch's own PR overlaps with everything, by definition
+	openPRS = slices.DeleteFunc(openPRS, func(pr PullRequest) bool { return localBranch != "" && pr.HeadBranch == localBranch })
+	checkedPRs := mostRecentlyUpdated(openPRS, prOverlapMaxPRs)
+
+	var builder strings.Builder
+	for _, pr := range checkedPRs {
+		prFiles, err := cg.gitProvider.GetPullRequestFiles(owner, repo, pr.Number)
+		if err != nil {
+			return "", err
+		}
+		overlap := overlappingFiles(localFiles, prFiles)
+		if len(overlap) == 0 {
+			continue
+		}
+		cg.noteAuthor(pr.Author)
+		builder.WriteString(fmt.Sprintf("- #%d %s (by %s)\n  URL: %s\n  Overlapping files: %s\n", pr.Number, pr.Title, pr.Author, pr.URL, strings.Join(overlap, ", ")))
+	}
+
+	var note string
+	if len(checkedPRs) < len(openPRS) {
+		note = fmt.Sprintf("Only the %d most recently updated of %d open pull/merge requests were checked.\n", len(checkedPRs), len(openPRS))
+	}
+	if builder.Len() == 0 {
+		return note + fmt.Sprintf("None of the %d open pull/merge requests checked touch the %d files changed on the current branch.", len(checkedPRs), len(localFiles)), nil
+	}
+	return note + "Open pull/merge requests changing the same files as the current branch (possible merge conflicts):\n" + builder.String(), nil
+}
+
+// the limit most recently updated pull requests, newest first. ones without an update time come last
+func mostRecentlyUpdated(prs []PullRequest, limit int) []PullRequest {
+	sorted := slices.Clone(prs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].UpdatedAt.After(sorted[j].UpdatedAt) })
+	if len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+// files present in both lists, in the order of the first one
+func overlappingFiles(localFiles, prFiles []string) []string {
+	var overlap []string
+	for _, file := range localFiles {
+		if slices.Contains(prFiles, file) {
+			overlap = append(overlap, file)
+		}
+	}
+	return overlap
+}
+
+// lists remote branches without commits for the given number of days, oldest first
+func (cg *ContextGatherer) getStaleBranches(days int) (string, error) {
+	if err := cg.initProvider(); err != nil {
+		return "", err
+	}
+
+	url, err := findPrimaryRemoteRepoURL(cg.gitRoot)
+	if err != nil {
+		return "", err
+	}
+
+	_, owner, repo, err := parseGitURL(url)
+	if err != nil {
+		return "", err
+	}
+
+	branches, err := cg.gitProvider.ListBranches(owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -days)
+	var stale []RemoteBranch
+	for _, branch := range branches {
+		if !branch.IsDefault && branch.LastCommit.Before(cutoff) {
+			cg.noteAuthor(branch.LastAuthor)
+			stale = append(stale, branch)
+		}
+	}
+	if len(stale) == 0 {
+		return fmt.Sprintf("No remote branches without commits in the last %d days (%d branches checked).", days, len(branches)), nil
+	}
+
+	sort.SliceStable(stale, func(i, j int) bool { return stale[i].LastCommit.Before(stale[j].LastCommit) })
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("%d of %d remote branches have no commits in the last %d days:\n", len(stale), len(branches), days))
+	for _, branch := range stale {
+		builder.WriteString(branch.Format(now))
+	}
+	return builder.String(), nil
 }
 
 func (cg *ContextGatherer) getLatestRelease() (string, error) {
@@ -81,13 +440,61 @@ func (cg *ContextGatherer) getLatestRelease() (string, error) {
 		return "", err
 	}
 
-	return release.Format(), nil
+	return formatEntity(&release, cg.cfg.ReleaseTemplate), nil
+}
+
+// the latest releases with their dates, for the release cadence rather than only the newest one
+func (cg *ContextGatherer) getRecentReleases(limit int) (string, error) {
+	if err := cg.initProvider(); err != nil {
+		return "", err
+	}
+
+	url, err := findPrimaryRemoteRepoURL(cg.gitRoot)
+	if err != nil {
+		return "", err
+	}
+
+	_, owner, repo, err := parseGitURL(url)
+	if err != nil {
+		return "", err
+	}
+
+	releases, err := cg.gitProvider.ListReleases(owner, repo, limit)
+	if err != nil {
+		return "", err
+	}
+	return formatRecentReleases(releases, time.Now()), nil
+}
+
+func formatRecentReleases(releases []Release, now time.Time) string {
+	if len(releases) == 0 {
+		return "No releases found."
+	}
+
+	lastMonth := 0
+	for _, release := range releases {
+		if !release.publishedTime.IsZero() && release.publishedTime.After(now.AddDate(0, 0, -30)) {
+			lastMonth++
+		}
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("The last %d releases, %d of them published in the last 30 days:\n", len(releases), lastMonth))
+	for _, release := range releases {
+		name := release.TagName
+		if release.Name != "" && release.Name != release.TagName {
+			name = fmt.Sprintf("%s (%s)", release.TagName, release.Name)
+		}
+		builder.WriteString(fmt.Sprintf("- %s, %s\n", name, release.PublishedAt))
+	}
+	return builder.String()
 }
 
 func (cg *ContextGatherer) getGitBranchStatus() (string, error) {
 	// checking that the local branch has remote tracking first
 	// this is not enough if a branch has been pushed but then removed from the remote
-	// e.g. a branch could be autoremoved on the remote after a Merge and git wouldn't know locally without a git fetch --prune
+	// e.g. a branch could be autoremoved on the remote after a Merge and git wouldn't know locally without a git fetch --prune,
+	// which XPLANE_PREFETCH runs before gathering
 	if !hasRemoteTrackingBranch(cg.gitRoot) {
 		output := "Local branch has not been pushed to the remote."
 		return output, nil
@@ -132,10 +539,203 @@ func (cg *ContextGatherer) getGitBranchStatus() (string, error) {
 		return "", err
 	}
 
-	branchComparison, err := cg.gitProvider.CompareBranchWithDefault(owner, repo, originOwner, localBranch)
+	branchComparison, err := cg.gitProvider.CompareBranch(owner, repo, originOwner, localBranch, cg.cfg.CompareBranch)
 	if err != nil {
 		return "", err
 	}
 
+	// ahead/behind alone can't tell a messy rebase from regular divergence
+	rewritten, err := hasRewrittenHistory(cg.gitRoot)
+	if err != nil {
+		log.Printf("Warning: Could not check for rewritten history: %s", redactError(err))
+	}
+	branchComparison.HistoryRewritten = rewritten
+
+	if omitBranchStatus(cg.cfg, branchComparison) {
+		return "", errOmitBlock
+	}
 	return branchComparison.Format(), nil
 }
+
+// being on an up to date default branch is the common case, with XPLANE_OMIT_IDENTICAL_BRANCH_STATUS the block
+// is left out then as it only adds noise. a rewritten history is still worth mentioning
+func omitBranchStatus(cfg *Config, comparison BranchComparison) bool {
+	return cfg.OmitIdenticalBranch && comparison.Status == "identical" && !comparison.HistoryRewritten
+}
+
+func (cg *ContextGatherer) getShippedIssues(n int) (string, error) {
+	if err := cg.initProvider(); err != nil {
+		return "", err
+	}
+
+	gitLog, err := runCommand(cg.gitRoot, "git", "log", "-n", shippedIssuesCommitWindow, "--format=%h%x1f%B%x1e")
+	if err != nil {
+		return "", err
+	}
+
+	url, err := findPrimaryRemoteRepoURL(cg.gitRoot)
+	if err != nil {
+		return "", err
+	}
+
+	_, owner, repo, err := parseGitURL(url)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	found := 0
+	for _, reference := range extractIssueReferences(gitLog) {
+		if found == n {
+			break
+		}
+		// references can point to pull requests or to issues that no longer exist, those are just not shipped issues
+		issue, err := cg.gitProvider.GetIssue(owner, repo, reference.number)
+		if errors.Is(err, errIssueNotFound) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		if issue.IsPullRequest || issue.State != "closed" {
+			continue
+		}
+		builder.WriteString(issue.Format())
+		builder.WriteString(fmt.Sprintf("  Referenced by commit: %s\n", reference.commit))
+		found++
+	}
+
+	if found == 0 {
+		return "No closed issues referenced by recent commits.", nil
+	}
+	return builder.String(), nil
+}
+
+// finds the open pull/merge request for the current local branch, returning nil when there is none,
+// along with the upstream owner and repo it lives in
+func (cg *ContextGatherer) findCurrentBranchPR() (*PullRequest, string, string, error) {
+	if err := cg.initProvider(); err != nil {
+		return nil, "", "", err
+	}
+
+	localBranch, err := runCommand(cg.gitRoot, "git", "branch", "--show-current")
+	if err != nil {
+		return nil, "", "", err
+	}
+	localBranch = strings.TrimSpace(localBranch)
+	if localBranch == "" {
+		return nil, "", "", fmt.Errorf("HEAD is detached, no branch to look up a pull/merge request for")
+	}
+
+	originOwner, err := getOriginOwner(cg.gitRoot)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	url, err := findPrimaryRemoteRepoURL(cg.gitRoot)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	_, owner, repo, err := parseGitURL(url)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	pr, err := cg.gitProvider.FindPullRequestForBranch(owner, repo, originOwner, localBranch)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return pr, owner, repo, nil
+}
+
+// the description of the current branch's pull/merge request as a prompt section, empty when there's nothing to add
+func (cg *ContextGatherer) getStatedIntent() (string, error) {
+	pr, _, _, err := cg.findCurrentBranchPR()
+	if err != nil {
+		return "", err
+	}
+	if pr == nil {
+		return "", nil
+	}
+	return buildIntentSection(pr), nil
+}
+
+func buildIntentSection(pr *PullRequest) string {
+	description := strings.TrimSpace(pr.Description)
+	if description == "" {
+		return ""
+	}
+	return fmt.Sprintf(`--- STATED INTENT ---
+The pull/merge request #%d for the current branch, "%s", states the intended change below. Compare it with what the context actually shows: point out changes that weren't stated, and stated goals that aren't reflected yet.
+
+%s
+--- END OF STATED INTENT ---
+
+`, pr.Number, pr.Title, description)
+}
+
+// posts the summary as a comment on the current branch's pull/merge request
+func (cg *ContextGatherer) postSummaryComment(summary string) error {
+	pr, owner, repo, err := cg.findCurrentBranchPR()
+	if err != nil {
+		return err
+	}
+	if pr == nil {
+		fmt.Println(cg.cfg.msg(MsgNoPRToComment))
+		return nil
+	}
+
+	if err := cg.gitProvider.PostPullRequestComment(owner, repo, pr.Number, summary); err != nil {
+		return err
+	}
+	fmt.Printf(cg.cfg.msg(MsgCommentPosted), pr.URL)
+	return nil
+}
+
+func (cg *ContextGatherer) getMergeStatus() (string, error) {
+	pr, owner, repo, err := cg.findCurrentBranchPR()
+	if err != nil {
+		return "", err
+	}
+	if pr == nil {
+		return "No open pull/merge request found for the current branch.", nil
+	}
+
+	mergeStatus, err := cg.gitProvider.GetMergeStatus(owner, repo, pr.Number)
+	if err != nil {
+		return "", err
+	}
+	return mergeStatus.Format(), nil
+}
+
+func (cg *ContextGatherer) getDiscussions(limit int) (string, error) {
+	if err := cg.initProvider(); err != nil {
+		return "", err
+	}
+
+	url, err := findPrimaryRemoteRepoURL(cg.gitRoot)
+	if err != nil {
+		return "", err
+	}
+
+	_, owner, repo, err := parseGitURL(url)
+	if err != nil {
+		return "", err
+	}
+
+	discussions, err := cg.gitProvider.GetRecentDiscussions(owner, repo, limit)
+	if err != nil {
+		return "", err
+	}
+	if len(discussions) == 0 {
+		return "No discussions found.", nil
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Recently active discussions:\n")
+	for _, discussion := range discussions {
+		cg.noteAuthor(discussion.Author)
+		builder.WriteString(discussion.Format())
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,105 @@
+package xplane
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupPullRequestsByLabel(t *testing.T) {
+	fix := PullRequest{Title: "Fix crash", Author: "jane", Labels: []string{"bug"}}
+	feature := PullRequest{Title: "Add export", Author: "bob", Labels: []string{"feature", "bug"}}
+	chore := PullRequest{Title: "Bump deps", Author: "bot"}
+
+	t.Run("grouped by label, biggest first", func(t *testing.T) {
+		output := groupPullRequestsByLabel([]PullRequest{fix, feature, chore}, nil)
+
+		expected := "## bug (2)\n\n" + formatPullRequests([]PullRequest{fix, feature}, nil) + "\n" +
+			"## feature (1)\n\n" + formatPullRequests([]PullRequest{feature}, nil) + "\n" +
+			"## unlabeled (1)\n\n" + formatPullRequests([]PullRequest{chore}, nil) + "\n"
+		assert.Equal(t, expected, output)
+	})
+
+	t.Run("flat list without any labels", func(t *testing.T) {
+		prs := []PullRequest{chore, {Title: "Docs", Author: "amy"}}
+		assert.Equal(t, formatPullRequests(prs, nil), groupPullRequestsByLabel(prs, nil))
+	})
+}
+
+func TestGroupPullRequestsByBranch(t *testing.T) {
+	release := PullRequest{Title: "Release 2.1", Author: "jane", BaseBranch: "main"}
+	feature := PullRequest{Title: "Add export", Author: "bob", BaseBranch: "develop"}
+	fix := PullRequest{Title: "Fix crash", Author: "amy", BaseBranch: "develop"}
+	unknown := PullRequest{Title: "Bump deps", Author: "bot"}
+
+	output := groupPullRequestsByBranch([]PullRequest{release, feature, unknown, fix}, nil)
+
+	expected := "## into develop (2)\n\n" + formatPullRequests([]PullRequest{feature, fix}, nil) + "\n" +
+		"## into main (1)\n\n" + formatPullRequests([]PullRequest{release}, nil) + "\n" +
+		"## into unknown branch (1)\n\n" + formatPullRequests([]PullRequest{unknown}, nil) + "\n"
+	assert.Equal(t, expected, output)
+	assert.Contains(t, output, "  Target branch: develop\n")
+}
+
+func TestCountPullRequestsByAuthor(t *testing.T) {
+	prs := []PullRequest{{Author: "bob"}, {Author: "alice"}, {Author: "carol"}, {Author: "alice"}, {Author: "alice"}}
+	assert.Equal(t, "PRs by author: alice (3), bob (1), carol (1)", countPullRequestsByAuthor(prs))
+}
+
+func TestFormatStalePullRequests(t *testing.T) {
+	now := time.Date(2025, 9, 15, 12, 0, 0, 0, time.UTC)
+	prs := []PullRequest{
+		{Number: 7, Title: "Fresh", Author: "bob", CreatedAt: now.AddDate(0, 0, -3)},
+		{Number: 12, Title: "Add export", Author: "alice", CreatedAt: now.AddDate(0, 0, -45), UpdatedAt: now.AddDate(0, 0, -20)},
+		{Number: 3, Title: "Old refactor", Author: "carol", CreatedAt: now.AddDate(0, 0, -90)},
+		{Number: 9, Title: "Unknown age", Author: "dan"},
+	}
+
+	expected := "## Stale PRs (open longer than 30 days)\n" +
+		"- #3 Old refactor by carol, open 90 days\n" +
+		"- #12 Add export by alice, open 45 days, last updated 20 days ago\n"
+	assert.Equal(t, expected, formatStalePullRequests(prs, 30, now))
+	assert.Empty(t, formatStalePullRequests(prs, 100, now))
+}
+
+func TestBuildIntentSection(t *testing.T) {
+	pr := &PullRequest{Number: 42, Title: "Add export", Description: "  Adds a CSV export.\n"}
+	section := buildIntentSection(pr)
+	assert.True(t, strings.HasPrefix(section, "--- STATED INTENT ---\n"))
+	assert.Contains(t, section, `#42 for the current branch, "Add export"`)
+	assert.Contains(t, section, "\n\nAdds a CSV export.\n--- END OF STATED INTENT ---")
+
+	pr.Description = " \n"
+	assert.Empty(t, buildIntentSection(pr))
+}
+
+func TestOverlappingFiles(t *testing.T) {
+	assert.Equal(t, []string{"auth.go", "config.go"}, overlappingFiles([]string{"auth.go", "main.go", "config.go"}, []string{"config.go", "auth.go", "README.md"}))
+	assert.Empty(t, overlappingFiles([]string{"main.go"}, []string{"auth.go"}))
+}
+
+func TestMostRecentlyUpdated(t *testing.T) {
+	now := time.Now()
+	prs := []PullRequest{{Number: 1, UpdatedAt: now.Add(-time.Hour)}, {Number: 2}, {Number: 3, UpdatedAt: now}, {Number: 4, UpdatedAt: now.Add(-2 * time.Hour)}}
+
+	numbers := func(prs []PullRequest) []int {
+		var numbers []int
+		for _, pr := range prs {
+			numbers = append(numbers, pr.Number)
+		}
+		return numbers
+	}
+	assert.Equal(t, []int{3, 1}, numbers(mostRecentlyUpdated(prs, 2)))
+	assert.Equal(t, []int{3, 1, 4, 2}, numbers(mostRecentlyUpdated(prs, 10)))
+	assert.Equal(t, 1, prs[0].Number, "the input is left alone")
+}
+
+func TestOmitBranchStatus(t *testing.T) {
+	omitting := &Config{OmitIdenticalBranch: true}
+	assert.True(t, omitBranchStatus(omitting, BranchComparison{Status: "identical"}))
+	assert.False(t, omitBranchStatus(omitting, BranchComparison{Status: "ahead", AheadBy: 2}))
+	assert.False(t, omitBranchStatus(omitting, BranchComparison{Status: "identical", HistoryRewritten: true}))
+	assert.False(t, omitBranchStatus(&Config{}, BranchComparison{Status: "identical"}), "kept by default")
+}
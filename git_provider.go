@@ -1,14 +1,19 @@
-package main
+package xplane
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/go-github/v74/github"
 	"gitlab.com/gitlab-org/api/client-go"
 	"golang.org/x/oauth2"
+	"log"
+	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
+	"text/template"
+	"time"
 )
 
 var gitURLRegex = regexp.MustCompile(`(?:git@|https://)([\w.-]+)(?::|/)([\w.-]+)/([\w.-]+?)(\.git)?$`)
@@ -50,8 +55,21 @@ type GitProvider interface {
 	GetUpstreamURL() string
 	BranchExistsOnRemoteOrigin(owner, repo, branchName string) (bool, error)
 	GetOpenPullRequests(owner, repo string) ([]PullRequest, error)
+	GetOpenPullRequestsWithReadiness(owner, repo string) ([]PullRequest, error)
 	GetLatestRelease(owner, repo string) (Release, error)
-	CompareBranchWithDefault(owner, repo, originOwner, localBranch string) (BranchComparison, error)
+	CompareBranch(owner, repo, originOwner, localBranch, baseBranch string) (BranchComparison, error)
+	GetIssue(owner, repo string, number int) (Issue, error)
+	FindPullRequestForBranch(owner, repo, originOwner, branchName string) (*PullRequest, error)
+	PostPullRequestComment(owner, repo string, number int, body string) error
+	GetMergeStatus(owner, repo string, number int) (MergeStatus, error)
+	GetRecentDiscussions(owner, repo string, limit int) ([]Discussion, error)
+	GetPullRequestCIStatus(owner, repo string, pr PullRequest) (string, error)
+	GetPullRequestUnresolvedThreads(owner, repo string, number int) (int, error)
+	GetPullRequestFiles(owner, repo string, number int) ([]string, error)
+	GetPullRequest(owner, repo string, number int) (*PullRequest, error)
+	GetPullRequestDiff(owner, repo string, number int) (string, error)
+	ListBranches(owner, repo string) ([]RemoteBranch, error)
+	ListReleases(owner, repo string, limit int) ([]Release, error)
 }
 
 type GithubProvider struct {
@@ -92,11 +110,22 @@ func (g *GithubProvider) GetOpenPullRequests(owner, repo string) ([]PullRequest,
 
 	var results []PullRequest
 	for _, pr := range prs {
+		var labels []string
+		for _, label := range pr.Labels {
+			labels = append(labels, label.GetName())
+		}
 		results = append(results, PullRequest{
+			Number:      pr.GetNumber(),
 			Title:       pr.GetTitle(),
 			Author:      pr.GetUser().GetLogin(),
 			Description: pr.GetBody(),
 			URL:         pr.GetHTMLURL(),
+			Labels:      labels,
+			HeadSHA:     pr.GetHead().GetSHA(),
+			HeadBranch:  pr.GetHead().GetRef(),
+			BaseBranch:  pr.GetBase().GetRef(),
+			CreatedAt:   pr.GetCreatedAt().Time,
+			UpdatedAt:   pr.GetUpdatedAt().Time,
 		})
 	}
 	return results, nil
@@ -112,25 +141,59 @@ func (g *GithubProvider) GetLatestRelease(owner, repo string) (Release, error) {
 		return Release{}, fmt.Errorf("xplane: error fetching latest release from Github: %v", err)
 	}
 
+	return githubReleaseToRelease(release), nil
+}
+
+func githubReleaseToRelease(release *github.RepositoryRelease) Release {
 	return Release{
-		TagName:     release.GetTagName(),
-		Name:        release.GetName(),
-		URL:         release.GetHTMLURL(),
-		PublishedAt: release.GetPublishedAt().Format("Sat, Nov 4, 1995"),
-	}, nil
+		TagName:       release.GetTagName(),
+		Name:          release.GetName(),
+		URL:           release.GetHTMLURL(),
+		PublishedAt:   release.GetPublishedAt().Format(releaseDateLayout),
+		publishedTime: release.GetPublishedAt().Time,
+	}
 }
 
-func (g *GithubProvider) CompareBranchWithDefault(owner, repo, originOwner, localBranch string) (BranchComparison, error) {
-	// finding repo's default branch
-	repoInfo, _, err := g.client.Repositories.Get(context.Background(), owner, repo)
-	if err != nil {
-		return BranchComparison{}, fmt.Errorf("xplane: could not get repo info for default branch: %v", err)
+// the latest releases, newest first, drafts left out as they aren't shipped
+func (g *GithubProvider) ListReleases(owner, repo string, limit int) ([]Release, error) {
+	opts := &github.ListOptions{PerPage: min(limit, 100)}
+	var releases []Release
+	for {
+		page, resp, err := g.client.Repositories.ListReleases(context.Background(), owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("xplane: error listing releases from Github: %v", err)
+		}
+		for _, release := range page {
+			if release.GetDraft() {
+				continue
+			}
+			releases = append(releases, githubReleaseToRelease(release))
+			if len(releases) == limit {
+				return releases, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return releases, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// compares the local branch's remote copy with baseBranch, or with the repo's default branch when baseBranch is empty
+func (g *GithubProvider) CompareBranch(owner, repo, originOwner, localBranch, baseBranch string) (BranchComparison, error) {
+	defaultBranch := baseBranch
+	if defaultBranch == "" {
+		// finding repo's default branch
+		repoInfo, _, err := g.client.Repositories.Get(context.Background(), owner, repo)
+		if err != nil {
+			return BranchComparison{}, fmt.Errorf("xplane: could not get repo info for default branch: %v", err)
+		}
+		defaultBranch = repoInfo.GetDefaultBranch()
 	}
-	defaultBranch := repoInfo.GetDefaultBranch()
 
 	// obv not comparing to itself
 	if localBranch == defaultBranch && owner == originOwner {
-		return BranchComparison{Status: "identical"}, nil
+		return BranchComparison{Status: "identical", BaseBranch: defaultBranch, ExplicitBase: baseBranch != ""}, nil
 	}
 
 	// using format "owner:branch"
@@ -144,14 +207,499 @@ func (g *GithubProvider) CompareBranchWithDefault(owner, repo, originOwner, loca
 	}
 
 	return BranchComparison{
-		AheadBy:  comparison.GetAheadBy(),
-		BehindBy: comparison.GetBehindBy(),
-		Status:   comparison.GetStatus(),
+		AheadBy:      comparison.GetAheadBy(),
+		BehindBy:     comparison.GetBehindBy(),
+		Status:       comparison.GetStatus(),
+		BaseBranch:   defaultBranch,
+		ExplicitBase: baseBranch != "",
+	}, nil
+}
+
+// GetIssue wraps this when the issue doesn't exist, or was deleted, rather than failing to fetch it
+var errIssueNotFound = errors.New("issue not found")
+
+func isNotFound(resp *http.Response) bool {
+	return resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone)
+}
+
+func (g *GithubProvider) GetIssue(owner, repo string, number int) (Issue, error) {
+	issue, resp, err := g.client.Issues.Get(context.Background(), owner, repo, number)
+	if err != nil {
+		if resp != nil && isNotFound(resp.Response) {
+			return Issue{}, fmt.Errorf("xplane: issue #%d on Github: %w", number, errIssueNotFound)
+		}
+		return Issue{}, fmt.Errorf("xplane: error fetching issue #%d from Github: %v", number, err)
+	}
+
+	return Issue{
+		Number: issue.GetNumber(),
+		Title:  issue.GetTitle(),
+		State:  issue.GetState(),
+		URL:    issue.GetHTMLURL(),
+		// github serves pull requests through the issues api as well
+		IsPullRequest: issue.IsPullRequest(),
+	}, nil
+}
+
+func (g *GithubProvider) FindPullRequestForBranch(owner, repo, originOwner, branchName string) (*PullRequest, error) {
+	opts := &github.PullRequestListOptions{
+		State: "open",
+		Head:  fmt.Sprintf("%s:%s", originOwner, branchName),
+	}
+	prs, _, err := g.client.PullRequests.List(context.Background(), owner, repo, opts)
+	if err != nil {
+		return nil, fmt.Errorf("xplane: error looking up the PR for branch '%s' on Github: %v", branchName, err)
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+
+	pr := prs[0]
+	return &PullRequest{
+		Number:      pr.GetNumber(),
+		Title:       pr.GetTitle(),
+		Author:      pr.GetUser().GetLogin(),
+		Description: pr.GetBody(),
+		URL:         pr.GetHTMLURL(),
+	}, nil
+}
+
+func (g *GithubProvider) PostPullRequestComment(owner, repo string, number int, body string) error {
+	// PR conversation comments go through the issues api on github
+	_, _, err := g.client.Issues.CreateComment(context.Background(), owner, repo, number, &github.IssueComment{Body: &body})
+	if err != nil {
+		return fmt.Errorf("xplane: error commenting on PR #%d on Github: %v", number, err)
+	}
+	return nil
+}
+
+func (g *GithubProvider) GetMergeStatus(owner, repo string, number int) (MergeStatus, error) {
+	pr, _, err := g.client.PullRequests.Get(context.Background(), owner, repo, number)
+	if err != nil {
+		return MergeStatus{}, fmt.Errorf("xplane: error fetching PR #%d from Github: %v", number, err)
+	}
+
+	// github computes mergeability in the background, a 'clean' state is the only one with nothing left to do
+	state := pr.GetMergeableState()
+	if state == "" {
+		state = "unknown"
+	}
+	return MergeStatus{
+		Number:    number,
+		State:     state,
+		Mergeable: state == "clean",
+		Draft:     pr.GetDraft(),
 	}, nil
 }
 
+// the REST API has no discussions endpoint, so they're fetched through GraphQL
+const githubDiscussionsQuery = `query($owner: String!, $repo: String!, $limit: Int!) {
+  repository(owner: $owner, name: $repo) {
+    discussions(first: $limit, orderBy: {field: UPDATED_AT, direction: DESC}) {
+      nodes {
+        number
+        title
+        url
+        bodyText
+        author { login }
+        category { name }
+        answer { id }
+      }
+    }
+  }
+}`
+
+type githubDiscussionsResponse struct {
+	Data struct {
+		Repository struct {
+			Discussions struct {
+				Nodes []struct {
+					Number   int    `json:"number"`
+					Title    string `json:"title"`
+					URL      string `json:"url"`
+					BodyText string `json:"bodyText"`
+					Author   *struct {
+						Login string `json:"login"`
+					} `json:"author"`
+					Category struct {
+						Name string `json:"name"`
+					} `json:"category"`
+					Answer *struct {
+						ID string `json:"id"`
+					} `json:"answer"`
+				} `json:"nodes"`
+			} `json:"discussions"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (g *GithubProvider) GetRecentDiscussions(owner, repo string, limit int) ([]Discussion, error) {
+	body := map[string]any{
+		"query":     githubDiscussionsQuery,
+		"variables": map[string]any{"owner": owner, "repo": repo, "limit": limit},
+	}
+	req, err := g.client.NewRequest("POST", "graphql", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response githubDiscussionsResponse
+	if _, err := g.client.Do(context.Background(), req, &response); err != nil {
+		return nil, fmt.Errorf("xplane: error fetching discussions from Github: %v", err)
+	}
+	// graphql reports errors with a 200 status
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("xplane: error fetching discussions from Github: %s", response.Errors[0].Message)
+	}
+
+	var discussions []Discussion
+	for _, node := range response.Data.Repository.Discussions.Nodes {
+		author := "ghost" // what github shows for deleted accounts
+		if node.Author != nil {
+			author = node.Author.Login
+		}
+		discussions = append(discussions, Discussion{
+			Number:   node.Number,
+			Title:    node.Title,
+			Author:   author,
+			Category: node.Category.Name,
+			URL:      node.URL,
+			Body:     node.BodyText,
+			Answered: node.Answer != nil,
+		})
+	}
+	return discussions, nil
+}
+
+// review decision and merge state aren't in the rest api's PR list, graphql has them for every PR in one call
+const githubPullRequestReadinessQuery = `query($owner: String!, $repo: String!) {
+  repository(owner: $owner, name: $repo) {
+    pullRequests(states: OPEN, first: 50, orderBy: {field: UPDATED_AT, direction: DESC}) {
+      nodes {
+        number
+        title
+        url
+        body
+        isDraft
+        headRefName
+        headRefOid
+        baseRefName
+        createdAt
+        updatedAt
+        author { login }
+        labels(first: 20) { nodes { name } }
+        reviewDecision
+        mergeStateStatus
+      }
+    }
+  }
+}`
+
+type githubPullRequestReadinessResponse struct {
+	Data struct {
+		Repository struct {
+			PullRequests struct {
+				Nodes []struct {
+					Number      int       `json:"number"`
+					Title       string    `json:"title"`
+					URL         string    `json:"url"`
+					Body        string    `json:"body"`
+					IsDraft     bool      `json:"isDraft"`
+					HeadRefName string    `json:"headRefName"`
+					HeadRefOid  string    `json:"headRefOid"`
+					BaseRefName string    `json:"baseRefName"`
+					CreatedAt   time.Time `json:"createdAt"`
+					UpdatedAt   time.Time `json:"updatedAt"`
+					Author      *struct {
+						Login string `json:"login"`
+					} `json:"author"`
+					Labels struct {
+						Nodes []struct {
+							Name string `json:"name"`
+						} `json:"nodes"`
+					} `json:"labels"`
+					ReviewDecision   string `json:"reviewDecision"`
+					MergeStateStatus string `json:"mergeStateStatus"`
+				} `json:"nodes"`
+			} `json:"pullRequests"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// lists up to 50 open PRs, most recently updated first, with their review decision and merge state
+func (g *GithubProvider) GetOpenPullRequestsWithReadiness(owner, repo string) ([]PullRequest, error) {
+	body := map[string]any{
+		"query":     githubPullRequestReadinessQuery,
+		"variables": map[string]any{"owner": owner, "repo": repo},
+	}
+	req, err := g.client.NewRequest("POST", "graphql", body)
+	if err != nil {
+		return nil, err
+	}
+	// mergeStateStatus used to sit behind this preview, sending it doesn't hurt where it's not needed anymore
+	req.Header.Set("Accept", "application/vnd.github.merge-info-preview+json")
+
+	var response githubPullRequestReadinessResponse
+	if _, err := g.client.Do(context.Background(), req, &response); err != nil {
+		return nil, fmt.Errorf("xplane: error fetching PRs from Github: %v", err)
+	}
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("xplane: error fetching PRs from Github: %s", response.Errors[0].Message)
+	}
+
+	var results []PullRequest
+	for _, node := range response.Data.Repository.PullRequests.Nodes {
+		author := "ghost"
+		if node.Author != nil {
+			author = node.Author.Login
+		}
+		var labels []string
+		for _, label := range node.Labels.Nodes {
+			labels = append(labels, label.Name)
+		}
+		// reviewDecision is null when the base branch requires no review
+		review := strings.ToLower(node.ReviewDecision)
+		mergeState := strings.ToLower(node.MergeStateStatus)
+		results = append(results, PullRequest{
+			Number:         node.Number,
+			Title:          node.Title,
+			Author:         author,
+			Description:    node.Body,
+			URL:            node.URL,
+			Labels:         labels,
+			HeadSHA:        node.HeadRefOid,
+			HeadBranch:     node.HeadRefName,
+			BaseBranch:     node.BaseRefName,
+			CreatedAt:      node.CreatedAt,
+			UpdatedAt:      node.UpdatedAt,
+			ReviewDecision: review,
+			MergeState:     mergeState,
+			// 'clean' means checks pass and nothing conflicts, 'has_hooks' is the same with post-merge hooks
+			ReadyToMerge: !node.IsDraft && (review == "" || review == reviewApproved) &&
+				(mergeState == "clean" || mergeState == "has_hooks"),
+		})
+	}
+	return results, nil
+}
+
+// review threads and whether they're resolved are only exposed through graphql
+const githubReviewThreadsQuery = `query($owner: String!, $repo: String!, $number: Int!) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100) { nodes { isResolved } }
+    }
+  }
+}`
+
+type githubReviewThreadsResponse struct {
+	Data struct {
+		Repository struct {
+			PullRequest struct {
+				ReviewThreads struct {
+					Nodes []struct {
+						IsResolved bool `json:"isResolved"`
+					} `json:"nodes"`
+				} `json:"reviewThreads"`
+			} `json:"pullRequest"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// counts the unresolved review threads of a PR, out of its first 100
+func (g *GithubProvider) GetPullRequestUnresolvedThreads(owner, repo string, number int) (int, error) {
+	body := map[string]any{
+		"query":     githubReviewThreadsQuery,
+		"variables": map[string]any{"owner": owner, "repo": repo, "number": number},
+	}
+	req, err := g.client.NewRequest("POST", "graphql", body)
+	if err != nil {
+		return 0, err
+	}
+
+	var response githubReviewThreadsResponse
+	if _, err := g.client.Do(context.Background(), req, &response); err != nil {
+		return 0, fmt.Errorf("xplane: error fetching review threads of #%d from Github: %v", number, err)
+	}
+	if len(response.Errors) > 0 {
+		return 0, fmt.Errorf("xplane: error fetching review threads of #%d from Github: %s", number, response.Errors[0].Message)
+	}
+
+	unresolved := 0
+	for _, thread := range response.Data.Repository.PullRequest.ReviewThreads.Nodes {
+		if !thread.IsResolved {
+			unresolved++
+		}
+	}
+	return unresolved, nil
+}
+
+// one graphql call gets every branch with its last commit date, the rest api would need one call per branch
+const githubBranchesQuery = `query($owner: String!, $repo: String!) {
+  repository(owner: $owner, name: $repo) {
+    defaultBranchRef { name }
+    refs(refPrefix: "refs/heads/", first: 100, orderBy: {field: TAG_COMMIT_DATE, direction: ASC}) {
+      nodes {
+        name
+        target { ... on Commit { committedDate author { name } } }
+      }
+    }
+  }
+}`
+
+type githubBranchesResponse struct {
+	Data struct {
+		Repository struct {
+			DefaultBranchRef struct {
+				Name string `json:"name"`
+			} `json:"defaultBranchRef"`
+			Refs struct {
+				Nodes []struct {
+					Name   string `json:"name"`
+					Target struct {
+						CommittedDate time.Time `json:"committedDate"`
+						Author        struct {
+							Name string `json:"name"`
+						} `json:"author"`
+					} `json:"target"`
+				} `json:"nodes"`
+			} `json:"refs"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// lists up to 100 branches, oldest last commit first
+func (g *GithubProvider) ListBranches(owner, repo string) ([]RemoteBranch, error) {
+	body := map[string]any{
+		"query":     githubBranchesQuery,
+		"variables": map[string]any{"owner": owner, "repo": repo},
+	}
+	req, err := g.client.NewRequest("POST", "graphql", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response githubBranchesResponse
+	if _, err := g.client.Do(context.Background(), req, &response); err != nil {
+		return nil, fmt.Errorf("xplane: error fetching branches from Github: %v", err)
+	}
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("xplane: error fetching branches from Github: %s", response.Errors[0].Message)
+	}
+
+	var branches []RemoteBranch
+	for _, node := range response.Data.Repository.Refs.Nodes {
+		branches = append(branches, RemoteBranch{
+			Name:       node.Name,
+			LastCommit: node.Target.CommittedDate,
+			LastAuthor: node.Target.Author.Name,
+			IsDefault:  node.Name == response.Data.Repository.DefaultBranchRef.Name,
+		})
+	}
+	return branches, nil
+}
+
+func (g *GithubProvider) GetPullRequest(owner, repo string, number int) (*PullRequest, error) {
+	pr, _, err := g.client.PullRequests.Get(context.Background(), owner, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("xplane: error fetching PR #%d from Github: %v", number, err)
+	}
+	var labels []string
+	for _, label := range pr.Labels {
+		labels = append(labels, label.GetName())
+	}
+	return &PullRequest{
+		Number:      pr.GetNumber(),
+		Title:       pr.GetTitle(),
+		Author:      pr.GetUser().GetLogin(),
+		Description: pr.GetBody(),
+		URL:         pr.GetHTMLURL(),
+		Labels:      labels,
+		HeadSHA:     pr.GetHead().GetSHA(),
+		HeadBranch:  pr.GetHead().GetRef(),
+		BaseBranch:  pr.GetBase().GetRef(),
+		CreatedAt:   pr.GetCreatedAt().Time,
+		UpdatedAt:   pr.GetUpdatedAt().Time,
+	}, nil
+}
+
+// the pull request's unified diff against its base branch, as github renders it
+func (g *GithubProvider) GetPullRequestDiff(owner, repo string, number int) (string, error) {
+	diff, _, err := g.client.PullRequests.GetRaw(context.Background(), owner, repo, number, github.RawOptions{Type: github.Diff})
+	if err != nil {
+		return "", fmt.Errorf("xplane: error fetching the diff of PR #%d from Github: %v", number, err)
+	}
+	return diff, nil
+}
+
+// lists the paths a pull request touches, renamed files are listed under both paths
+func (g *GithubProvider) GetPullRequestFiles(owner, repo string, number int) ([]string, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	var files []string
+	for {
+		commitFiles, resp, err := g.client.PullRequests.ListFiles(context.Background(), owner, repo, number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("xplane: error fetching the files of PR #%d from Github: %v", number, err)
+		}
+		for _, file := range commitFiles {
+			files = append(files, file.GetFilename())
+			if file.GetPreviousFilename() != "" {
+				files = append(files, file.GetPreviousFilename())
+			}
+		}
+		if resp.NextPage == 0 {
+			return files, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// github has both commit statuses and check runs, a PR's head commit can have either or both
+func (g *GithubProvider) GetPullRequestCIStatus(owner, repo string, pr PullRequest) (string, error) {
+	ctx := context.Background()
+	combined, _, err := g.client.Repositories.GetCombinedStatus(ctx, owner, repo, pr.HeadSHA, nil)
+	if err != nil {
+		return "", fmt.Errorf("xplane: error fetching commit statuses of PR #%d from Github: %v", pr.Number, err)
+	}
+	checkRuns, _, err := g.client.Checks.ListCheckRunsForRef(ctx, owner, repo, pr.HeadSHA, nil)
+	if err != nil {
+		return "", fmt.Errorf("xplane: error fetching check runs of PR #%d from Github: %v", pr.Number, err)
+	}
+
+	var states []string
+	// the combined state reads 'pending' when there are no statuses at all
+	if combined.GetTotalCount() > 0 {
+		states = append(states, githubStatusStates[combined.GetState()])
+	}
+	for _, run := range checkRuns.CheckRuns {
+		if run.GetStatus() != "completed" {
+			states = append(states, ciPending)
+			continue
+		}
+		switch run.GetConclusion() {
+		case "success", "neutral", "skipped":
+			states = append(states, ciPassing)
+		default:
+			states = append(states, ciFailing)
+		}
+	}
+	return combineCIStates(states), nil
+}
+
 type GitlabProvider struct {
 	client            *gitlab.Client
+	hostURL           string
 	remoteOriginURL   string
 	remoteUpstreamURL string
 }
@@ -198,17 +746,68 @@ func (g *GitlabProvider) GetOpenPullRequests(owner, repo string) ([]PullRequest,
 	var results []PullRequest
 
 	for _, mr := range mrs {
-		results = append(results, PullRequest{
-			Title:       mr.Title,
-			Author:      mr.Author.Username,
-			Description: mr.Description,
-			URL:         mr.WebURL,
-		})
+		results = append(results, gitlabMergeRequestToPullRequest(mr))
+	}
+
+	return results, nil
+}
+
+func gitlabMergeRequestToPullRequest(mr *gitlab.BasicMergeRequest) PullRequest {
+	return PullRequest{
+		Number:      mr.IID,
+		Title:       mr.Title,
+		Author:      mr.Author.Username,
+		Description: mr.Description,
+		URL:         mr.WebURL,
+		Labels:      mr.Labels,
+		HeadSHA:     mr.SHA,
+		HeadBranch:  mr.SourceBranch,
+		BaseBranch:  mr.TargetBranch,
+		CreatedAt:   derefTime(mr.CreatedAt),
+		UpdatedAt:   derefTime(mr.UpdatedAt),
+	}
+}
+
+// the open MRs with gitlab's detailed merge status, which covers approvals, pipelines and conflicts.
+// the review decision comes from each MR's approvals, at the cost of one call per MR
+func (g *GitlabProvider) GetOpenPullRequestsWithReadiness(owner, repo string) ([]PullRequest, error) {
+	projectID := fmt.Sprintf("%s/%s", owner, repo)
+	prState := "opened"
+	mrs, _, err := g.client.MergeRequests.ListProjectMergeRequests(projectID, &gitlab.ListProjectMergeRequestsOptions{State: &prState})
+	if err != nil {
+		return nil, fmt.Errorf("xplane: error fetching MRs from Gitlab: %v", err)
 	}
 
+	var results []PullRequest
+	for _, mr := range mrs {
+		pr := gitlabMergeRequestToPullRequest(mr)
+		pr.ReviewDecision = g.reviewDecision(projectID, mr)
+		pr.MergeState = mr.DetailedMergeStatus
+		pr.ReadyToMerge = !mr.Draft && mr.DetailedMergeStatus == "mergeable"
+		results = append(results, pr)
+	}
 	return results, nil
 }
 
+// the closest gitlab has to github's review decision: a reviewer asking for changes, or else the MR's approvals.
+// empty when nobody approved an MR that needs no approval, or when the approvals can't be read
+func (g *GitlabProvider) reviewDecision(projectID string, mr *gitlab.BasicMergeRequest) string {
+	if mr.DetailedMergeStatus == "requested_changes" {
+		return reviewChangesRequested
+	}
+	approvals, _, err := g.client.MergeRequestApprovals.GetConfiguration(projectID, mr.IID)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case approvals.ApprovalsRequired == 0 && len(approvals.ApprovedBy) == 0:
+		return ""
+	case approvals.Approved:
+		return reviewApproved
+	}
+	return reviewRequired
+}
+
 func (g *GitlabProvider) GetLatestRelease(owner, repo string) (Release, error) {
 	projectID := fmt.Sprintf("%s/%s", owner, repo)
 
@@ -227,13 +826,53 @@ func (g *GitlabProvider) GetLatestRelease(owner, repo string) (Release, error) {
 		return Release{TagName: "No releases found"}, nil
 	}
 
-	latest := releases[0]
+	fallbackURL := fmt.Sprintf("%s/%s/-/releases/%s", strings.TrimRight(g.hostURL, "/"), projectID, url.PathEscape(releases[0].TagName))
+	return gitlabReleaseToRelease(releases[0], fallbackURL), nil
+}
+
+// the latest releases, newest first
+func (g *GitlabProvider) ListReleases(owner, repo string, limit int) ([]Release, error) {
+	projectID := fmt.Sprintf("%s/%s", owner, repo)
+	opts := &gitlab.ListReleasesOptions{ListOptions: gitlab.ListOptions{PerPage: min(limit, 100)}}
+	var releases []Release
+	for {
+		page, resp, err := g.client.Releases.ListReleases(projectID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("xplane: error fetching releases from Gitlab: %v", err)
+		}
+		for _, release := range page {
+			fallbackURL := fmt.Sprintf("%s/%s/-/releases/%s", strings.TrimRight(g.hostURL, "/"), projectID, url.PathEscape(release.TagName))
+			releases = append(releases, gitlabReleaseToRelease(release, fallbackURL))
+			if len(releases) == limit {
+				return releases, nil
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return releases, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// releases without assets come back without '_links', and upcoming ones without a release date
+func gitlabReleaseToRelease(release *gitlab.Release, fallbackURL string) Release {
+	releaseURL := release.Links.Self
+	if releaseURL == "" {
+		releaseURL = fallbackURL
+	}
+	publishedAt := "not released yet"
+	var publishedTime time.Time
+	if release.ReleasedAt != nil {
+		publishedAt = release.ReleasedAt.Format(releaseDateLayout)
+		publishedTime = *release.ReleasedAt
+	}
 	return Release{
-		TagName:     latest.TagName,
-		Name:        latest.Name,
-		URL:         latest.Links.Self,
-		PublishedAt: latest.ReleasedAt.Format("Sat, Nov 4, 1995"),
-	}, nil
+		TagName:       release.TagName,
+		Name:          release.Name,
+		URL:           releaseURL,
+		PublishedAt:   publishedAt,
+		publishedTime: publishedTime,
+	}
 }
 
 // helper that simplifies fetching commits from paged gitlab content
@@ -263,24 +902,28 @@ func (g *GitlabProvider) getAllCommits(projectID, branchName string) ([]*gitlab.
 	return allCommits, nil
 }
 
-func (g *GitlabProvider) CompareBranchWithDefault(owner, repo, originOwner, localBranch string) (BranchComparison, error) {
+// compares the local branch's remote copy with baseBranch, or with the project's default branch when baseBranch is empty
+func (g *GitlabProvider) CompareBranch(owner, repo, originOwner, localBranch, baseBranch string) (BranchComparison, error) {
 	upstreamProjectID := fmt.Sprintf("%s/%s", owner, repo)
 	forkProjectID := fmt.Sprintf("%s/%s", originOwner, repo)
 
-	project, _, err := g.client.Projects.GetProject(upstreamProjectID, nil)
-	if err != nil {
-		return BranchComparison{}, fmt.Errorf("xplane: could not get Gitlab repo info: %v", err)
+	defaultBranch := baseBranch
+	if defaultBranch == "" {
+		project, _, err := g.client.Projects.GetProject(upstreamProjectID, nil)
+		if err != nil {
+			return BranchComparison{}, fmt.Errorf("xplane: could not get Gitlab repo info: %v", err)
+		}
+		defaultBranch = project.DefaultBranch
 	}
-	defaultBranch := project.DefaultBranch
 
 	if localBranch == defaultBranch && owner == originOwner {
-		return BranchComparison{Status: "identical"}, nil
+		return BranchComparison{Status: "identical", BaseBranch: defaultBranch, ExplicitBase: baseBranch != ""}, nil
 	}
 
 	// I need to implement cross-fork comparison logic manually
 	upstreamCommits, err := g.getAllCommits(upstreamProjectID, defaultBranch)
 	if err != nil {
-		return BranchComparison{}, fmt.Errorf("xplane: could not list commits for upstream default branch: %w", err)
+		return BranchComparison{}, fmt.Errorf("xplane: could not list commits for upstream branch '%s': %w", defaultBranch, err)
 	}
 	upstreamCommitMap := make(map[string]bool)
 	for _, commit := range upstreamCommits {
@@ -325,12 +968,217 @@ func (g *GitlabProvider) CompareBranchWithDefault(owner, repo, originOwner, loca
 	}
 
 	return BranchComparison{
-		AheadBy:  aheadBy,
-		BehindBy: behindBy,
-		Status:   status,
+		AheadBy:      aheadBy,
+		BehindBy:     behindBy,
+		Status:       status,
+		BaseBranch:   defaultBranch,
+		ExplicitBase: baseBranch != "",
 	}, nil
 }
 
+func (g *GitlabProvider) GetIssue(owner, repo string, number int) (Issue, error) {
+	projectID := fmt.Sprintf("%s/%s", owner, repo)
+	issue, resp, err := g.client.Issues.GetIssue(projectID, number)
+	if err != nil {
+		if resp != nil && isNotFound(resp.Response) {
+			return Issue{}, fmt.Errorf("xplane: issue #%d on Gitlab: %w", number, errIssueNotFound)
+		}
+		return Issue{}, fmt.Errorf("xplane: error fetching issue #%d from Gitlab: %v", number, err)
+	}
+
+	return Issue{
+		Number: issue.IID,
+		Title:  issue.Title,
+		State:  issue.State,
+		URL:    issue.WebURL,
+	}, nil
+}
+
+func (g *GitlabProvider) FindPullRequestForBranch(owner, repo, originOwner, branchName string) (*PullRequest, error) {
+	projectID := fmt.Sprintf("%s/%s", owner, repo)
+	prState := "opened"
+	opts := &gitlab.ListProjectMergeRequestsOptions{
+		State:        &prState,
+		SourceBranch: &branchName,
+	}
+	mrs, _, err := g.client.MergeRequests.ListProjectMergeRequests(projectID, opts)
+	if err != nil {
+		return nil, fmt.Errorf("xplane: error looking up the MR for branch '%s' on Gitlab: %v", branchName, err)
+	}
+
+	if len(mrs) == 0 {
+		return nil, nil
+	}
+
+	mr := mrs[0]
+	return &PullRequest{
+		Number:      mr.IID,
+		Title:       mr.Title,
+		Author:      mr.Author.Username,
+		Description: mr.Description,
+		URL:         mr.WebURL,
+	}, nil
+}
+
+func (g *GitlabProvider) PostPullRequestComment(owner, repo string, number int, body string) error {
+	projectID := fmt.Sprintf("%s/%s", owner, repo)
+	_, _, err := g.client.Notes.CreateMergeRequestNote(projectID, number, &gitlab.CreateMergeRequestNoteOptions{Body: &body})
+	if err != nil {
+		return fmt.Errorf("xplane: error commenting on MR !%d on Gitlab: %v", number, err)
+	}
+	return nil
+}
+
+func (g *GitlabProvider) GetMergeStatus(owner, repo string, number int) (MergeStatus, error) {
+	projectID := fmt.Sprintf("%s/%s", owner, repo)
+	mr, _, err := g.client.MergeRequests.GetMergeRequest(projectID, number, nil)
+	if err != nil {
+		return MergeStatus{}, fmt.Errorf("xplane: error fetching MR !%d from Gitlab: %v", number, err)
+	}
+
+	state := mr.DetailedMergeStatus
+	if state == "" {
+		state = "unknown"
+	}
+	return MergeStatus{
+		Number:    number,
+		State:     state,
+		Mergeable: state == "mergeable",
+		Draft:     mr.Draft,
+	}, nil
+}
+
+func (g *GitlabProvider) GetPullRequestCIStatus(owner, repo string, pr PullRequest) (string, error) {
+	projectID := fmt.Sprintf("%s/%s", owner, repo)
+	mr, _, err := g.client.MergeRequests.GetMergeRequest(projectID, pr.Number, nil)
+	if err != nil {
+		return "", fmt.Errorf("xplane: error fetching MR !%d from Gitlab: %v", pr.Number, err)
+	}
+	if mr.HeadPipeline == nil {
+		return ciNone, nil
+	}
+	return combineCIStates([]string{gitlabPipelineStates[mr.HeadPipeline.Status]}), nil
+}
+
+// counts the MR's discussions with a resolvable note that isn't resolved yet, plain comments can't be resolved
+func (g *GitlabProvider) GetPullRequestUnresolvedThreads(owner, repo string, number int) (int, error) {
+	projectID := fmt.Sprintf("%s/%s", owner, repo)
+	opts := &gitlab.ListMergeRequestDiscussionsOptions{PerPage: 100, Page: 1}
+
+	unresolved := 0
+	for {
+		discussions, resp, err := g.client.Discussions.ListMergeRequestDiscussions(projectID, number, opts)
+		if err != nil {
+			return 0, fmt.Errorf("xplane: error fetching discussions of MR !%d from Gitlab: %v", number, err)
+		}
+		for _, discussion := range discussions {
+			if hasUnresolvedNote(discussion.Notes) {
+				unresolved++
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return unresolved, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func hasUnresolvedNote(notes []*gitlab.Note) bool {
+	for _, note := range notes {
+		if note.Resolvable && !note.Resolved {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *GitlabProvider) GetPullRequest(owner, repo string, number int) (*PullRequest, error) {
+	projectID := fmt.Sprintf("%s/%s", owner, repo)
+	mr, _, err := g.client.MergeRequests.GetMergeRequest(projectID, number, nil)
+	if err != nil {
+		return nil, fmt.Errorf("xplane: error fetching MR !%d from Gitlab: %v", number, err)
+	}
+	pr := gitlabMergeRequestToPullRequest(&mr.BasicMergeRequest)
+	return &pr, nil
+}
+
+// gitlab hands out the changes file by file, they're stitched back into one unified diff
+func (g *GitlabProvider) GetPullRequestDiff(owner, repo string, number int) (string, error) {
+	projectID := fmt.Sprintf("%s/%s", owner, repo)
+	opts := &gitlab.ListMergeRequestDiffsOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	var diff strings.Builder
+	for {
+		diffs, resp, err := g.client.MergeRequests.ListMergeRequestDiffs(projectID, number, opts)
+		if err != nil {
+			return "", fmt.Errorf("xplane: error fetching the changes of MR !%d from Gitlab: %v", number, err)
+		}
+		for _, file := range diffs {
+			fmt.Fprintf(&diff, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n%s", file.OldPath, file.NewPath, file.OldPath, file.NewPath, file.Diff)
+			if !strings.HasSuffix(file.Diff, "\n") {
+				diff.WriteString("\n")
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return diff.String(), nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// lists the paths a merge request touches, renamed files are listed under both paths
+func (g *GitlabProvider) GetPullRequestFiles(owner, repo string, number int) ([]string, error) {
+	projectID := fmt.Sprintf("%s/%s", owner, repo)
+	opts := &gitlab.ListMergeRequestDiffsOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	var files []string
+	for {
+		diffs, resp, err := g.client.MergeRequests.ListMergeRequestDiffs(projectID, number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("xplane: error fetching the changes of MR !%d from Gitlab: %v", number, err)
+		}
+		for _, diff := range diffs {
+			files = append(files, diff.NewPath)
+			if diff.OldPath != diff.NewPath {
+				files = append(files, diff.OldPath)
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return files, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *GitlabProvider) ListBranches(owner, repo string) ([]RemoteBranch, error) {
+	projectID := fmt.Sprintf("%s/%s", owner, repo)
+	opts := &gitlab.ListBranchesOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	var branches []RemoteBranch
+	for {
+		page, resp, err := g.client.Branches.ListBranches(projectID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("xplane: error fetching branches from Gitlab: %v", err)
+		}
+		for _, branch := range page {
+			remoteBranch := RemoteBranch{Name: branch.Name, IsDefault: branch.Default}
+			if branch.Commit != nil {
+				remoteBranch.LastAuthor = branch.Commit.AuthorName
+				if branch.Commit.CommittedDate != nil {
+					remoteBranch.LastCommit = *branch.Commit.CommittedDate
+				}
+			}
+			branches = append(branches, remoteBranch)
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return branches, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// gitlab has no discussions forum, its "discussions" are comment threads on issues and MRs
+func (g *GitlabProvider) GetRecentDiscussions(owner, repo string, limit int) ([]Discussion, error) {
+	return nil, fmt.Errorf("xplane: discussions are only available on Github")
+}
+
 func NewGitHubProvider(token string, remoteOriginURL string, remoteUpstreamURL string) *GithubProvider {
 	ctx := context.Background()
 	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
@@ -343,29 +1191,121 @@ func NewGitHubProvider(token string, remoteOriginURL string, remoteUpstreamURL s
 	}
 }
 
+// SetUserAgent sets the User-Agent of the provider's api calls, an empty one keeps go-github's own
+func (g *GithubProvider) SetUserAgent(userAgent string) {
+	if userAgent != "" {
+		g.client.UserAgent = userAgent
+	}
+}
+
 func NewGitlabProvider(token string, hostURL string, remoteOriginURL string, remoteUpstreamURL string) (*GitlabProvider, error) {
 	client, err := gitlab.NewClient(token, gitlab.WithBaseURL(hostURL))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gitlab client: %w", err)
 	}
 
-	return &GitlabProvider{client: client, remoteOriginURL: remoteOriginURL, remoteUpstreamURL: remoteUpstreamURL}, nil
+	return &GitlabProvider{client: client, hostURL: hostURL, remoteOriginURL: remoteOriginURL, remoteUpstreamURL: remoteUpstreamURL}, nil
+}
+
+// SetUserAgent sets the User-Agent of the provider's api calls, an empty one keeps the gitlab client's own
+func (g *GitlabProvider) SetUserAgent(userAgent string) {
+	if userAgent != "" {
+		g.client.UserAgent = userAgent
+	}
 }
 
 type GitEntity interface {
 	Format() string
 }
 
+// renders an entity with a user template (XPLANE_PR_TEMPLATE, XPLANE_RELEASE_TEMPLATE), or with its own Format()
+// when there's none. a template that fails on an entity falls back to Format() too, a typo shouldn't lose the data
+func formatEntity(entity GitEntity, tmpl *template.Template) string {
+	if tmpl == nil {
+		return entity.Format()
+	}
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, entity); err != nil {
+		log.Printf("Warning: Could not apply %s: %s", tmpl.Name(), redactError(err))
+		return entity.Format()
+	}
+	return builder.String()
+}
+
+// the helpers templates get on top of text/template's own, e.g. '{{join .Labels ", "}}'
+var entityTemplateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+}
+
+func parseEntityTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	return template.New(name).Funcs(entityTemplateFuncs).Parse(text)
+}
+
 type PullRequest struct {
+	Number      int
 	Title       string
 	Author      string
 	Description string
 	URL         string
+	Labels      []string
+	HeadSHA     string
+	HeadBranch  string
+	BaseBranch  string    // the branch it merges into
+	CreatedAt   time.Time // zero when the provider didn't say
+	UpdatedAt   time.Time
+	CIStatus    string // only filled when XPLANE_PR_CI_STATUS is on
+	// only filled when XPLANE_PR_THREADS is on, threadsFetched tells a real zero from an unchecked PR
+	UnresolvedThreads int
+	threadsFetched    bool
+	// only filled by GetOpenPullRequestsWithReadiness
+	ReviewDecision string // one of the review* constants, empty when unknown or no review is required
+	MergeState     string // the provider's own merge state, e.g. 'clean' on github or 'mergeable' on gitlab
+	ReadyToMerge   bool   // approved, checks passing and nothing blocking
+}
+
+// gitlab leaves timestamps nil when it has none, the zero time stands in for them
+func derefTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
 }
 
+// review decisions, normalized across providers
+const (
+	reviewApproved         = "approved"
+	reviewRequired         = "review_required"
+	reviewChangesRequested = "changes_requested"
+)
+
 func (pr *PullRequest) Format() string {
 	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf("- %s (by %s)\n  URL: %s\n  Body: %s\n\n", pr.Title, pr.Author, pr.URL, pr.Description))
+	builder.WriteString(fmt.Sprintf("- %s (by %s)\n  URL: %s\n", pr.Title, pr.Author, pr.URL))
+	if pr.BaseBranch != "" {
+		builder.WriteString(fmt.Sprintf("  Target branch: %s\n", pr.BaseBranch))
+	}
+	if len(pr.Labels) > 0 {
+		builder.WriteString(fmt.Sprintf("  Labels: %s\n", strings.Join(pr.Labels, ", ")))
+	}
+	if pr.CIStatus != "" {
+		builder.WriteString(fmt.Sprintf("  CI: %s\n", pr.CIStatus))
+	}
+	if pr.threadsFetched {
+		builder.WriteString(fmt.Sprintf("  Unresolved review threads: %d\n", pr.UnresolvedThreads))
+	}
+	if pr.ReviewDecision != "" {
+		builder.WriteString(fmt.Sprintf("  Review: %s\n", pr.ReviewDecision))
+	}
+	if pr.MergeState != "" {
+		builder.WriteString(fmt.Sprintf("  Merge state: %s\n", pr.MergeState))
+	}
+	builder.WriteString(fmt.Sprintf("  Body: %s\n\n", pr.Description))
 	output := builder.String()
 	if output == "" {
 		output = "No open pull/merge requests found."
@@ -375,12 +1315,16 @@ func (pr *PullRequest) Format() string {
 }
 
 type Release struct {
-	TagName     string
-	Name        string
-	URL         string
-	PublishedAt string
+	TagName       string
+	Name          string
+	URL           string
+	PublishedAt   string
+	publishedTime time.Time // zero for upcoming releases
 }
 
+// how release dates are shown, e.g. 'Fri, Aug 1, 2025'
+const releaseDateLayout = "Mon, Jan 2, 2006"
+
 func (r *Release) Format() string {
 	var builder strings.Builder
 	builder.WriteString(fmt.Sprintf("Release %s@%s\n  URL: %s\n\n  Published: %s\n", r.Name, r.TagName, r.URL, r.PublishedAt))
@@ -392,15 +1336,164 @@ func (r *Release) Format() string {
 	return output
 }
 
+type Issue struct {
+	Number        int
+	Title         string
+	State         string
+	URL           string
+	IsPullRequest bool
+}
+
+func (i *Issue) Format() string {
+	return fmt.Sprintf("- #%d %s (%s)\n  URL: %s\n", i.Number, i.Title, i.State, i.URL)
+}
+
+// describes the merge states reported by github (mergeable_state) and gitlab (detailed_merge_status)
+var mergeStateDescriptions = map[string]string{
+	"clean":                    "all requirements met",
+	"mergeable":                "all requirements met",
+	"blocked":                  "blocked by required reviews or status checks",
+	"behind":                   "head branch is behind the base branch",
+	"dirty":                    "merge conflicts",
+	"conflict":                 "merge conflicts",
+	"unstable":                 "non-required status checks are failing",
+	"draft":                    "still a draft",
+	"draft_status":             "still a draft",
+	"not_approved":             "waiting for required approvals",
+	"ci_must_pass":             "waiting for the pipeline to pass",
+	"ci_still_running":         "pipeline still running",
+	"discussions_not_resolved": "unresolved discussions",
+	"need_rebase":              "needs a rebase",
+	"unknown":                  "not computed yet",
+}
+
+const (
+	ciPassing = "passing"
+	ciFailing = "failing"
+	ciPending = "pending"
+	ciNone    = "no CI"
+)
+
+var githubStatusStates = map[string]string{
+	"success": ciPassing,
+	"pending": ciPending,
+	"failure": ciFailing,
+	"error":   ciFailing,
+}
+
+var gitlabPipelineStates = map[string]string{
+	"success":              ciPassing,
+	"skipped":              ciPassing,
+	"manual":               ciPending,
+	"created":              ciPending,
+	"waiting_for_resource": ciPending,
+	"preparing":            ciPending,
+	"pending":              ciPending,
+	"running":              ciPending,
+	"scheduled":            ciPending,
+	"failed":               ciFailing,
+	"canceled":             ciFailing,
+}
+
+// any failure wins over anything still running, which wins over passing
+func combineCIStates(states []string) string {
+	if len(states) == 0 {
+		return ciNone
+	}
+	combined := ciPassing
+	for _, state := range states {
+		switch state {
+		case ciFailing:
+			return ciFailing
+		case ciPassing:
+		default:
+			// includes states we don't know about, better to say pending than passing
+			combined = ciPending
+		}
+	}
+	return combined
+}
+
+type MergeStatus struct {
+	Number    int
+	State     string
+	Mergeable bool
+	Draft     bool
+}
+
+func (m *MergeStatus) Format() string {
+	readiness := "no"
+	if m.Mergeable {
+		readiness = "yes"
+	}
+	state := m.State
+	if description, ok := mergeStateDescriptions[m.State]; ok {
+		state = fmt.Sprintf("%s (%s)", m.State, description)
+	}
+	return fmt.Sprintf("Merge status of #%d for the current branch:\n  State: %s\n  Draft: %t\n  Ready to merge: %s\n", m.Number, state, m.Draft, readiness)
+}
+
+// caps the discussion body sent as context, the opening post is usually enough to get the gist
+const maxDiscussionBodyLength = 300
+
+type Discussion struct {
+	Number   int
+	Title    string
+	Author   string
+	Category string
+	URL      string
+	Body     string
+	Answered bool
+}
+
+func (d *Discussion) Format() string {
+	body := strings.Join(strings.Fields(d.Body), " ")
+	if runes := []rune(body); len(runes) > maxDiscussionBodyLength {
+		body = string(runes[:maxDiscussionBodyLength]) + "..."
+	}
+	answered := ""
+	if d.Answered {
+		answered = ", answered"
+	}
+	return fmt.Sprintf("- #%d %s (by %s, %s%s)\n  URL: %s\n  Summary: %s\n", d.Number, d.Title, d.Author, d.Category, answered, d.URL, body)
+}
+
+type RemoteBranch struct {
+	Name       string
+	LastCommit time.Time
+	LastAuthor string
+	IsDefault  bool
+}
+
+func (b *RemoteBranch) Format(now time.Time) string {
+	days := int(now.Sub(b.LastCommit).Hours() / 24)
+	return fmt.Sprintf("- %s: last commit %s by %s (%d days ago)\n", b.Name, b.LastCommit.Format("2006-01-02"), b.LastAuthor, days)
+}
+
 type BranchComparison struct {
-	AheadBy  int
-	BehindBy int
-	Status   string
+	AheadBy    int
+	BehindBy   int
+	Status     string
+	BaseBranch string // the remote branch the local branch was compared against, the default one unless ExplicitBase
+	// the base was configured with XPLANE_COMPARE_BRANCH rather than being the default branch
+	ExplicitBase bool
+	// the local branch and its own remote branch hold different copies of the same commits
+	HistoryRewritten bool
 }
 
 func (b *BranchComparison) Format() string {
+	baseBranch := "default branch"
+	if b.ExplicitBase {
+		baseBranch = fmt.Sprintf("branch '%s'", b.BaseBranch)
+	} else if b.BaseBranch != "" {
+		baseBranch = fmt.Sprintf("default branch '%s'", b.BaseBranch)
+	}
+
 	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf("Local branch vs main branch:\n  Status: %s\n  AheadBy: %d\n  BehindBy: %d\n", b.Status, b.AheadBy, b.BehindBy))
+	builder.WriteString(fmt.Sprintf("Local branch vs %s:\n  Status: %s\n  AheadBy: %d\n  BehindBy: %d\n", baseBranch, b.Status, b.AheadBy, b.BehindBy))
+	if b.HistoryRewritten {
+		builder.WriteString("  History rewritten / force-pushed: the local branch and its remote branch have diverged copies of the same commits\n")
+	}
 	output := builder.String()
 	if output == "" {
 		output = "No branch comparison info between local and remote/upstream found."
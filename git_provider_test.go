@@ -1,9 +1,18 @@
-package main
+package xplane
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/go-github/v74/github"
 	"github.com/stretchr/testify/assert"
+	"gitlab.com/gitlab-org/api/client-go"
 )
 
 func TestParseGitURL(t *testing.T) {
@@ -42,4 +51,441 @@ func TestParseGitURL(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestBranchComparisonFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		comparison BranchComparison
+		expected   string
+	}{
+		{
+			"named default branch",
+			BranchComparison{AheadBy: 2, BehindBy: 1, Status: "diverged", BaseBranch: "develop"},
+			"Local branch vs default branch 'develop':\n  Status: diverged\n  AheadBy: 2\n  BehindBy: 1\n",
+		},
+		{
+			"unknown default branch",
+			BranchComparison{Status: "identical"},
+			"Local branch vs default branch:\n  Status: identical\n  AheadBy: 0\n  BehindBy: 0\n",
+		},
+		{
+			"configured base branch",
+			BranchComparison{BehindBy: 4, Status: "behind", BaseBranch: "release/2.x", ExplicitBase: true},
+			"Local branch vs branch 'release/2.x':\n  Status: behind\n  AheadBy: 0\n  BehindBy: 4\n",
+		},
+		{
+			"rewritten history",
+			BranchComparison{AheadBy: 3, Status: "ahead", BaseBranch: "main", HistoryRewritten: true},
+			"Local branch vs default branch 'main':\n  Status: ahead\n  AheadBy: 3\n  BehindBy: 0\n  History rewritten / force-pushed: the local branch and its remote branch have diverged copies of the same commits\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.comparison.Format())
+		})
+	}
+}
+
+func TestMergeStatusFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   MergeStatus
+		expected string
+	}{
+		{
+			"ready github PR",
+			MergeStatus{Number: 4, State: "clean", Mergeable: true},
+			"Merge status of #4 for the current branch:\n  State: clean (all requirements met)\n  Draft: false\n  Ready to merge: yes\n",
+		},
+		{
+			"blocked gitlab draft MR",
+			MergeStatus{Number: 9, State: "not_approved", Draft: true},
+			"Merge status of #9 for the current branch:\n  State: not_approved (waiting for required approvals)\n  Draft: true\n  Ready to merge: no\n",
+		},
+		{
+			"unknown state is passed through",
+			MergeStatus{Number: 1, State: "something_new"},
+			"Merge status of #1 for the current branch:\n  State: something_new\n  Draft: false\n  Ready to merge: no\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.status.Format())
+		})
+	}
+}
+
+func TestPullRequestFormat(t *testing.T) {
+	pr := PullRequest{Title: "Fix crash", Author: "jane", URL: "https://example.com/pr/1", Description: "details"}
+	assert.Equal(t, "- Fix crash (by jane)\n  URL: https://example.com/pr/1\n  Body: details\n\n", pr.Format())
+
+	pr.Labels = []string{"bug", "urgent"}
+	assert.Equal(t, "- Fix crash (by jane)\n  URL: https://example.com/pr/1\n  Labels: bug, urgent\n  Body: details\n\n", pr.Format())
+
+	pr.CIStatus = ciFailing
+	assert.Equal(t, "- Fix crash (by jane)\n  URL: https://example.com/pr/1\n  Labels: bug, urgent\n  CI: failing\n  Body: details\n\n", pr.Format())
+}
+
+func TestGithubGetRecentDiscussions(t *testing.T) {
+	var variables map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/graphql", r.URL.Path)
+		var request struct {
+			Variables map[string]any `json:"variables"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		variables = request.Variables
+		w.Write([]byte(`{"data": {"repository": {"discussions": {"nodes": [
+			{"number": 7, "title": "RFC: plugin system", "url": "https://github.com/o/r/discussions/7", "bodyText": "Should we?", "author": {"login": "jane"}, "category": {"name": "Ideas"}, "answer": null},
+			{"number": 3, "title": "How to configure?", "url": "https://github.com/o/r/discussions/3", "bodyText": "Like this", "author": null, "category": {"name": "Q&A"}, "answer": {"id": "x"}}
+		]}}}}`))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	provider := &GithubProvider{client: client}
+
+	discussions, err := provider.GetRecentDiscussions("o", "r", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"owner": "o", "repo": "r", "limit": 5.0}, variables)
+	assert.Equal(t, []Discussion{
+		{Number: 7, Title: "RFC: plugin system", Author: "jane", Category: "Ideas", URL: "https://github.com/o/r/discussions/7", Body: "Should we?"},
+		{Number: 3, Title: "How to configure?", Author: "ghost", Category: "Q&A", URL: "https://github.com/o/r/discussions/3", Body: "Like this", Answered: true},
+	}, discussions)
+}
+
+func TestDiscussionFormat(t *testing.T) {
+	discussion := Discussion{Number: 3, Title: "How to configure?", Author: "jane", Category: "Q&A", URL: "https://example.com/3", Body: "Like\n\nthis", Answered: true}
+	assert.Equal(t, "- #3 How to configure? (by jane, Q&A, answered)\n  URL: https://example.com/3\n  Summary: Like this\n", discussion.Format())
+
+	discussion.Body = strings.Repeat("a", maxDiscussionBodyLength+10)
+	assert.Contains(t, discussion.Format(), "  Summary: "+strings.Repeat("a", maxDiscussionBodyLength)+"...\n")
+}
+
+// answers GetConfiguration with the approvals of each MR, by IID
+type fakeGitlabApprovals struct {
+	gitlab.MergeRequestApprovalsServiceInterface
+	approvals map[int]*gitlab.MergeRequestApprovals
+}
+
+func (f *fakeGitlabApprovals) GetConfiguration(pid any, mr int, options ...gitlab.RequestOptionFunc) (*gitlab.MergeRequestApprovals, *gitlab.Response, error) {
+	if approvals, ok := f.approvals[mr]; ok {
+		return approvals, nil, nil
+	}
+	return nil, nil, errors.New("403 Forbidden")
+}
+
+func TestGitlabReviewDecision(t *testing.T) {
+	approver := []*gitlab.MergeRequestApproverUser{{User: &gitlab.BasicUser{Username: "jane"}}}
+	provider := &GitlabProvider{client: &gitlab.Client{MergeRequestApprovals: &fakeGitlabApprovals{approvals: map[int]*gitlab.MergeRequestApprovals{
+		1: {Approved: true, ApprovalsRequired: 1, ApprovedBy: approver},
+		2: {Approved: false, ApprovalsRequired: 2, ApprovedBy: approver},
+		3: {Approved: true},
+		4: {Approved: true, ApprovedBy: approver},
+	}}}}
+
+	tests := []struct {
+		name     string
+		mr       *gitlab.BasicMergeRequest
+		expected string
+	}{
+		{"approved", &gitlab.BasicMergeRequest{IID: 1, DetailedMergeStatus: "mergeable"}, reviewApproved},
+		{"approvals left", &gitlab.BasicMergeRequest{IID: 2, DetailedMergeStatus: "not_approved"}, reviewRequired},
+		{"no approval required", &gitlab.BasicMergeRequest{IID: 3, DetailedMergeStatus: "mergeable"}, ""},
+		{"approved without being required", &gitlab.BasicMergeRequest{IID: 4, DetailedMergeStatus: "mergeable"}, reviewApproved},
+		{"changes requested", &gitlab.BasicMergeRequest{IID: 5, DetailedMergeStatus: "requested_changes"}, reviewChangesRequested},
+		{"approvals unreadable", &gitlab.BasicMergeRequest{IID: 6, DetailedMergeStatus: "mergeable"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, provider.reviewDecision("o/r", tt.mr))
+		})
+	}
+}
+
+func TestGitlabReleaseToRelease(t *testing.T) {
+	releasedAt := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	fallbackURL := "https://gitlab.com/o/r/-/releases/v1.0.0"
+
+	t.Run("release with links", func(t *testing.T) {
+		release := &gitlab.Release{TagName: "v1.0.0", Name: "First", ReleasedAt: &releasedAt}
+		release.Links.Self = "https://gitlab.com/o/r/-/releases/v1.0.0/self"
+		converted := gitlabReleaseToRelease(release, fallbackURL)
+		assert.Equal(t, "https://gitlab.com/o/r/-/releases/v1.0.0/self", converted.URL)
+		assert.Equal(t, "Fri, Aug 1, 2025", converted.PublishedAt)
+	})
+
+	t.Run("release without links or date", func(t *testing.T) {
+		release := &gitlab.Release{TagName: "v1.0.0", Name: "First"}
+		converted := gitlabReleaseToRelease(release, fallbackURL)
+		assert.Equal(t, fallbackURL, converted.URL)
+		assert.Equal(t, "not released yet", converted.PublishedAt)
+	})
+}
+
+func TestCombineCIStates(t *testing.T) {
+	tests := []struct {
+		name     string
+		states   []string
+		expected string
+	}{
+		{"no checks", nil, ciNone},
+		{"all passing", []string{ciPassing, ciPassing}, ciPassing},
+		{"one still running", []string{ciPassing, ciPending}, ciPending},
+		{"failure wins", []string{ciPending, ciFailing, ciPassing}, ciFailing},
+		{"unknown state", []string{ciPassing, ""}, ciPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, combineCIStates(tt.states))
+		})
+	}
+}
+
+func TestGithubGetPullRequestFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/o/r/pulls/17/files", r.URL.Path)
+		w.Write([]byte(`[{"filename": "auth.go"}, {"filename": "docs/auth.md", "previous_filename": "docs/login.md"}]`))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	provider := &GithubProvider{client: client}
+
+	files, err := provider.GetPullRequestFiles("o", "r", 17)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"auth.go", "docs/auth.md", "docs/login.md"}, files)
+}
+
+func TestGithubGetIssueNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/issues/1":
+			w.Write([]byte(`{"number": 1, "title": "Crash", "state": "closed"}`))
+		case "/repos/o/r/issues/2":
+			w.WriteHeader(http.StatusGone)
+		case "/repos/o/r/issues/3":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	provider := &GithubProvider{client: client}
+
+	issue, err := provider.GetIssue("o", "r", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "closed", issue.State)
+
+	_, err = provider.GetIssue("o", "r", 2)
+	assert.ErrorIs(t, err, errIssueNotFound, "deleted issues count as missing")
+	_, err = provider.GetIssue("o", "r", 4)
+	assert.ErrorIs(t, err, errIssueNotFound)
+
+	_, err = provider.GetIssue("o", "r", 3)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errIssueNotFound, "an auth failure is a real error")
+}
+
+func TestGithubGetPullRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/o/r/pulls/17", r.URL.Path)
+		if strings.Contains(r.Header.Get("Accept"), "diff") {
+			w.Write([]byte("diff --git a/auth.go b/auth.go\n+// token refresh\n"))
+			return
+		}
+		w.Write([]byte(`{"number": 17, "title": "Refresh tokens", "body": "Fixes #3", "user": {"login": "alice"}, "labels": [{"name": "auth"}], "head": {"ref": "refresh", "sha": "abc"}, "created_at": "2025-08-01T10:00:00Z", "updated_at": "2025-08-03T10:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	provider := &GithubProvider{client: client}
+
+	pr, err := provider.GetPullRequest("o", "r", 17)
+	assert.NoError(t, err)
+	assert.Equal(t, &PullRequest{Number: 17, Title: "Refresh tokens", Author: "alice", Description: "Fixes #3", Labels: []string{"auth"}, HeadSHA: "abc", HeadBranch: "refresh",
+		CreatedAt: time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC), UpdatedAt: time.Date(2025, 8, 3, 10, 0, 0, 0, time.UTC)}, pr)
+
+	diff, err := provider.GetPullRequestDiff("o", "r", 17)
+	assert.NoError(t, err)
+	assert.Equal(t, "diff --git a/auth.go b/auth.go\n+// token refresh\n", diff)
+}
+
+func TestGithubListBranches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/graphql", r.URL.Path)
+		w.Write([]byte(`{"data": {"repository": {"defaultBranchRef": {"name": "main"}, "refs": {"nodes": [
+			{"name": "old-spike", "target": {"committedDate": "2024-01-02T10:00:00Z", "author": {"name": "jane"}}},
+			{"name": "main", "target": {"committedDate": "2025-06-01T10:00:00Z", "author": {"name": "joe"}}}
+		]}}}}`))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	provider := &GithubProvider{client: client}
+
+	branches, err := provider.ListBranches("o", "r")
+	assert.NoError(t, err)
+	assert.Equal(t, []RemoteBranch{
+		{Name: "old-spike", LastCommit: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), LastAuthor: "jane"},
+		{Name: "main", LastCommit: time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC), LastAuthor: "joe", IsDefault: true},
+	}, branches)
+}
+
+func TestRemoteBranchFormat(t *testing.T) {
+	branch := RemoteBranch{Name: "old-spike", LastCommit: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), LastAuthor: "jane"}
+	now := time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, "- old-spike: last commit 2024-01-02 by jane (90 days ago)\n", branch.Format(now))
+}
+
+func TestGithubCompareBranch(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/repos/o/r":
+			w.Write([]byte(`{"default_branch": "main"}`))
+		default:
+			w.Write([]byte(`{"ahead_by": 2, "behind_by": 1, "status": "diverged"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	provider := &GithubProvider{client: client}
+
+	comparison, err := provider.CompareBranch("o", "r", "me", "feature", "")
+	assert.NoError(t, err)
+	assert.Equal(t, BranchComparison{AheadBy: 2, BehindBy: 1, Status: "diverged", BaseBranch: "main"}, comparison)
+	assert.Equal(t, []string{"/repos/o/r", "/repos/o/r/compare/main...me:feature"}, requested)
+
+	requested = nil
+	comparison, err = provider.CompareBranch("o", "r", "me", "feature", "release/2.x")
+	assert.NoError(t, err)
+	assert.Equal(t, BranchComparison{AheadBy: 2, BehindBy: 1, Status: "diverged", BaseBranch: "release/2.x", ExplicitBase: true}, comparison)
+	assert.Equal(t, []string{"/repos/o/r/compare/release/2.x...me:feature"}, requested)
+}
+
+func TestGithubGetOpenPullRequestsWithReadiness(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/graphql", r.URL.Path)
+		w.Write([]byte(`{"data": {"repository": {"pullRequests": {"nodes": [
+			{"number": 1, "title": "Ready", "author": {"login": "jane"}, "labels": {"nodes": [{"name": "bug"}]},
+			 "reviewDecision": "APPROVED", "mergeStateStatus": "CLEAN"},
+			{"number": 2, "title": "No review needed", "author": {"login": "joe"}, "reviewDecision": null, "mergeStateStatus": "CLEAN"},
+			{"number": 3, "title": "Checks failing", "author": {"login": "joe"}, "reviewDecision": "APPROVED", "mergeStateStatus": "UNSTABLE"},
+			{"number": 4, "title": "Awaiting review", "author": null, "reviewDecision": "REVIEW_REQUIRED", "mergeStateStatus": "BLOCKED"},
+			{"number": 5, "title": "Draft", "isDraft": true, "author": {"login": "jane"}, "reviewDecision": "APPROVED", "mergeStateStatus": "DRAFT"}
+		]}}}}`))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	provider := &GithubProvider{client: client}
+
+	prs, err := provider.GetOpenPullRequestsWithReadiness("o", "r")
+	assert.NoError(t, err)
+	assert.Len(t, prs, 5)
+
+	var ready []int
+	for _, pr := range prs {
+		if pr.ReadyToMerge {
+			ready = append(ready, pr.Number)
+		}
+	}
+	assert.Equal(t, []int{1, 2}, ready)
+	assert.Equal(t, []string{"bug"}, prs[0].Labels)
+	assert.Equal(t, reviewRequired, prs[3].ReviewDecision)
+	assert.Equal(t, "ghost", prs[3].Author)
+	assert.Contains(t, prs[0].Format(), "  Review: approved\n  Merge state: clean\n")
+}
+
+func TestGithubGetPullRequestUnresolvedThreads(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/graphql", r.URL.Path)
+		w.Write([]byte(`{"data": {"repository": {"pullRequest": {"reviewThreads": {"nodes": [
+			{"isResolved": true}, {"isResolved": false}, {"isResolved": false}
+		]}}}}}`))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	provider := &GithubProvider{client: client}
+
+	unresolved, err := provider.GetPullRequestUnresolvedThreads("o", "r", 17)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, unresolved)
+
+	pr := PullRequest{Title: "Add auth", Author: "jane", UnresolvedThreads: unresolved, threadsFetched: true}
+	assert.Contains(t, pr.Format(), "  Unresolved review threads: 2\n")
+	assert.NotContains(t, (&PullRequest{Title: "Add auth"}).Format(), "Unresolved", "unchecked PRs don't claim zero")
+}
+
+func TestHasUnresolvedNote(t *testing.T) {
+	assert.False(t, hasUnresolvedNote([]*gitlab.Note{{Body: "plain comment"}}))
+	assert.False(t, hasUnresolvedNote([]*gitlab.Note{{Resolvable: true, Resolved: true}}))
+	assert.True(t, hasUnresolvedNote([]*gitlab.Note{{Resolvable: true, Resolved: true}, {Resolvable: true}}))
+}
+
+func TestFormatEntity(t *testing.T) {
+	pr := &PullRequest{Number: 7, Title: "Add export", Author: "bob", Labels: []string{"feature", "ui"}}
+
+	assert.Equal(t, pr.Format(), formatEntity(pr, nil))
+
+	tmpl, err := parseEntityTemplate("XPLANE_PR_TEMPLATE", `#{{.Number}} {{.Title}} by {{upper .Author}} [{{join .Labels ", "}}]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "#7 Add export by BOB [feature, ui]", formatEntity(pr, tmpl))
+
+	release := &Release{TagName: "v1.2.0", PublishedAt: "2025-01-02"}
+	tmpl, err = parseEntityTemplate("XPLANE_RELEASE_TEMPLATE", "{{.Missing}}")
+	assert.NoError(t, err)
+	assert.Equal(t, release.Format(), formatEntity(release, tmpl), "a failing template falls back to Format()")
+
+	tmpl, err = parseEntityTemplate("XPLANE_PR_TEMPLATE", "")
+	assert.NoError(t, err)
+	assert.Nil(t, tmpl)
+	_, err = parseEntityTemplate("XPLANE_PR_TEMPLATE", "{{.Title")
+	assert.Error(t, err)
+}
+
+func TestGithubListReleases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/o/r/releases", r.URL.Path)
+		w.Write([]byte(`[
+			{"tag_name": "v1.3.0", "draft": true},
+			{"tag_name": "v1.2.1", "name": "v1.2.1", "published_at": "2025-08-01T12:00:00Z"},
+			{"tag_name": "v1.2.0", "name": "Autumn", "published_at": "2025-07-02T12:00:00Z"},
+			{"tag_name": "v1.1.0", "published_at": "2025-05-02T12:00:00Z"}
+		]`))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	provider := &GithubProvider{client: client}
+
+	releases, err := provider.ListReleases("o", "r", 2)
+	assert.NoError(t, err)
+	assert.Len(t, releases, 2, "drafts are skipped and the limit applies")
+	assert.Equal(t, "v1.2.1", releases[0].TagName)
+	assert.Equal(t, "Fri, Aug 1, 2025", releases[0].PublishedAt)
+
+	output := formatRecentReleases(releases, time.Date(2025, 8, 15, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, "The last 2 releases, 1 of them published in the last 30 days:\n- v1.2.1, Fri, Aug 1, 2025\n- v1.2.0 (Autumn), Wed, Jul 2, 2025\n", output)
+	assert.Equal(t, "No releases found.", formatRecentReleases(nil, time.Now()))
+}
@@ -1,16 +1,24 @@
-package main
+package xplane
 
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// the values XPLANE_PROVIDER accepts, see pickLLM
+var supportedProviders = []string{"claude_code", "gemini_cli", "gemini", "ollama"}
+
 func pickLLM(cfg *Config) (LLMProvider, error) {
 	switch cfg.Provider {
 	case "claude_code":
@@ -32,11 +40,26 @@ func pickLLM(cfg *Config) (LLMProvider, error) {
 		}
 		model := cfg.Model
 		if model == "" {
-			model = "gemma3n"
+			available, err := listOllamaModels(host, cfg.userAgent())
+			if err != nil {
+				return nil, err
+			}
+			if model, err = pickOllamaModel(available); err != nil {
+				return nil, err
+			}
+			fmt.Printf("xplane: Using '%s', pulled on the ollama server.\n", model)
+		}
+		endpoint := cfg.OllamaEndpoint
+		if endpoint == "" {
+			endpoint = defaultOllamaEndpoint
 		}
 		return &Ollama{
 			serverAddress: host,
 			model:         model,
+			endpoint:      endpoint,
+			options:       ollamaOptions(cfg.ModelParams, cfg.OllamaOptions),
+			keepAlive:     cfg.OllamaKeepAlive,
+			userAgent:     cfg.userAgent(),
 		}, nil
 	default:
 		return nil, fmt.Errorf("xplane: unknown llm provider '%s' found in config", cfg.Provider)
@@ -46,19 +69,85 @@ func pickLLM(cfg *Config) (LLMProvider, error) {
 type LLMProvider interface {
 	summarizeContext(finalPrompt string) (string, error)
 	getName() string
+	// the model actually used, which can differ from XPLANE_MODEL, e.g. when ollama picks a pulled one
+	getModel() string
+}
+
+// implemented by providers that can hand out the summary as it's generated,
+// onChunk gets each piece of text and can abort the generation by returning an error
+type streamingLLMProvider interface {
+	LLMProvider
+	streamContext(finalPrompt string, onChunk func(string) error) (string, error)
 }
 
-// getKnowledgeFilePath returns the path to the shared project knowledge file
-func getKnowledgeFilePath() (string, error) {
+// getKnowledgeFilePath returns the absolute path to the project knowledge file, creating its directory if needed
+func getKnowledgeFilePath(cfg *Config) (string, error) {
 	projRoot, err := findGitRoot()
 	if err != nil {
 		return "", err
 	}
-	xplaneDir := filepath.Join(projRoot, ".xplane")
-	if err := os.MkdirAll(xplaneDir, 0755); err != nil {
+	knowledgePath := filepath.Join(projRoot, cfg.knowledgeFilePath())
+	if err := os.MkdirAll(filepath.Dir(knowledgePath), 0755); err != nil {
 		return "", err
 	}
-	return filepath.Join(xplaneDir, "KNOWLEDGE.md"), nil
+	return knowledgePath, nil
+}
+
+// how many times a CLI provider is run when it exits fine but prints nothing
+const cliEmptyOutputAttempts = 2
+
+var errEmptyCLIOutput = errors.New("the CLI exited successfully but printed no summary")
+
+// noise the CLIs print to stdout before the actual answer
+var cliPreambleRegex = regexp.MustCompile(`(?i)^(loaded cached credentials|data collection is disabled|update available|a new version of|npm (notice|warn)|\(node:\d+\)|.*deprecationwarning)`)
+
+// stdout that is really a login prompt or an error message instead of a summary
+var cliErrorOutputRegex = regexp.MustCompile(`(?i)(please (login|log in|run /login)|invalid api key|authentication (required|failed)|not logged in|credit balance is too low|quota exceeded)`)
+
+// stderr of a CLI that exited because it isn't logged in
+var cliAuthErrorRegex = regexp.MustCompile(`(?i)(not (logged in|authenticated)|please (login|log in|run /login|authenticate)|authentication (required|failed)|unauthori[sz]ed|invalid api key|(token|credentials?) (has )?(expired|not found)|\b401\b)`)
+
+// turns a failed CLI run into an actionable error when it's about authentication, the generic one otherwise
+func cliRunError(name, loginHint string, args []string, err error, stderr string) error {
+	if cliAuthErrorRegex.MatchString(stderr) {
+		return fmt.Errorf("%s is not authenticated, %s first (stderr: %s)", name, loginHint, strings.TrimSpace(stderr))
+	}
+	return fmt.Errorf("%s failed with args %v: %v, stderr: %v", strings.ToLower(name), args, err, stderr)
+}
+
+// only short outputs are checked for error markers, a real summary may well mention logging in
+const maxCLIErrorOutputLines = 5
+
+// strips known preamble lines from a CLI provider's stdout and rejects output that isn't a summary
+func cleanCLIOutput(raw string) (string, error) {
+	lines := strings.Split(strings.TrimSpace(raw), "\n")
+	for len(lines) > 0 && (strings.TrimSpace(lines[0]) == "" || cliPreambleRegex.MatchString(strings.TrimSpace(lines[0]))) {
+		lines = lines[1:]
+	}
+	output := strings.TrimSpace(strings.Join(lines, "\n"))
+	if output == "" {
+		return "", errEmptyCLIOutput
+	}
+	if len(lines) <= maxCLIErrorOutputLines && cliErrorOutputRegex.MatchString(output) {
+		return "", fmt.Errorf("the CLI printed an error instead of a summary: %s", output)
+	}
+	return output, nil
+}
+
+// runs a CLI provider and validates its output, retrying when it comes back empty
+func summarizeWithCLI(run func() (string, error)) (string, error) {
+	for attempt := 0; attempt < cliEmptyOutputAttempts; attempt++ {
+		raw, err := run()
+		if err != nil {
+			return "", err
+		}
+		summary, err := cleanCLIOutput(raw)
+		if errors.Is(err, errEmptyCLIOutput) {
+			continue
+		}
+		return summary, err
+	}
+	return "", errEmptyCLIOutput
 }
 
 type ClaudeCode struct {
@@ -69,7 +158,15 @@ func (c *ClaudeCode) getName() string {
 	return "Claude Code"
 }
 
+func (c *ClaudeCode) getModel() string {
+	return c.model
+}
+
 func (c *ClaudeCode) summarizeContext(finalPrompt string) (string, error) {
+	return summarizeWithCLI(func() (string, error) { return c.run(finalPrompt) })
+}
+
+func (c *ClaudeCode) run(finalPrompt string) (string, error) {
 	args := []string{"--print", "--model", c.model}
 	cmd := exec.Command("claude", args...)
 
@@ -81,7 +178,7 @@ func (c *ClaudeCode) summarizeContext(finalPrompt string) (string, error) {
 
 	err := cmd.Run()
 	if err != nil {
-		return "", fmt.Errorf("claude code failed with args %v: %v, stderr: %v", args, err, stderr.String())
+		return "", cliRunError("Claude Code", "run 'claude' and log in with /login", args, err, stderr.String())
 	}
 	return out.String(), nil
 }
@@ -94,7 +191,15 @@ func (g *GeminiCli) getName() string {
 	return "Gemini CLI"
 }
 
+func (g *GeminiCli) getModel() string {
+	return g.model
+}
+
 func (g *GeminiCli) summarizeContext(finalPrompt string) (string, error) {
+	return summarizeWithCLI(func() (string, error) { return g.run(finalPrompt) })
+}
+
+func (g *GeminiCli) run(finalPrompt string) (string, error) {
 	args := []string{"-y", "-m", g.model} // see gemini --help
 	cmd := exec.Command("gemini", args...)
 
@@ -107,7 +212,7 @@ func (g *GeminiCli) summarizeContext(finalPrompt string) (string, error) {
 
 	err := cmd.Run()
 	if err != nil {
-		return "", fmt.Errorf("gemini cli failed with args %v: %v, stderr: %v", args, err, stderr.String())
+		return "", cliRunError("Gemini CLI", "run 'gemini' once to log in, or set GEMINI_API_KEY", args, err, stderr.String())
 	}
 	return out.String(), nil
 }
@@ -121,18 +226,76 @@ func (g *Gemini) getName() string {
 	return "Gemini"
 }
 
+func (g *Gemini) getModel() string {
+	return g.model
+}
+
 func (g *Gemini) summarizeContext(finalPrompt string) (string, error) {
 	return "Summary from Gemini (not the same as Gemini CLI!) not implemented yet", nil
 }
 
+// XPLANE_MODEL_PARAMS keys are snake_case like ollama's own options, these are the ones named differently there
+var ollamaParamNames = map[string]string{
+	"max_tokens":        "num_predict",
+	"max_output_tokens": "num_predict",
+	"stop_sequences":    "stop",
+}
+
+// maps XPLANE_MODEL_PARAMS onto ollama's 'options', XPLANE_OLLAMA_OPTIONS wins on conflicts as it's the more specific one
+func ollamaOptions(params, options map[string]any) map[string]any {
+	if len(params) == 0 {
+		return options
+	}
+	merged := make(map[string]any, len(params)+len(options))
+	for key, value := range params {
+		if name, ok := ollamaParamNames[key]; ok {
+			key = name
+		}
+		merged[key] = value
+	}
+	for key, value := range options {
+		merged[key] = value
+	}
+	return merged
+}
+
+const (
+	defaultOllamaEndpoint = "/api/generate"
+	ollamaChatEndpoint    = "/api/chat"
+	preferredOllamaModel  = "gemma3n" // picked when XPLANE_MODEL is unset and it's pulled on the server
+)
+
 type OllamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+	Model     string         `json:"model"`
+	Prompt    string         `json:"prompt"`
+	Stream    bool           `json:"stream"`
+	Options   map[string]any `json:"options,omitempty"`
+	KeepAlive any            `json:"keep_alive,omitempty"` // a duration like "30m" or seconds, -1 keeps the model loaded
 }
 
 type OllamaResponse struct {
 	Response string `json:"response"`
+	Done     bool   `json:"done"`            // when streaming, marks the last chunk
+	Error    string `json:"error,omitempty"` // set instead of Response when generation fails, even with a 200
+}
+
+type OllamaChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type OllamaChatRequest struct {
+	Model     string              `json:"model"`
+	Messages  []OllamaChatMessage `json:"messages"`
+	Stream    bool                `json:"stream"`
+	Options   map[string]any      `json:"options,omitempty"`
+	KeepAlive any                 `json:"keep_alive,omitempty"`
+}
+
+type OllamaChatResponse struct {
+	Message OllamaChatMessage `json:"message"`
+	Done    bool              `json:"done"`
+	Error   string            `json:"error,omitempty"`
 }
 
 type OllamaModelInfo struct {
@@ -146,79 +309,204 @@ type OllamaTagsResponse struct {
 type Ollama struct {
 	serverAddress string
 	model         string
+	endpoint      string         // path of the generation api, either '/api/generate' or the chat style '/api/chat'
+	options       map[string]any // passed as is to the 'options' field, e.g. num_ctx or temperature
+	keepAlive     any            // how long ollama keeps the model loaded after the request, nil leaves it to the server
+	userAgent     string         // empty keeps go's default
+}
+
+// ollama takes keep_alive either as a duration string or as a number of seconds, -1 meaning forever
+func parseOllamaKeepAlive(raw string) (any, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	if seconds, err := strconv.Atoi(raw); err == nil {
+		return seconds, nil
+	}
+	if _, err := time.ParseDuration(raw); err != nil {
+		return nil, fmt.Errorf("keep alive must be a duration like '30m' or a number of seconds like '-1': %w", err)
+	}
+	return raw, nil
+}
+
+func (o *Ollama) usesChatAPI() bool {
+	return strings.TrimSuffix(o.endpoint, "/") == ollamaChatEndpoint
+}
+
+// builds the request body matching the configured endpoint's format
+func (o *Ollama) buildPayload(finalPrompt string, stream bool) ([]byte, error) {
+	if o.usesChatAPI() {
+		return json.Marshal(OllamaChatRequest{
+			Model:     o.model,
+			Messages:  []OllamaChatMessage{{Role: "user", Content: finalPrompt}},
+			Stream:    stream,
+			Options:   o.options,
+			KeepAlive: o.keepAlive,
+		})
+	}
+
+	return json.Marshal(OllamaRequest{
+		Model:     o.model,
+		Prompt:    finalPrompt,
+		Stream:    stream,
+		Options:   o.options,
+		KeepAlive: o.keepAlive,
+	})
+}
+
+// extracts the generated text from the response body matching the configured endpoint's format
+func (o *Ollama) decodeResponse(body io.Reader) (string, error) {
+	text, _, err := o.decodeChunk(json.NewDecoder(body))
+	return text, err
+}
+
+// decodes one response object, a whole response or a single chunk of a streamed one
+func (o *Ollama) decodeChunk(decoder *json.Decoder) (text string, done bool, err error) {
+	if o.usesChatAPI() {
+		var chatResponse OllamaChatResponse
+		if err := decoder.Decode(&chatResponse); err != nil {
+			return "", false, fmt.Errorf("failed to decode ollama response: %w", err)
+		}
+		if chatResponse.Error != "" {
+			return "", false, fmt.Errorf("ollama model '%s' returned an error: %s", o.model, chatResponse.Error)
+		}
+		return chatResponse.Message.Content, chatResponse.Done, nil
+	}
+
+	var ollamaResponse OllamaResponse
+	if err := decoder.Decode(&ollamaResponse); err != nil {
+		return "", false, fmt.Errorf("failed to decode ollama response: %w", err)
+	}
+	if ollamaResponse.Error != "" {
+		return "", false, fmt.Errorf("ollama model '%s' returned an error: %s", o.model, ollamaResponse.Error)
+	}
+	return ollamaResponse.Response, ollamaResponse.Done, nil
 }
 
 func (o *Ollama) getName() string {
 	return "Ollama"
 }
 
-func (o *Ollama) checkModelAvailability() (bool, error) {
-	apiEndpoint := o.serverAddress + "/api/tags"
-	resp, err := http.Get(apiEndpoint)
+func (o *Ollama) getModel() string {
+	return o.model
+}
+
+// lists the names of the models pulled on the ollama server
+func listOllamaModels(serverAddress, userAgent string) ([]string, error) {
+	apiEndpoint := serverAddress + "/api/tags"
+	resp, err := newHTTPClient(userAgent).Get(apiEndpoint)
 	if err != nil {
-		return false, fmt.Errorf("could not connect to ollama server at '%s': %w. Is the server running?", o.serverAddress, err)
+		return nil, fmt.Errorf("could not connect to ollama server at '%s': %w. Is the server running?", serverAddress, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("ollama server returned non-200 status: %s", resp.Status)
+		return nil, fmt.Errorf("ollama server returned non-200 status: %s", resp.Status)
 	}
 
 	var tagsResponse OllamaTagsResponse
 	decodingErr := json.NewDecoder(resp.Body).Decode(&tagsResponse)
 	if decodingErr != nil {
-		return false, fmt.Errorf("failed to decode ollama tags response: %w", decodingErr)
+		return nil, fmt.Errorf("failed to decode ollama tags response: %w", decodingErr)
 	}
 
+	var models []string
 	for _, model := range tagsResponse.Models {
-		if strings.HasPrefix(model.Name, o.model) {
-			return true, nil
+		models = append(models, model.Name)
+	}
+	return models, nil
+}
+
+// the model used when none is configured: the preferred one if it's pulled, otherwise whatever the server has first
+func pickOllamaModel(available []string) (string, error) {
+	if len(available) == 0 {
+		return "", fmt.Errorf("no models pulled on the ollama server, pull one with e.g. 'ollama pull %s' or set XPLANE_MODEL", preferredOllamaModel)
+	}
+	for _, model := range available {
+		if strings.HasPrefix(model, preferredOllamaModel) {
+			return model, nil
+		}
+	}
+	return available[0], nil
+}
+
+func (o *Ollama) checkModelAvailability() error {
+	available, err := listOllamaModels(o.serverAddress, o.userAgent)
+	if err != nil {
+		return err
+	}
+
+	for _, model := range available {
+		if strings.HasPrefix(model, o.model) {
+			return nil
 		}
 	}
 
-	return false, nil
+	availableMsg := "none"
+	if len(available) > 0 {
+		availableMsg = strings.Join(available, ", ")
+	}
+	return fmt.Errorf("ollama model '%s' not found. Please pull it by running 'ollama pull %s' on the host server, or set XPLANE_MODEL to one of the available models: %s", o.model, o.model, availableMsg)
 }
 
 func (o *Ollama) summarizeContext(finalPrompt string) (string, error) {
-	// before even attempting to prompt the model, let's check it's been pulled
-	modelIsPulled, err := o.checkModelAvailability()
+	resp, err := o.generate(finalPrompt, false)
 	if err != nil {
 		return "", err
 	}
+	defer resp.Body.Close()
+
+	return o.decodeResponse(resp.Body)
+}
 
-	if !modelIsPulled {
-		return "", fmt.Errorf("ollama model '%s' not found. Please pull it by running 'ollama pull %s' on the host server.", o.model, o.model)
+// ollama streams newline delimited json objects, each holding the next piece of text
+func (o *Ollama) streamContext(finalPrompt string, onChunk func(string) error) (string, error) {
+	resp, err := o.generate(finalPrompt, true)
+	if err != nil {
+		return "", err
 	}
-	requestPayload := OllamaRequest{
-		Model:  o.model,
-		Prompt: finalPrompt,
-		Stream: false,
+	defer resp.Body.Close()
+
+	var summary strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		text, done, err := o.decodeChunk(decoder)
+		if err != nil {
+			return summary.String(), err
+		}
+		summary.WriteString(text)
+		if text != "" {
+			if err := onChunk(text); err != nil {
+				return summary.String(), err
+			}
+		}
+		if done {
+			return summary.String(), nil
+		}
 	}
+}
 
-	payloadBytes, err := json.Marshal(requestPayload)
+// checks the model is there and sends the generation request, the caller closes the response body
+func (o *Ollama) generate(finalPrompt string, stream bool) (*http.Response, error) {
+	// before even attempting to prompt the model, let's check it's been pulled
+	if err := o.checkModelAvailability(); err != nil {
+		return nil, err
+	}
+	payloadBytes, err := o.buildPayload(finalPrompt, stream)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal ollama request: %w", err)
+		return nil, fmt.Errorf("failed to marshal ollama request: %w", err)
 	}
 
-	apiEndpoint := o.serverAddress + "/api/generate"
+	apiEndpoint := o.serverAddress + o.endpoint
 	req, err := http.NewRequest("POST", apiEndpoint, bytes.NewBuffer(payloadBytes))
 	if err != nil {
-		return "", fmt.Errorf("failed to create ollama request: %w", err)
+		return nil, fmt.Errorf("failed to create ollama request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := newHTTPClient(o.userAgent).Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request to ollama server '%s': %w", o.serverAddress, err)
+		return nil, fmt.Errorf("failed to send request to ollama server '%s': %w", o.serverAddress, err)
 	}
-	defer resp.Body.Close()
-
-	var ollamaResponse OllamaResponse
-	decodingErr := json.NewDecoder(resp.Body).Decode(&ollamaResponse)
-	if decodingErr != nil {
-		return "", fmt.Errorf("failed to decode ollama response: %w", decodingErr)
-	}
-
-	return ollamaResponse.Response, nil
+	return resp, nil
 }
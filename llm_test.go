@@ -0,0 +1,294 @@
+package xplane
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakes an ollama server that has the given model pulled and answers on both generation endpoints
+func newFakeOllamaServer(t *testing.T, model string, received *map[string]any) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			json.NewEncoder(w).Encode(OllamaTagsResponse{Models: []OllamaModelInfo{{Name: model}}})
+		case "/api/generate":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(received))
+			json.NewEncoder(w).Encode(OllamaResponse{Response: "generated summary"})
+		case "/api/chat":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(received))
+			json.NewEncoder(w).Encode(OllamaChatResponse{Message: OllamaChatMessage{Role: "assistant", Content: "chat summary"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestOllamaSummarizeContext(t *testing.T) {
+	tests := []struct {
+		name            string
+		endpoint        string
+		options         map[string]any
+		expectedSummary string
+		expectedFields  []string
+	}{
+		{"generate endpoint", "/api/generate", nil, "generated summary", []string{"prompt"}},
+		{"chat endpoint", "/api/chat", nil, "chat summary", []string{"messages"}},
+		{"options are forwarded", "/api/generate", map[string]any{"num_ctx": 8192.0}, "generated summary", []string{"prompt", "options"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received map[string]any
+			server := newFakeOllamaServer(t, "llama3", &received)
+			defer server.Close()
+
+			ollama := &Ollama{serverAddress: server.URL, model: "llama3", endpoint: tt.endpoint, options: tt.options}
+			summary, err := ollama.summarizeContext("what changed?")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedSummary, summary)
+			for _, field := range tt.expectedFields {
+				assert.Contains(t, received, field)
+			}
+			if tt.options != nil {
+				assert.Equal(t, tt.options, received["options"])
+			} else {
+				assert.NotContains(t, received, "options")
+			}
+		})
+	}
+}
+
+func TestOllamaSummarizeContextErrorResponse(t *testing.T) {
+	for _, endpoint := range []string{"/api/generate", "/api/chat"} {
+		t.Run(endpoint, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/api/tags" {
+					json.NewEncoder(w).Encode(OllamaTagsResponse{Models: []OllamaModelInfo{{Name: "llama3"}}})
+					return
+				}
+				// ollama reports mid-generation failures with a 200 and an error body
+				w.Write([]byte(`{"error":"model runner has unexpectedly stopped"}`))
+			}))
+			defer server.Close()
+
+			ollama := &Ollama{serverAddress: server.URL, model: "llama3", endpoint: endpoint}
+			summary, err := ollama.summarizeContext("what changed?")
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "model runner has unexpectedly stopped")
+			assert.Empty(t, summary)
+		})
+	}
+}
+
+func TestCleanCLIOutput(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         string
+		expected    string
+		expectedErr string
+	}{
+		{"plain summary", "## Changes\n- fixed a bug\n", "## Changes\n- fixed a bug", ""},
+		{"preamble is stripped", "Loaded cached credentials.\nData collection is disabled.\n\n## Changes\n", "## Changes", ""},
+		{"update notice is stripped", "Update available! 1.0.1 -> 1.1.0\n## Changes", "## Changes", ""},
+		{"empty output", "\n  \n", "", "printed no summary"},
+		{"only preamble", "Loaded cached credentials.\n", "", "printed no summary"},
+		{"login prompt", "Please login to continue.", "", "printed an error instead of a summary"},
+		{"invalid api key", "Invalid API key · Please run /login", "", "printed an error instead of a summary"},
+		{"long summary mentioning login", "## Changes\n- a\n- b\n- c\n- d\n- e\n- users now see 'Please login' on expiry", "## Changes\n- a\n- b\n- c\n- d\n- e\n- users now see 'Please login' on expiry", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := cleanCLIOutput(tt.raw)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, output)
+		})
+	}
+}
+
+func TestSummarizeWithCLIRetriesEmptyOutput(t *testing.T) {
+	outputs := []string{"", "## Changes"}
+	calls := 0
+	summary, err := summarizeWithCLI(func() (string, error) {
+		calls++
+		return outputs[calls-1], nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "## Changes", summary)
+	assert.Equal(t, 2, calls)
+
+	calls = 0
+	_, err = summarizeWithCLI(func() (string, error) {
+		calls++
+		return "", nil
+	})
+	assert.ErrorIs(t, err, errEmptyCLIOutput)
+	assert.Equal(t, cliEmptyOutputAttempts, calls)
+}
+
+func TestParseOllamaKeepAlive(t *testing.T) {
+	tests := []struct {
+		raw         string
+		expected    any
+		expectError bool
+	}{
+		{"", nil, false},
+		{"30m", "30m", false},
+		{"-1", -1, false},
+		{"600", 600, false},
+		{"forever", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			keepAlive, err := parseOllamaKeepAlive(tt.raw)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, keepAlive)
+		})
+	}
+}
+
+func TestOllamaKeepAliveIsSent(t *testing.T) {
+	var received map[string]any
+	server := newFakeOllamaServer(t, "llama3", &received)
+	defer server.Close()
+
+	ollama := &Ollama{serverAddress: server.URL, model: "llama3", endpoint: defaultOllamaEndpoint, keepAlive: -1}
+	_, err := ollama.summarizeContext("what changed?")
+	assert.NoError(t, err)
+	assert.Equal(t, -1.0, received["keep_alive"])
+
+	ollama.keepAlive = nil
+	received = nil
+	_, err = ollama.summarizeContext("what changed?")
+	assert.NoError(t, err)
+	assert.NotContains(t, received, "keep_alive")
+}
+
+func TestOllamaStreamContext(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		chunks   []string
+	}{
+		{"/api/generate", []string{`{"response":"# Sum","done":false}`, `{"response":"mary\n","done":false}`, `{"response":"","done":true}`}},
+		{"/api/chat", []string{`{"message":{"role":"assistant","content":"# Sum"},"done":false}`, `{"message":{"role":"assistant","content":"mary\n"},"done":true}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			var stream bool
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/api/tags" {
+					json.NewEncoder(w).Encode(OllamaTagsResponse{Models: []OllamaModelInfo{{Name: "llama3"}}})
+					return
+				}
+				var request map[string]any
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+				stream, _ = request["stream"].(bool)
+				for _, chunk := range tt.chunks {
+					w.Write([]byte(chunk + "\n"))
+				}
+			}))
+			defer server.Close()
+
+			ollama := &Ollama{serverAddress: server.URL, model: "llama3", endpoint: tt.endpoint}
+			var received []string
+			summary, err := ollama.streamContext("what changed?", func(chunk string) error {
+				received = append(received, chunk)
+				return nil
+			})
+
+			assert.NoError(t, err)
+			assert.True(t, stream)
+			assert.Equal(t, "# Summary\n", summary)
+			assert.Equal(t, []string{"# Sum", "mary\n"}, received)
+		})
+	}
+}
+
+func TestPickOllamaModel(t *testing.T) {
+	tests := []struct {
+		name      string
+		available []string
+		expected  string
+		expectErr bool
+	}{
+		{"preferred model pulled", []string{"llama3:latest", "gemma3n:e4b"}, "gemma3n:e4b", false},
+		{"first available otherwise", []string{"llama3:latest", "qwen3:8b"}, "llama3:latest", false},
+		{"nothing pulled", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := pickOllamaModel(tt.available)
+			assert.Equal(t, tt.expected, model)
+			assert.Equal(t, tt.expectErr, err != nil)
+		})
+	}
+}
+
+func TestPickLLMOllamaDefaultsToServerModel(t *testing.T) {
+	var received map[string]any
+	server := newFakeOllamaServer(t, "llama3:latest", &received)
+	defer server.Close()
+	cfg := &Config{Provider: "ollama", OllamaServerAddress: server.URL}
+
+	llm, err := pickLLM(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "llama3:latest", llm.getModel())
+	assert.Empty(t, cfg.Model, "the config is left alone")
+
+	missing := &Ollama{serverAddress: server.URL, model: "gemma3n", endpoint: defaultOllamaEndpoint}
+	_, err = missing.summarizeContext("what changed?")
+	assert.ErrorContains(t, err, "available models: llama3:latest")
+}
+
+func TestCLIRunError(t *testing.T) {
+	tests := []struct {
+		name     string
+		stderr   string
+		expected string
+	}{
+		{"not logged in", "Error: Not logged in. Please run /login\n", "Gemini CLI is not authenticated, run 'gemini' once to log in first (stderr: Error: Not logged in. Please run /login)"},
+		{"expired token", "OAuth token has expired", "Gemini CLI is not authenticated, run 'gemini' once to log in first (stderr: OAuth token has expired)"},
+		{"http 401", "request failed with status 401", "Gemini CLI is not authenticated, run 'gemini' once to log in first (stderr: request failed with status 401)"},
+		{"other failures stay generic", "model not found", "gemini cli failed with args [-m x]: exit status 1, stderr: model not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cliRunError("Gemini CLI", "run 'gemini' once to log in", []string{"-m", "x"}, errors.New("exit status 1"), tt.stderr)
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+func TestModelParamsMapping(t *testing.T) {
+	params := map[string]any{"temperature": 0.2, "top_p": 0.9, "presence_penalty": 0.5, "max_tokens": 512.0}
+
+	assert.Equal(t, map[string]any{
+		"temperature": 0.2, "top_p": 0.9, "presence_penalty": 0.5, "num_predict": 512.0, "num_ctx": 8192.0,
+	}, ollamaOptions(params, map[string]any{"num_ctx": 8192.0}))
+	assert.Equal(t, 0.7, ollamaOptions(params, map[string]any{"temperature": 0.7})["temperature"], "XPLANE_OLLAMA_OPTIONS wins")
+	assert.Nil(t, ollamaOptions(nil, nil))
+
+	llm, err := pickLLM(&Config{Provider: "claude_code", Model: "claude-sonnet-4", ModelParams: params})
+	assert.NoError(t, err, "CLI providers ignore the params")
+	assert.IsType(t, &ClaudeCode{}, llm)
+}
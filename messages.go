@@ -1,20 +1,61 @@
-package main
+package xplane
 
 import "fmt"
 
 const (
 	MsgFetchingContext          = "✈️  xplane: Gathering project context..."
 	MsgGenericCommand           = "    - \ue795     Running generic command '%s' ...\n"
+	MsgIncludingFile            = "    - \uf15c     Including file '%s'...\n"
 	MsgGetCodeStats             = "    - \ueb03     Analyzing code stats..."
+	MsgGetCoverage              = "    - \uf0e4     Reading test coverage..."
+	MsgGetBuildSize             = "    - \uf0ad     Building to measure the artifact size..."
 	MsgGetLeakedSecrets         = "    - \uf43d     Detecting potentially leaked secrets..."
+	MsgPrefetchingRemote        = "    - \ue65d     Fetching remote state (git fetch --prune)..."
 	MsgCheckingGitStatus        = "    - \ue65d     Checking local git status..."
 	MsgFetchingGitLog           = "    - \ue65d     Fetching recent git log..."
+	MsgFetchingGitLogPatches    = "    - \ue65d     Fetching patches of recent commits..."
+	MsgFetchingDiffSinceRelease = "    - \ue65d     Fetching changes since the latest release..."
+	MsgFetchingBranchDiff       = "    - \ue65d     Fetching changes since the branch point..."
 	MsgFetchingGitDiff          = "    - \ue65d     Fetching uncommitted diff..."
+	MsgFetchingAPISpecDiff      = "    - \ue65d     Fetching API spec diff..."
+	MsgSummarizingDiffPerFile   = "          (diff over budget, summarizing %d files one by one)\n"
+	MsgFetchingDiffSummary      = "    - \ue65d     Summarizing uncommitted changes per file..."
+	MsgFetchingDocsDiff         = "    - \ue65d     Fetching documentation diff..."
+	MsgFetchingTestDiff         = "    - \ue65d     Fetching test files diff..."
+	MsgFetchingContainerDiff    = "    - \ue65d     Fetching container config diff..."
+	MsgFetchingCIConfigDiff     = "    - \ue65d     Fetching CI and build config diff..."
+	MsgFetchingRecentBlame      = "    - \ue65d     Blaming recently changed files..."
+	MsgFetchingSubmodules       = "    - \ue65d     Checking submodules..."
+	MsgFetchingLicense          = "    - \uf0e3     Checking the project license..."
+	MsgFetchingConflictMarkers  = "    - \ue65d     Looking for unresolved conflict markers..."
+	MsgFetchingReverts          = "    - \ue65d     Looking for recent reverts..."
+	MsgFetchingPathHistory      = "    - \ue65d     Fetching the history of the configured path..."
+	MsgFetchingRerereStatus     = "    - \ue65d     Checking recorded conflict resolutions..."
 	MsgFetchingGithubRemoteInfo = "    - \uF09B     Fetching info from GitHub: %s"
 	MsgFetchingGitlabRemoteInfo = "    - \ue65c     Fetching info from GitLab: %s"
+	MsgUsingCachedOutput        = "          (using cached result, run with --refresh to fetch again)"
 	MsgAnalyzingContext         = "\uee0d  xplane: Context has changed, analyzing with %s provider using '%s'...\n\n\n"
-	MsgKnowledgeInitialized     = "\ue28c Initialized project knowledge file at .xplane/KNOWLEDGE.md"
+	MsgCondensingContext        = "\uee0d  xplane: Condensing context with %s provider using '%s'...\n"
+	MsgComparingSnapshots       = "\uee0d  xplane: Comparing snapshot '%s' to '%s' with %s provider using '%s'...\n\n\n"
+	MsgFetchingPullRequest      = "\uee0d  xplane: Fetching pull/merge request #%d...\n"
+	MsgSummarizingPullRequest   = "\uee0d  xplane: Summarizing pull/merge request #%d with %s provider using '%s'...\n\n\n"
+	MsgAuditingCommands         = "\uee0d  xplane: Running each configured command, nothing is sent to the llm...\n\n"
+	MsgSnapshotSaved            = "\uf0c7  xplane: Saved snapshot '%s'.\n"
+	MsgWaitingForLLM            = "\r\033[K%s xplane: Waiting for %s... (%ds)"
+	MsgCommentPosted            = "\uf27a  xplane: Posted summary as a comment on %s\n"
+	MsgNoDiffToShow             = "\ue65d  xplane: No uncommitted changes to show."
+	MsgSummaryCopied            = "\uf0ea  xplane: Copied the summary to the clipboard."
+	MsgNoPRToComment            = "\uf27a  xplane: No open pull/merge request found for the current branch, skipping comment."
+	MsgUpdateAvailable          = "\uf01b  xplane: %s is available (you have %s), see https://github.com/Gdetrane/xplane/releases"
+	MsgKnowledgeInitialized     = "\ue28c Initialized project knowledge file at %s\n"
 	MsgKnowledgeUpdated         = "\ue28c  Project knowledge updated."
+	MsgNoNewUpdates             = "✅ xplane: No new updates."
+	MsgSummaryFailed            = "⚠️ xplane: Could not generate summary: %s\n"
+	MsgContextNotAdvanced       = "⚠️ xplane: Context not updated, the changes will be summarized on the next run."
+	MsgSkippedForTimeBudget     = "⚠️ xplane: Skipped %d commands due to the time budget (XPLANE_MAX_RUNTIME): %s\n"
+	MsgSkippingCommand          = "    - ⚠️  Skipping command '%s': could not initialize git provider (%s)\n"
+	MsgKnowledgeCommitted       = "\ue28c  Project knowledge committed."
+	MsgKnowledgeDisabledHint    = "\ue28c  The summary has a KNOWLEDGE UPDATE section that wasn't saved, set USE_PROJECT_KNOWLEDGE=true to keep it in %s\n"
 )
 
 func buildRemoteInfoMsg(providerName string, commandName string) string {
@@ -23,22 +64,61 @@ func buildRemoteInfoMsg(providerName string, commandName string) string {
 		if commandName == "release" {
 			return fmt.Sprintf(MsgFetchingGithubRemoteInfo, "Getting latest release...")
 		}
+		if commandName == "recent_releases" {
+			return fmt.Sprintf(MsgFetchingGithubRemoteInfo, "Getting recent releases...")
+		}
 		if commandName == "github_prs" {
 			return fmt.Sprintf(MsgFetchingGithubRemoteInfo, "Getting open PRs...")
 		}
 		if commandName == "git_branch_status" {
 			return fmt.Sprintf(MsgFetchingGithubRemoteInfo, "Comparing current branch to upstream...")
 		}
+		if commandName == "shipped_issues" {
+			return fmt.Sprintf(MsgFetchingGithubRemoteInfo, "Getting issues referenced by recent commits...")
+		}
+		if commandName == "merge_status" {
+			return fmt.Sprintf(MsgFetchingGithubRemoteInfo, "Checking merge requirements of the current branch...")
+		}
+		if commandName == "pr_overlap" {
+			return fmt.Sprintf(MsgFetchingGithubRemoteInfo, "Checking open PRs for files overlapping with the current branch...")
+		}
+		if commandName == "stale_branches" {
+			return fmt.Sprintf(MsgFetchingGithubRemoteInfo, "Looking for stale branches...")
+		}
+		if commandName == "mergeable_prs" {
+			return fmt.Sprintf(MsgFetchingGithubRemoteInfo, "Getting PRs ready to merge...")
+		}
+		if commandName == "github_discussions" {
+			return fmt.Sprintf(MsgFetchingGithubRemoteInfo, "Getting recent discussions...")
+		}
 	case "gitlab":
 		if commandName == "release" {
 			return fmt.Sprintf(MsgFetchingGitlabRemoteInfo, "Getting latest release...")
 		}
+		if commandName == "recent_releases" {
+			return fmt.Sprintf(MsgFetchingGitlabRemoteInfo, "Getting recent releases...")
+		}
 		if commandName == "gitlab_mrs" {
 			return fmt.Sprintf(MsgFetchingGitlabRemoteInfo, "Getting open MRs...")
 		}
 		if commandName == "git_branch_status" {
 			return fmt.Sprintf(MsgFetchingGitlabRemoteInfo, "Comparing current branch to upstream...")
 		}
+		if commandName == "shipped_issues" {
+			return fmt.Sprintf(MsgFetchingGitlabRemoteInfo, "Getting issues referenced by recent commits...")
+		}
+		if commandName == "merge_status" {
+			return fmt.Sprintf(MsgFetchingGitlabRemoteInfo, "Checking merge requirements of the current branch...")
+		}
+		if commandName == "pr_overlap" {
+			return fmt.Sprintf(MsgFetchingGitlabRemoteInfo, "Checking open MRs for files overlapping with the current branch...")
+		}
+		if commandName == "stale_branches" {
+			return fmt.Sprintf(MsgFetchingGitlabRemoteInfo, "Looking for stale branches...")
+		}
+		if commandName == "mergeable_prs" {
+			return fmt.Sprintf(MsgFetchingGitlabRemoteInfo, "Getting MRs ready to merge...")
+		}
 	default:
 		return fmt.Sprintf("Unexpected command: %s", commandName)
 	}
@@ -1,4 +1,4 @@
-package main
+package xplane
 
 import (
 	"testing"
@@ -18,7 +18,14 @@ func TestBuildRemoteInfoMsg(t *testing.T) {
 		{"github branch status", "github", "git_branch_status", "    - \uF09B     Fetching info from GitHub: Comparing current branch to upstream..."},
 		{"gitlab release", "gitlab", "release", "    - \ue65c     Fetching info from GitLab: Getting latest release..."},
 		{"gitlab mrs", "gitlab", "gitlab_mrs", "    - \ue65c     Fetching info from GitLab: Getting open MRs..."},
+		{"github shipped issues", "github", "shipped_issues", "    - \uF09B     Fetching info from GitHub: Getting issues referenced by recent commits..."},
+		{"gitlab shipped issues", "gitlab", "shipped_issues", "    - \ue65c     Fetching info from GitLab: Getting issues referenced by recent commits..."},
+		{"github merge status", "github", "merge_status", "    - \uF09B     Fetching info from GitHub: Checking merge requirements of the current branch..."},
+		{"gitlab merge status", "gitlab", "merge_status", "    - \ue65c     Fetching info from GitLab: Checking merge requirements of the current branch..."},
+		{"github discussions", "github", "github_discussions", "    - \uF09B     Fetching info from GitHub: Getting recent discussions..."},
 		{"gitlab branch status", "gitlab", "git_branch_status", "    - \ue65c     Fetching info from GitLab: Comparing current branch to upstream..."},
+		{"github recent releases", "github", "recent_releases", "    - \uF09B     Fetching info from GitHub: Getting recent releases..."},
+		{"gitlab recent releases", "gitlab", "recent_releases", "    - \ue65c     Fetching info from GitLab: Getting recent releases..."},
 		{"unknown provider", "unknown", "release", "Unexpected command: release"},
 		{"unknown command", "github", "unknown", "Unexpected git provider: github"},
 	}
@@ -0,0 +1,149 @@
+package xplane
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDiffBudget = 20000 // characters of git_diff output before per-file summaries kick in
+	// a single file's diff is cut to this before being summarized, the gist is usually in the first hunks
+	maxFileDiffLength = 12000
+	// one llm call per file, so a big refactor or a vendored dependency bump only gets its largest diffs
+	// summarized and the rest listed with their line counts
+	maxSummarizedFiles = 20
+	// summaries are cached by the hash of the file's diff, so an unchanged diff keeps the same context between runs
+	perFileSummaryCacheTTL    = 30 * 24 * time.Hour
+	perFileSummaryCachePrefix = "diff_summary_"
+)
+
+const perFileSummaryPrompt = `Summarize the following uncommitted diff of '%s' in one short line, focusing on what changed in behavior rather than on the lines themselves. Answer with that line only.
+
+%s`
+
+type fileDiff struct {
+	path string
+	diff string
+}
+
+// splits a unified diff into one chunk per file, keyed by the file's new path
+func splitDiffByFile(diff string) []fileDiff {
+	var files []fileDiff
+	for _, chunk := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(chunk, "diff --git ") {
+			path := strings.TrimSpace(chunk)
+			if _, newPath, found := strings.Cut(path, " b/"); found {
+				path = newPath
+			}
+			files = append(files, fileDiff{path: path})
+		}
+		if len(files) > 0 {
+			files[len(files)-1].diff += chunk
+		}
+	}
+	return files
+}
+
+// the indexes of the limit files with the largest diffs
+func largestFileDiffs(files []fileDiff, limit int) map[int]bool {
+	indexes := make([]int, len(files))
+	for i := range files {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool { return len(files[indexes[i]].diff) > len(files[indexes[j]].diff) })
+	largest := make(map[int]bool, limit)
+	for _, index := range indexes[:min(limit, len(indexes))] {
+		largest[index] = true
+	}
+	return largest
+}
+
+// the added and removed lines of the file's hunks, like `git diff --numstat` counts them
+func (f fileDiff) lineCounts() (additions, deletions int) {
+	inHunk := false
+	for _, line := range strings.Split(f.diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case !inHunk:
+		case strings.HasPrefix(line, "+"):
+			additions++
+		case strings.HasPrefix(line, "-"):
+			deletions++
+		}
+	}
+	return additions, deletions
+}
+
+// git_diff, with each file's changes replaced by a one-line LLM summary when the whole diff exceeds the budget.
+// without summarize the diff is returned whole, for dry runs that mustn't call the llm
+func getBudgetedGitDiff(cfg *Config, gitRoot string, summarize bool) (string, error) {
+	diff, err := runCommand(gitRoot, "git", excludingXplaneFiles(cfg.ownFilesOutsideContextDir(), "diff")...)
+	if err != nil {
+		return "", err
+	}
+	if !summarize || !cfg.PerFileDiffSummary || len(diff) <= cfg.diffBudget() {
+		return formatGitDiff(diff), nil
+	}
+
+	// the condense model is the cheap one, when there is one
+	var summarizer LLMProvider
+	if cfg.CondenseModel != "" {
+		summarizer, err = pickCondenseLLM(cfg)
+	} else {
+		summarizer, err = pickLLM(cfg)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not load a model for per-file diff summaries: %w", err)
+	}
+
+	files := splitDiffByFile(diff)
+	summarized := largestFileDiffs(files, maxSummarizedFiles)
+	fmt.Printf(cfg.msg(MsgSummarizingDiffPerFile), len(summarized))
+	var builder, rest strings.Builder
+	builder.WriteString(fmt.Sprintf("The full diff was too large (%d characters), each file's changes are summarized instead:\n", len(diff)))
+	for i, file := range files {
+		if !summarized[i] {
+			additions, deletions := file.lineCounts()
+			rest.WriteString(fmt.Sprintf("%s: +%d/-%d\n", file.path, additions, deletions))
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("- %s: %s\n", file.path, summarizeFileDiff(summarizer, gitRoot, file)))
+	}
+	if rest.Len() > 0 {
+		builder.WriteString(fmt.Sprintf("The other %d files, with smaller diffs, weren't summarized:\n%s", len(files)-len(summarized), rest.String()))
+	}
+	// every edit to a file makes a new entry, the expired ones would pile up otherwise
+	pruneCachedOutputs(gitRoot, perFileSummaryCachePrefix, perFileSummaryCacheTTL)
+	return formatGitDiff(builder.String()), nil
+}
+
+func summarizeFileDiff(summarizer LLMProvider, gitRoot string, file fileDiff) string {
+	hash := sha256.Sum256([]byte(file.diff))
+	cacheKey := perFileSummaryCachePrefix + hex.EncodeToString(hash[:8])
+	if summary, ok := readCachedOutput(gitRoot, cacheKey, perFileSummaryCacheTTL); ok {
+		return summary
+	}
+
+	diff := file.diff
+	if len(diff) > maxFileDiffLength {
+		diff = truncateAtRune(diff, maxFileDiffLength) + "\n[diff truncated]"
+	}
+	summary, err := summarizer.summarizeContext(fmt.Sprintf(perFileSummaryPrompt, file.path, diff))
+	if err != nil {
+		// not caching failures, the next run gets another go at it
+		log.Printf("Warning: Could not summarize the diff of '%s': %s", file.path, redactError(err))
+		return fmt.Sprintf("changed, %d lines of diff (summary unavailable)", strings.Count(file.diff, "\n"))
+	}
+
+	summary = strings.Join(strings.Fields(summary), " ")
+	if err := writeCachedOutput(gitRoot, cacheKey, summary); err != nil {
+		log.Printf("Warning: Could not cache the diff summary of '%s': %s", file.path, redactError(err))
+	}
+	return summary
+}
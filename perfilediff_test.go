@@ -0,0 +1,73 @@
+package xplane
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitDiffByFile(t *testing.T) {
+	diff := "diff --git a/main.go b/main.go\nindex 1..2 100644\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package lib\n" +
+		"diff --git a/old.txt b/new.txt\nsimilarity index 100%\nrename from old.txt\nrename to new.txt\n"
+
+	files := splitDiffByFile(diff)
+
+	assert.Len(t, files, 2)
+	assert.Equal(t, "main.go", files[0].path)
+	assert.True(t, strings.HasSuffix(files[0].diff, "+package lib\n"))
+	assert.Equal(t, "new.txt", files[1].path)
+	assert.Equal(t, diff, files[0].diff+files[1].diff)
+}
+
+func TestLargestFileDiffs(t *testing.T) {
+	files := []fileDiff{{path: "a", diff: "12"}, {path: "b", diff: "1234"}, {path: "c", diff: "1"}, {path: "d", diff: "123"}}
+
+	assert.Equal(t, map[int]bool{1: true, 3: true}, largestFileDiffs(files, 2))
+	assert.Len(t, largestFileDiffs(files, 10), 4)
+}
+
+func TestFileDiffLineCounts(t *testing.T) {
+	file := fileDiff{path: "main.go", diff: "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,3 @@\n-package main\n+package lib\n+// added\n import \"fmt\"\n"}
+
+	additions, deletions := file.lineCounts()
+	assert.Equal(t, 2, additions)
+	assert.Equal(t, 1, deletions)
+}
+
+func TestGetBudgetedGitDiff(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"a.go": "package a\n", "b.go": "package b\n"})
+	assert.NoError(t, os.WriteFile(path.Join(root, "a.go"), []byte("package a2\n"), 0o644))
+	assert.NoError(t, os.WriteFile(path.Join(root, "b.go"), []byte("package b2\n"), 0o644))
+
+	t.Run("under budget keeps the raw diff", func(t *testing.T) {
+		output, err := getBudgetedGitDiff(&Config{PerFileDiffSummary: true}, root, true)
+		assert.NoError(t, err)
+		assert.Contains(t, output, "+package a2")
+	})
+
+	t.Run("over budget summarizes each file once", func(t *testing.T) {
+		var received map[string]any
+		server := newFakeOllamaServer(t, "llama3", &received)
+		defer server.Close()
+		cfg := &Config{PerFileDiffSummary: true, DiffBudget: 10, Provider: "ollama", Model: "llama3", OllamaServerAddress: server.URL}
+		assert.NoError(t, writeCachedOutput(root, "diff_summary_0123456789abcdef", "an old summary"))
+		expired := time.Now().Add(-perFileSummaryCacheTTL - time.Hour)
+		assert.NoError(t, os.Chtimes(cachedOutputPath(root, "diff_summary_0123456789abcdef"), expired, expired))
+
+		output, err := getBudgetedGitDiff(cfg, root, true)
+		assert.NoError(t, err)
+		assert.NoFileExists(t, cachedOutputPath(root, "diff_summary_0123456789abcdef"), "expired summaries are pruned")
+		assert.Contains(t, output, "each file's changes are summarized instead:\n- a.go: generated summary\n- b.go: generated summary\n")
+		assert.NotContains(t, output, "+package a2")
+
+		// the second run is served from the cache
+		server.Close()
+		cached, err := getBudgetedGitDiff(cfg, root, true)
+		assert.NoError(t, err)
+		assert.Contains(t, cached, "- a.go: generated summary\n")
+	})
+}
@@ -0,0 +1,89 @@
+package xplane
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProgressEventKind tells which step of a run a ProgressEvent is about
+type ProgressEventKind int
+
+const (
+	ProgressGatheringStarted ProgressEventKind = iota
+	ProgressCommandStarted
+	ProgressCommandFinished
+	ProgressLLMStarted
+	ProgressLLMFinished
+)
+
+// ProgressEvent is handed to Config.OnProgress as a run goes, so embedders can render their own progress
+type ProgressEvent struct {
+	Kind     ProgressEventKind
+	Command  string        // the command being run, or 'file:<path>' for XPLANE_INCLUDE_FILES
+	Cached   bool          // the command's output comes from the remote cache
+	Provider string        // the git provider for remote commands, the llm provider for llm events
+	Model    string        // llm events only
+	Duration time.Duration // finished events only
+	Err      error         // finished events only
+}
+
+// what the CLI prints when each built-in command starts, remote commands get buildRemoteInfoMsg instead
+var commandProgressMessages = map[string]string{
+	"git_status":         MsgCheckingGitStatus,
+	"git_log":            MsgFetchingGitLog,
+	"git_log_patches":    MsgFetchingGitLogPatches,
+	"diff_since_release": MsgFetchingDiffSinceRelease,
+	"branch_diff":        MsgFetchingBranchDiff,
+	"git_diff":           MsgFetchingGitDiff,
+	"diff_summary":       MsgFetchingDiffSummary,
+	"tokei":              MsgGetCodeStats,
+	"ripsecrets":         MsgGetLeakedSecrets,
+	"coverage":           MsgGetCoverage,
+	"build_size":         MsgGetBuildSize,
+	"api_spec_diff":      MsgFetchingAPISpecDiff,
+	"docs_diff":          MsgFetchingDocsDiff,
+	"test_diff":          MsgFetchingTestDiff,
+	"container_diff":     MsgFetchingContainerDiff,
+	"ci_config_diff":     MsgFetchingCIConfigDiff,
+	"recent_blame":       MsgFetchingRecentBlame,
+	"git_submodules":     MsgFetchingSubmodules,
+	"license":            MsgFetchingLicense,
+	"rerere_status":      MsgFetchingRerereStatus,
+	"conflict_markers":   MsgFetchingConflictMarkers,
+	"reverts":            MsgFetchingReverts,
+	"path_history":       MsgFetchingPathHistory,
+}
+
+// sends the event to Config.OnProgress, or prints it like the CLI always did when there's none
+func (cfg *Config) reportProgress(event ProgressEvent) {
+	if cfg.OnProgress != nil {
+		cfg.OnProgress(event)
+		return
+	}
+	printProgress(cfg, event)
+}
+
+func printProgress(cfg *Config, event ProgressEvent) {
+	switch event.Kind {
+	case ProgressGatheringStarted:
+		fmt.Println(cfg.msg(MsgFetchingContext))
+	case ProgressCommandStarted:
+		if event.Provider != "" {
+			fmt.Println(cfg.msg(buildRemoteInfoMsg(event.Provider, event.Command)))
+		}
+		if event.Cached {
+			fmt.Println(cfg.msg(MsgUsingCachedOutput))
+			return
+		}
+		if msg, ok := commandProgressMessages[event.Command]; ok {
+			fmt.Println(cfg.msg(msg))
+		} else if path, ok := strings.CutPrefix(event.Command, "file:"); ok {
+			fmt.Printf(cfg.msg(MsgIncludingFile), path)
+		} else if _, isSpecial := specialCommandToBinMap[event.Command]; !isSpecial {
+			fmt.Printf(cfg.msg(MsgGenericCommand), event.Command)
+		}
+	case ProgressLLMStarted:
+		fmt.Printf(cfg.msg(MsgAnalyzingContext), event.Provider, event.Model)
+	}
+}
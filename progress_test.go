@@ -0,0 +1,78 @@
+package xplane
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGatherContextReportsProgress(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"main.go": "package main\n", "NOTES.md": "notes\n"})
+	var events []ProgressEvent
+	cfg := &Config{
+		Commands:     []string{"git_status"},
+		IncludeFiles: []string{"NOTES.md"},
+		OnProgress:   func(event ProgressEvent) { events = append(events, event) },
+	}
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	_, err := gatherContext(cfg, root, false)
+	w.Close()
+	os.Stdout = old
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	assert.NoError(t, err)
+	assert.Empty(t, buf.String(), "nothing printed when the embedder handles progress")
+
+	var kinds []ProgressEventKind
+	var commands []string
+	for _, event := range events {
+		kinds = append(kinds, event.Kind)
+		commands = append(commands, event.Command)
+		assert.NoError(t, event.Err)
+	}
+	assert.Equal(t, []ProgressEventKind{
+		ProgressGatheringStarted,
+		ProgressCommandStarted, ProgressCommandFinished,
+		ProgressCommandStarted, ProgressCommandFinished,
+	}, kinds)
+	assert.Equal(t, []string{"", "git_status", "git_status", "file:NOTES.md", "file:NOTES.md"}, commands)
+}
+
+func TestPrintProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    ProgressEvent
+		expected string
+	}{
+		{"built-in command", ProgressEvent{Kind: ProgressCommandStarted, Command: "git_log"}, MsgFetchingGitLog + "\n"},
+		{"remote command from cache", ProgressEvent{Kind: ProgressCommandStarted, Command: "release", Provider: "github", Cached: true},
+			buildRemoteInfoMsg("github", "release") + "\n" + MsgUsingCachedOutput + "\n"},
+		{"generic command", ProgressEvent{Kind: ProgressCommandStarted, Command: "ls"}, fmt.Sprintf(MsgGenericCommand, "ls")},
+		{"special command without a message", ProgressEvent{Kind: ProgressCommandStarted, Command: "readme"}, ""},
+		{"included file", ProgressEvent{Kind: ProgressCommandStarted, Command: "file:docs/ARCH.md"}, fmt.Sprintf(MsgIncludingFile, "docs/ARCH.md")},
+		{"finished commands stay quiet", ProgressEvent{Kind: ProgressCommandFinished, Command: "git_log"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := os.Stdout
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+			printProgress(&Config{}, tt.event)
+			w.Close()
+			os.Stdout = old
+			var buf bytes.Buffer
+			io.Copy(&buf, r)
+
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package xplane
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const pullRequestPrompt = `You are reviewing a pull/merge request for a teammate who hasn't looked at it yet.
+
+Summarize it in markdown: what it changes and why, going by the diff rather than only the description, which parts deserve a careful review, and anything that looks risky or unfinished. Point out where the diff doesn't match what the description claims.
+
+--- PULL REQUEST ---
+{{PULL_REQUEST}}
+--- DIFF ---
+{{DIFF}}`
+
+// the title, description and diff of a pull/merge request of the primary remote, fetched from the provider
+// so the local working tree doesn't matter
+func buildPullRequestPrompt(gitRoot string, cfg *Config, number int) (string, error) {
+	provider, err := getGitProvider(gitRoot, cfg)
+	if err != nil {
+		return "", err
+	}
+	url, err := findPrimaryRemoteRepoURL(gitRoot)
+	if err != nil {
+		return "", err
+	}
+	_, owner, repo, err := parseGitURL(url)
+	if err != nil {
+		return "", err
+	}
+
+	pr, err := provider.GetPullRequest(owner, repo, number)
+	if err != nil {
+		return "", err
+	}
+	diff, err := provider.GetPullRequestDiff(owner, repo, number)
+	if err != nil {
+		return "", err
+	}
+
+	var anonymizer *authorAnonymizer
+	if cfg.AnonymizeAuthors {
+		if anonymizer, err = newRepoAnonymizer(gitRoot, true); err != nil {
+			return "", err
+		}
+	}
+	return formatPullRequestPrompt(cfg, pr, diff, anonymizer), nil
+}
+
+// with an anonymizer, the author gets a pseudonym and any known name in the description or the diff,
+// like a reviewer mentioned there, is replaced as in the context
+func formatPullRequestPrompt(cfg *Config, pr *PullRequest, diff string, anonymizer *authorAnonymizer) string {
+	if budget := cfg.diffBudget(); len(diff) > budget {
+		kept := truncateAtRune(diff, budget)
+		diff = fmt.Sprintf("%s\n[diff truncated, %d more characters]\n", kept, len(diff)-len(kept))
+	}
+	if anonymizer != nil {
+		anonymized := *pr
+		anonymized.Author = anonymizer.pseudonym(pr.Author)
+		anonymized.Description = anonymizer.anonymize(pr.Description)
+		pr = &anonymized
+		diff = anonymizer.anonymize(diff)
+	}
+	pullRequest := fmt.Sprintf("#%d %s", pr.Number, formatEntity(pr, cfg.PRTemplate))
+	// a single pass, so a description or a diff that happens to contain a placeholder is left alone
+	prompt := strings.NewReplacer("{{PULL_REQUEST}}", pullRequest, "{{DIFF}}", diff).Replace(pullRequestPrompt)
+	return wrapPrompt(withLanguage(prompt, cfg.Language), cfg.PromptPrefix, cfg.PromptSuffix)
+}
+
+// SummarizePullRequest returns the LLM's summary of a pull/merge request on the repo's primary remote.
+// it doesn't look at the local changes and never writes to .xplane
+func SummarizePullRequest(ctx context.Context, cfg *Config, number int) (string, error) {
+	gitRoot, err := findGitRoot()
+	if err != nil {
+		return "", fmt.Errorf("not inside a git repository: %w", err)
+	}
+	llmProvider, err := pickLLM(cfg)
+	if err != nil {
+		return "", fmt.Errorf("could not load an llm provider: %w", err)
+	}
+
+	finalPrompt, err := buildPullRequestPrompt(gitRoot, cfg, number)
+	if err != nil {
+		return "", err
+	}
+	return summarizeWithContext(ctx, llmProvider, finalPrompt)
+}
+
+// RunPullRequest is the CLI side of SummarizePullRequest: it shows progress and prints the rendered summary
+func RunPullRequest(cfg *Config, number int) error {
+	gitRoot, err := findGitRoot()
+	if err != nil {
+		return fmt.Errorf("not inside a git repository: %w", err)
+	}
+	llmProvider, err := pickLLM(cfg)
+	if err != nil {
+		return fmt.Errorf("could not load an llm provider: %w", err)
+	}
+
+	fmt.Printf(cfg.msg(MsgFetchingPullRequest), number)
+	finalPrompt, err := buildPullRequestPrompt(gitRoot, cfg, number)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(cfg.msg(MsgSummarizingPullRequest), number, llmProvider.getName(), llmProvider.getModel())
+	return printGeneratedSummary(cfg, llmProvider, finalPrompt)
+}
@@ -0,0 +1,44 @@
+package xplane
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatPullRequestPrompt(t *testing.T) {
+	pr := &PullRequest{Number: 17, Title: "Refresh tokens", Author: "alice", Description: "Fixes the expiry bug"}
+	cfg := &Config{DiffBudget: 10, PromptPrefix: "Be brief."}
+
+	prompt := formatPullRequestPrompt(cfg, pr, "+0123456789abcdef", nil)
+
+	assert.True(t, strings.HasPrefix(prompt, "Be brief.\n\n"))
+	assert.Contains(t, prompt, "#17 - Refresh tokens (by alice)")
+	assert.Contains(t, prompt, "Body: Fixes the expiry bug")
+	assert.Contains(t, prompt, "+012345678\n[diff truncated, 7 more characters]")
+	assert.NotContains(t, prompt, "{{")
+}
+
+func TestFormatPullRequestPromptLeavesPlaceholdersInValues(t *testing.T) {
+	pr := &PullRequest{Number: 3, Title: "Docs", Author: "bob", Description: "Explains the {{DIFF}} and {{PULL_REQUEST}} placeholders"}
+
+	prompt := formatPullRequestPrompt(&Config{}, pr, "+see {{PULL_REQUEST}}", nil)
+
+	assert.Contains(t, prompt, "Body: Explains the {{DIFF}} and {{PULL_REQUEST}} placeholders")
+	assert.Contains(t, prompt, "--- DIFF ---\n+see {{PULL_REQUEST}}")
+}
+
+func TestFormatPullRequestPromptAnonymizesAuthors(t *testing.T) {
+	pr := &PullRequest{Number: 17, Title: "Refresh tokens", Author: "alice", Description: "Addresses Bob Smith's review"}
+	anonymizer := newAuthorAnonymizer(testAnonymizerKey)
+	bob := anonymizer.pseudonym("Bob Smith")
+
+	prompt := formatPullRequestPrompt(&Config{}, pr, "+// suggested by Bob Smith", anonymizer)
+
+	assert.Contains(t, prompt, "(by "+anonymizer.pseudonym("alice")+")")
+	assert.Contains(t, prompt, "Addresses "+bob+"'s review")
+	assert.Contains(t, prompt, "+// suggested by "+bob)
+	assert.NotContains(t, prompt, "alice")
+	assert.NotContains(t, pr.Author, "Author ", "the fetched pull request is left as is")
+}
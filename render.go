@@ -0,0 +1,71 @@
+package xplane
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+
+	"github.com/yuin/goldmark"
+)
+
+// SummaryRenderer turns the raw markdown summary into what gets printed
+type SummaryRenderer interface {
+	Render(summary string) (string, error)
+}
+
+const defaultOutputFormat = "glamour"
+
+var summaryRenderers = map[string]SummaryRenderer{
+	"glamour": glamourRenderer{},
+	"plain":   plainRenderer{},
+	"json":    jsonRenderer{},
+	"html":    htmlRenderer{},
+}
+
+// the styled terminal output, header included
+type glamourRenderer struct {
+	ascii bool // XPLANE_ASCII_ONLY, see Config.summaryRenderer
+}
+
+func (r glamourRenderer) Render(summary string) (string, error) {
+	return renderMarkdown(summary, r.ascii)
+}
+
+// the markdown exactly as the LLM wrote it, for pipes and files
+type plainRenderer struct{}
+
+func (plainRenderer) Render(summary string) (string, error) {
+	return summary, nil
+}
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) Render(summary string) (string, error) {
+	output, err := json.MarshalIndent(map[string]string{"summary": summary}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// a standalone page, e.g. to publish the summary somewhere
+type htmlRenderer struct{}
+
+const htmlPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+</head>
+<body>
+%s</body>
+</html>`
+
+func (htmlRenderer) Render(summary string) (string, error) {
+	var body bytes.Buffer
+	if err := goldmark.Convert([]byte(summary), &body); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(htmlPageTemplate, html.EscapeString("xplane summary"), body.String()), nil
+}
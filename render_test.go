@@ -0,0 +1,46 @@
+package xplane
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummaryRenderers(t *testing.T) {
+	summary := "## Changes\n- fixed <b>bugs</b>"
+
+	t.Run("plain", func(t *testing.T) {
+		output, err := summaryRenderers["plain"].Render(summary)
+		assert.NoError(t, err)
+		assert.Equal(t, summary, output)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		output, err := summaryRenderers["json"].Render(summary)
+		assert.NoError(t, err)
+		var decoded map[string]string
+		assert.NoError(t, json.Unmarshal([]byte(output), &decoded))
+		assert.Equal(t, summary, decoded["summary"])
+	})
+
+	t.Run("html", func(t *testing.T) {
+		output, err := summaryRenderers["html"].Render(summary)
+		assert.NoError(t, err)
+		assert.Contains(t, output, "<!DOCTYPE html>")
+		assert.Contains(t, output, "<h2>Changes</h2>")
+		assert.NotContains(t, output, "<b>bugs</b>", "raw html from the LLM is not passed through")
+	})
+}
+
+type customRenderer struct{}
+
+func (customRenderer) Render(summary string) (string, error) {
+	return "custom: " + summary, nil
+}
+
+func TestConfigSummaryRenderer(t *testing.T) {
+	assert.Equal(t, summaryRenderers["glamour"], (&Config{}).summaryRenderer())
+	assert.Equal(t, summaryRenderers["json"], (&Config{OutputFormat: "json"}).summaryRenderer())
+	assert.Equal(t, customRenderer{}, (&Config{OutputFormat: "json", Renderer: customRenderer{}}).summaryRenderer())
+}
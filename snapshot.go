@@ -0,0 +1,147 @@
+package xplane
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const snapshotsDir = "snapshots"
+
+// names end up as file names, so only plain ones are allowed
+var snapshotNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func snapshotPath(gitRoot, name string) (string, error) {
+	if !snapshotNameRegex.MatchString(name) {
+		return "", fmt.Errorf("invalid snapshot name '%s', use letters, digits, '.', '_' and '-' only", name)
+	}
+	return filepath.Join(gitRoot, contextDir, snapshotsDir, name+".txt"), nil
+}
+
+func readSnapshot(gitRoot, name string) (string, error) {
+	path, err := snapshotPath(gitRoot, name)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("no snapshot named '%s', save one with 'xplane snapshot save %s'", name, name)
+	} else if err != nil {
+		return "", fmt.Errorf("could not read snapshot '%s': %w", name, err)
+	}
+	return string(content), nil
+}
+
+// SaveSnapshot gathers the current context and stores it under .xplane/snapshots/<name>.txt,
+// overwriting any snapshot with the same name. the regular stored context is left untouched
+func SaveSnapshot(cfg *Config, name string) error {
+	gitRoot, err := findGitRoot()
+	if err != nil {
+		return fmt.Errorf("not inside a git repository: %w", err)
+	}
+	path, err := snapshotPath(gitRoot, name)
+	if err != nil {
+		return err
+	}
+
+	currentContext, err := gatherContext(cfg, gitRoot, false)
+	if err != nil {
+		return fmt.Errorf("error gathering context: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("could not create snapshots directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(currentContext), 0o644); err != nil {
+		return fmt.Errorf("could not write snapshot '%s': %w", name, err)
+	}
+	fmt.Printf(cfg.msg(MsgSnapshotSaved), name)
+	return nil
+}
+
+// ListSnapshots returns the names of the saved snapshots, sorted
+func ListSnapshots() ([]string, error) {
+	gitRoot, err := findGitRoot()
+	if err != nil {
+		return nil, fmt.Errorf("not inside a git repository: %w", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(gitRoot, contextDir, snapshotsDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read snapshots directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if name, found := strings.CutSuffix(entry.Name(), ".txt"); found && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// builds the usual prompt, with the 'from' snapshot as the previous state and the 'to' one as the current state
+func buildSnapshotPrompt(gitRoot string, cfg *Config, from, to string) (string, error) {
+	staticPrompt, err := readStaticPrompt(gitRoot)
+	if err != nil {
+		return "", err
+	}
+	fromContext, err := readSnapshot(gitRoot, from)
+	if err != nil {
+		return "", err
+	}
+	toContext, err := readSnapshot(gitRoot, to)
+	if err != nil {
+		return "", err
+	}
+	fromContext, toContext, err = maybeCondenseContexts(cfg, fromContext, toContext)
+	if err != nil {
+		return "", err
+	}
+	return buildFinalPrompt(staticPrompt, "", fromContext, toContext, cfg), nil
+}
+
+// CompareSnapshots returns the LLM's summary of what changed between two saved snapshots, without writing anything
+func CompareSnapshots(ctx context.Context, cfg *Config, from, to string) (string, error) {
+	gitRoot, err := findGitRoot()
+	if err != nil {
+		return "", fmt.Errorf("not inside a git repository: %w", err)
+	}
+	llmProvider, err := pickLLM(cfg)
+	if err != nil {
+		return "", fmt.Errorf("could not load an llm provider: %w", err)
+	}
+
+	finalPrompt, err := buildSnapshotPrompt(gitRoot, cfg, from, to)
+	if err != nil {
+		return "", err
+	}
+	return summarizeWithContext(ctx, llmProvider, finalPrompt)
+}
+
+// RunCompare is the CLI side of CompareSnapshots: it shows progress and prints the rendered summary
+func RunCompare(cfg *Config, from, to string) error {
+	gitRoot, err := findGitRoot()
+	if err != nil {
+		return fmt.Errorf("not inside a git repository: %w", err)
+	}
+	llmProvider, err := pickLLM(cfg)
+	if err != nil {
+		return fmt.Errorf("could not load an llm provider: %w", err)
+	}
+
+	finalPrompt, err := buildSnapshotPrompt(gitRoot, cfg, from, to)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(cfg.msg(MsgComparingSnapshots), from, to, llmProvider.getName(), llmProvider.getModel())
+	return printGeneratedSummary(cfg, llmProvider, finalPrompt)
+}
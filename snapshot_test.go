@@ -0,0 +1,74 @@
+package xplane
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		expectError bool
+	}{
+		{"v1.2.0", false},
+		{"before_refactor", false},
+		{"", true},
+		{"../escape", true},
+		{".hidden", true},
+		{"with space", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := snapshotPath("/repo", tt.name)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, filepath.Join("/repo", contextDir, snapshotsDir, tt.name+".txt"), path)
+		})
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	root := initTestRepo(t, map[string]string{"README.md": "# first\n"})
+	t.Chdir(root)
+
+	var received map[string]any
+	server := newFakeOllamaServer(t, "llama3", &received)
+	defer server.Close()
+
+	cfg := &Config{
+		Commands:            []string{"readme"},
+		Provider:            "ollama",
+		Model:               "llama3",
+		OllamaServerAddress: server.URL,
+	}
+
+	assert.NoError(t, SaveSnapshot(cfg, "before"))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "README.md"), []byte("# second\n"), 0o644))
+	assert.NoError(t, SaveSnapshot(cfg, "after"))
+
+	names, err := ListSnapshots()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"after", "before"}, names)
+
+	summary, err := CompareSnapshots(context.Background(), cfg, "before", "after")
+	assert.NoError(t, err)
+	assert.Equal(t, "generated summary", summary)
+	prompt := received["prompt"].(string)
+	assert.Less(t, strings.Index(prompt, "# first"), strings.Index(prompt, "# second"))
+
+	_, err = CompareSnapshots(context.Background(), cfg, "before", "missing")
+	assert.ErrorContains(t, err, "no snapshot named 'missing'")
+
+	// snapshots never advance the regular stored context
+	_, statErr := os.Stat(filepath.Join(root, contextDir, dynamicContextFile))
+	assert.True(t, os.IsNotExist(statErr))
+}
@@ -0,0 +1,67 @@
+package xplane
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// the built-in prompt templates. each one is overridden on its own by a file of the same name in .xplane/,
+// so e.g. the knowledge instructions can be changed without copying the whole prompt
+//
+//go:embed templates/*.txt
+var defaultTemplates embed.FS
+
+const knowledgeInstructionsFile = "knowledge_instructions.txt"
+
+var (
+	defaultStaticContext         = mustReadDefaultTemplate(staticContextFile)
+	defaultKnowledgeInstructions = mustReadDefaultTemplate(knowledgeInstructionsFile)
+)
+
+func mustReadDefaultTemplate(name string) string {
+	content, err := defaultTemplates.ReadFile(path.Join("templates", name))
+	if err != nil {
+		panic(fmt.Sprintf("xplane: built-in template %s is missing: %v", name, err))
+	}
+	return string(content)
+}
+
+// .xplane/<name> when the project has one, with its {{INCLUDE:path}} resolved, or the built-in default
+func readTemplate(gitRoot, name string) (string, error) {
+	overridePath := filepath.Join(gitRoot, contextDir, name)
+	content, err := os.ReadFile(overridePath)
+	if os.IsNotExist(err) {
+		return mustReadDefaultTemplate(name), nil
+	} else if err != nil {
+		return "", fmt.Errorf("could not read %s: %w", name, err)
+	}
+	return resolveIncludes(string(content), filepath.Dir(overridePath), []string{overridePath})
+}
+
+// InitStaticContext writes the built-in prompt to .xplane/static_context.txt as a starting point for a
+// customized one, and returns its path. it's never done implicitly: once the file exists it overrides the
+// built-in prompt for good, including its later improvements
+func InitStaticContext() (string, error) {
+	gitRoot, err := findGitRoot()
+	if err != nil {
+		return "", fmt.Errorf("not inside a git repository: %w", err)
+	}
+	return initStaticContext(gitRoot)
+}
+
+func initStaticContext(gitRoot string) (string, error) {
+	staticContextPath := filepath.Join(gitRoot, contextDir, staticContextFile)
+	if _, err := os.Stat(staticContextPath); err == nil {
+		return "", fmt.Errorf("%s already exists, not overwriting it", filepath.Join(contextDir, staticContextFile))
+	}
+	if err := os.MkdirAll(filepath.Dir(staticContextPath), 0o755); err != nil {
+		return "", fmt.Errorf("could not create .xplane directory: %w", err)
+	}
+	if err := os.WriteFile(staticContextPath, []byte(defaultStaticContext), 0o644); err != nil {
+		return "", fmt.Errorf("could not write default static context: %w", err)
+	}
+	return staticContextPath, nil
+}
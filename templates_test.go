@@ -0,0 +1,52 @@
+package xplane
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadTemplate(t *testing.T) {
+	root := t.TempDir()
+
+	staticPrompt, err := readTemplate(root, staticContextFile)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultStaticContext, staticPrompt)
+	assert.Contains(t, staticPrompt, "{{PREVIOUS_CONTEXT}}")
+
+	// overriding the knowledge instructions leaves the main prompt on its default
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, contextDir), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, contextDir, "team.txt"), []byte("Write for the platform team."), 0o644))
+	override := "Keep a KNOWLEDGE UPDATE short.\n{{INCLUDE:team.txt}}\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(root, contextDir, knowledgeInstructionsFile), []byte(override), 0o644))
+
+	instructions, err := readTemplate(root, knowledgeInstructionsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "Keep a KNOWLEDGE UPDATE short.\nWrite for the platform team.\n", instructions)
+	staticPrompt, err = readTemplate(root, staticContextFile)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultStaticContext, staticPrompt)
+
+	section := buildKnowledgeSection("# Project Knowledge", instructions, "")
+	assert.True(t, strings.HasSuffix(section, "--- PROJECT KNOWLEDGE ---\n# Project Knowledge\n\nKeep a KNOWLEDGE UPDATE short.\nWrite for the platform team."))
+}
+
+func TestInitStaticContext(t *testing.T) {
+	root := t.TempDir()
+
+	path, err := initStaticContext(root)
+	assert.NoError(t, err)
+	written, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultStaticContext, string(written))
+
+	assert.NoError(t, os.WriteFile(path, []byte("My prompt"), 0o644))
+	_, err = initStaticContext(root)
+	assert.ErrorContains(t, err, "already exists")
+	written, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "My prompt", string(written), "an existing prompt is never overwritten")
+}
@@ -1,11 +1,19 @@
-package main
+package xplane
 
 import (
 	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/charmbracelet/glamour"
 )
 
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
 const xplaneHeader = `
 ██╗  ██╗██████╗ ██╗      █████╗ ███╗   ██╗███████╗
 ╚██╗██╔╝██╔══██╗██║     ██╔══██╗████╗  ██║██╔════╝
@@ -16,16 +24,226 @@ const xplaneHeader = `
                                                   
 `
 
-// formats a raw markdown string and renders it in a terminal environment
-func renderMarkdown(rawMarkdown string) (string, error) {
-	fullContent := fmt.Sprintf("```\n%s\n```\n\n%s", xplaneHeader, rawMarkdown)
-	renderer, err := glamour.NewTermRenderer(
-		glamour.WithStandardStyle("dracula"),
+// prints the summary with the configured renderer, or the raw markdown if rendering fails
+func printSummary(cfg *Config, summary string) {
+	renderedSummary, renderErr := cfg.summaryRenderer().Render(summary)
+	if renderErr != nil {
+		// fallback to printing
+		fmt.Println("Error rendering markdown, printing raw output:")
+		fmt.Println(summary)
+		return
+	}
+	fmt.Println(renderedSummary)
+}
+
+// prints the uncommitted diff after the summary for --show-diff, so the summary can be checked against it.
+// the terminal style gets it highlighted through a diff code fence, plain gets it raw, and the json/html
+// outputs are meant for other tools so they're left alone
+func printDiff(cfg *Config, gitRoot string) {
+	format := cfg.OutputFormat
+	if format == "" {
+		format = defaultOutputFormat
+	}
+	if cfg.Renderer != nil || (format != "glamour" && format != "plain") {
+		log.Printf("Warning: --show-diff only works with the glamour and plain output formats, skipping the diff")
+		return
+	}
+
+	diff, err := runCommand(gitRoot, "git", excludingXplaneFiles(cfg.ownFilesOutsideContextDir(), "diff")...)
+	if err != nil {
+		log.Printf("Warning: Could not get the diff to show: %s", redactError(err))
+		return
+	}
+	if strings.TrimSpace(diff) == "" {
+		fmt.Println(cfg.msg(MsgNoDiffToShow))
+		return
+	}
+	if format == "plain" {
+		fmt.Println(diff)
+		return
+	}
+	fmt.Println(renderDiff(diff, cfg.ASCIIOnly))
+}
+
+// falls back to the raw diff when it can't be highlighted
+func renderDiff(diff string, ascii bool) string {
+	renderer, err := newMarkdownRenderer(ascii)
+	if err != nil {
+		return diff
+	}
+	rendered, err := renderer.Render("```diff\n" + strings.TrimRight(diff, "\n") + "\n```\n")
+	if err != nil {
+		return diff
+	}
+	return rendered
+}
+
+// ascii picks a style without box characters or colors, for XPLANE_ASCII_ONLY
+func newMarkdownRenderer(ascii bool) (*glamour.TermRenderer, error) {
+	style := "dracula"
+	if ascii {
+		style = "ascii"
+	}
+	return glamour.NewTermRenderer(
+		glamour.WithStandardStyle(style),
 		glamour.WithWordWrap(0), // setting to 0 lets the terminal emulator handle it
 	)
+}
+
+// formats a raw markdown string and renders it in a terminal environment
+func renderMarkdown(rawMarkdown string, ascii bool) (string, error) {
+	fullContent := fmt.Sprintf("```\n%s\n```\n\n%s", banner(ascii), rawMarkdown)
+	renderer, err := newMarkdownRenderer(ascii)
 	if err != nil {
 		return "", err
 	}
 
 	return renderer.Render(fullContent)
 }
+
+// renders markdown incrementally as it's written, one block at a time: a block ends on a blank line
+// outside of a code fence, so that a half written paragraph or code block is never rendered
+type markdownStream struct {
+	out      io.Writer
+	renderer *glamour.TermRenderer
+	line     strings.Builder // the current, incomplete line
+	block    strings.Builder // complete lines not rendered yet
+	inFence  bool
+}
+
+// starts a stream on out, printing the header right away
+func newMarkdownStream(cfg *Config, out io.Writer) (*markdownStream, error) {
+	renderer, err := newMarkdownRenderer(cfg.ASCIIOnly)
+	if err != nil {
+		return nil, err
+	}
+	stream := &markdownStream{out: out, renderer: renderer}
+	return stream, stream.render(fmt.Sprintf("```\n%s\n```\n", banner(cfg.ASCIIOnly)))
+}
+
+func (m *markdownStream) Write(chunk string) error {
+	for {
+		newline := strings.IndexByte(chunk, '\n')
+		if newline < 0 {
+			m.line.WriteString(chunk)
+			return nil
+		}
+		m.line.WriteString(chunk[:newline+1])
+		chunk = chunk[newline+1:]
+
+		line := m.line.String()
+		m.line.Reset()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			m.inFence = !m.inFence
+		}
+		m.block.WriteString(line)
+		if trimmed == "" && !m.inFence {
+			if err := m.flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// renders whatever is left, once the whole summary has been written
+func (m *markdownStream) Close() error {
+	m.block.WriteString(m.line.String())
+	m.line.Reset()
+	return m.flush()
+}
+
+func (m *markdownStream) flush() error {
+	block := m.block.String()
+	m.block.Reset()
+	if strings.TrimSpace(block) == "" {
+		return nil
+	}
+	return m.render(block)
+}
+
+func (m *markdownStream) render(block string) error {
+	rendered, err := m.renderer.Render(block)
+	if err != nil {
+		return err
+	}
+	// glamour pads every render with blank lines, trimming them keeps blocks as close as in a single render
+	_, err = fmt.Fprintln(m.out, strings.TrimRight(rendered, "\n "))
+	return err
+}
+
+// streaming only makes sense for the styled terminal output, other formats are meant to be read whole
+func canStreamSummary(cfg *Config, llm LLMProvider) bool {
+	if _, ok := llm.(streamingLLMProvider); !ok || !cfg.StreamSummary || !isTerminal(os.Stdout) {
+		return false
+	}
+	_, isGlamour := cfg.summaryRenderer().(glamourRenderer)
+	return isGlamour
+}
+
+// generates the summary while rendering it to out as it arrives, onFirstChunk runs before anything is printed
+func streamSummary(cfg *Config, llm streamingLLMProvider, finalPrompt string, out io.Writer, onFirstChunk func()) (string, error) {
+	var stream *markdownStream
+	summary, err := llm.streamContext(finalPrompt, func(chunk string) error {
+		if stream == nil {
+			onFirstChunk()
+			var err error
+			if stream, err = newMarkdownStream(cfg, out); err != nil {
+				return err
+			}
+		}
+		return stream.Write(chunk)
+	})
+	if stream != nil {
+		if closeErr := stream.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return summary, err
+}
+
+// reports whether f is attached to a terminal, so that pipes and CI logs don't get carriage return noise
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// redraws a spinner with the elapsed time on a single line until the returned stop function is called,
+// stop clears the line and is safe to call more than once
+func startProgressIndicator(out io.Writer, cfg *Config, providerName string) (stop func()) {
+	frames, format := spinnerFrames, cfg.msg(MsgWaitingForLLM)
+	if cfg.ASCIIOnly {
+		frames = asciiSpinnerFrames
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	start := time.Now()
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+
+		for frame := 0; ; frame++ {
+			elapsed := int(time.Since(start).Seconds())
+			fmt.Fprintf(out, format, frames[frame%len(frames)], providerName, elapsed)
+			select {
+			case <-done:
+				fmt.Fprint(out, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}
+}
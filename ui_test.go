@@ -1,7 +1,13 @@
-package main
+package xplane
 
 import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,7 +26,7 @@ func TestRenderMarkdown(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := renderMarkdown(tt.input)
+			result, err := renderMarkdown(tt.input, false)
 			
 			if tt.expectError {
 				assert.Error(t, err)
@@ -34,4 +40,109 @@ func TestRenderMarkdown(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestRenderDiff(t *testing.T) {
+	diff := "diff --git a/main.go b/main.go\n-fmt.Println(\"old\")\n+fmt.Println(\"new\")\n"
+	plain := ansiEscapeRegex.ReplaceAllString(renderDiff(diff, false), "")
+
+	assert.Contains(t, plain, `-fmt.Println("old")`)
+	assert.Contains(t, plain, `+fmt.Println("new")`)
+	assert.NotContains(t, plain, "```")
+}
+
+// guards the buffer the progress goroutine writes into
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestStartProgressIndicator(t *testing.T) {
+	var out syncBuffer
+	stop := startProgressIndicator(&out, &Config{}, "Ollama")
+	time.Sleep(150 * time.Millisecond)
+	stop()
+	stop() // stopping twice must not panic
+
+	output := out.String()
+	assert.Contains(t, output, "Waiting for Ollama")
+	assert.True(t, strings.HasSuffix(output, "\r\033[K"), "line should be cleared on stop")
+}
+
+func TestIsTerminal(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+
+	assert.False(t, isTerminal(w))
+}
+
+var ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func TestMarkdownStream(t *testing.T) {
+	var out bytes.Buffer
+	rendered := func() string { return ansiEscapeRegex.ReplaceAllString(out.String(), "") }
+	stream, err := newMarkdownStream(&Config{}, &out)
+	assert.NoError(t, err)
+	assert.Contains(t, rendered(), "██╗  ██╗██████╗", "the header is printed right away")
+
+	afterHeader := out.Len()
+	assert.NoError(t, stream.Write("# Title\n\nFirst para"))
+	titleRendered := out.Len()
+	assert.Greater(t, titleRendered, afterHeader, "a block is rendered once a blank line ends it")
+	assert.NotContains(t, rendered(), "First para")
+
+	// blank lines inside a code fence don't end the block
+	assert.NoError(t, stream.Write("graph\n\n```\ncode\n\nmore code\n"))
+	assert.Contains(t, rendered(), "First paragraph")
+	assert.NotContains(t, rendered(), "more code")
+
+	assert.NoError(t, stream.Write("```\nLast words"))
+	assert.NoError(t, stream.Close())
+	assert.Contains(t, rendered(), "more code")
+	assert.Contains(t, rendered(), "Last words")
+}
+
+// streams canned chunks instead of calling a real model
+type fakeStreamingLLM struct {
+	fakeLLM
+	chunks []string
+}
+
+func (f *fakeStreamingLLM) streamContext(finalPrompt string, onChunk func(string) error) (string, error) {
+	for _, chunk := range f.chunks {
+		if err := onChunk(chunk); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(f.chunks, ""), nil
+}
+
+func TestStreamSummary(t *testing.T) {
+	var out bytes.Buffer
+	firstChunks := 0
+	llm := &fakeStreamingLLM{chunks: []string{"# Sum", "mary\n\n", "Done."}}
+
+	summary, err := streamSummary(&Config{}, llm, "prompt", &out, func() { firstChunks++ })
+
+	assert.NoError(t, err)
+	assert.Equal(t, "# Summary\n\nDone.", summary)
+	assert.Equal(t, 1, firstChunks)
+	rendered := ansiEscapeRegex.ReplaceAllString(out.String(), "")
+	assert.Contains(t, rendered, "Summary")
+	assert.Contains(t, rendered, "Done.")
+	assert.False(t, canStreamSummary(&Config{StreamSummary: true}, &fakeLLM{}), "fakeLLM can't stream")
+}
@@ -0,0 +1,147 @@
+package xplane
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v74/github"
+)
+
+// Version is the xplane release this binary was built from, set with
+// -ldflags "-X github.com/Gdetrane/xplane.Version=v1.2.3", 'go install ...@v1.2.3' builds don't need it
+var Version = "dev"
+
+const (
+	xplaneRepoOwner     = "Gdetrane"
+	xplaneRepoName      = "xplane"
+	updateCheckCacheKey = "latest_xplane_release"
+	updateCheckTTL      = 24 * time.Hour
+	updateCheckTimeout  = 3 * time.Second // a notice isn't worth holding up a run for
+)
+
+// go's pseudo-versions, e.g. v0.0.0-20250101000000-abcdef123456 or v1.2.4-0.20250101000000-abcdef123456,
+// what 'go install ...@main' stamps a build with
+var pseudoVersionRegex = regexp.MustCompile(`[-.](?:0\.)?[0-9]{14}-[0-9a-f]{12}(?:\+incompatible)?$`)
+
+// the build's version, empty when it isn't a release, e.g. built from a checkout or from an untagged commit
+func buildVersion() string {
+	if Version != "dev" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && isReleaseVersion(info.Main.Version) {
+		return info.Main.Version
+	}
+	return ""
+}
+
+func isReleaseVersion(version string) bool {
+	return version != "" && version != "(devel)" && !pseudoVersionRegex.MatchString(version)
+}
+
+type updateCheckResult struct {
+	notice string
+	err    error
+}
+
+// looks for a newer xplane release in the background, with XPLANE_CHECK_UPDATES, so that a slow or
+// unreachable github never holds up a run. nil when there's nothing to check
+func startUpdateCheck(cfg *Config, gitRoot string) <-chan updateCheckResult {
+	current := buildVersion()
+	if current == "" {
+		return nil
+	}
+	httpClient := newHTTPClient(cfg.userAgent())
+	httpClient.Timeout = updateCheckTimeout
+	client := github.NewClient(httpClient)
+	if cfg.GithubToken != "" {
+		client = client.WithAuthToken(cfg.GithubToken)
+	}
+	// buffered, the check can finish after nobody is waiting for it anymore
+	results := make(chan updateCheckResult, 1)
+	go func() {
+		notice, err := checkForUpdate(gitRoot, &GithubProvider{client: client}, current)
+		results <- updateCheckResult{notice: notice, err: err}
+	}()
+	return results
+}
+
+// prints the update notice once the run's output is done, waiting at most updateCheckTimeout for the check.
+// never fails a run, being offline or rate limited just skips the notice
+func printUpdateNotice(cfg *Config, results <-chan updateCheckResult) {
+	if results == nil {
+		return
+	}
+	select {
+	case result := <-results:
+		if result.err != nil {
+			log.Printf("Warning: Could not check for xplane updates: %s", redactError(result.err))
+		} else if result.notice != "" {
+			fmt.Println(cfg.msg(result.notice))
+		}
+	case <-time.After(updateCheckTimeout):
+	}
+}
+
+// the latest release tag is cached for a day, there's no need to ask github on every run
+func checkForUpdate(gitRoot string, provider GitProvider, current string) (string, error) {
+	latest, isCached := readCachedOutput(gitRoot, updateCheckCacheKey, updateCheckTTL)
+	if !isCached {
+		release, err := provider.GetLatestRelease(xplaneRepoOwner, xplaneRepoName)
+		if err != nil {
+			return "", err
+		}
+		latest = release.TagName
+		if err := writeCachedOutput(gitRoot, updateCheckCacheKey, latest); err != nil {
+			log.Printf("Warning: Could not cache the latest xplane release: %s", redactError(err))
+		}
+	}
+
+	if !isNewerVersion(latest, current) {
+		return "", nil
+	}
+	return fmt.Sprintf(MsgUpdateAvailable, latest, current), nil
+}
+
+// compares vMAJOR.MINOR.PATCH versions, anything that doesn't parse (like 'No releases found') is never newer
+func isNewerVersion(candidate, current string) bool {
+	candidateParts, ok := parseVersion(candidate)
+	if !ok {
+		return false
+	}
+	currentParts, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range candidateParts {
+		if candidateParts[i] != currentParts[i] {
+			return candidateParts[i] > currentParts[i]
+		}
+	}
+	return false
+}
+
+// pre-release and build suffixes are ignored, v1.2.0-rc1 counts as v1.2.0
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if end := strings.IndexAny(version, "-+"); end >= 0 {
+		version = version[:end]
+	}
+	fields := strings.Split(version, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = number
+	}
+	return parts, true
+}
@@ -0,0 +1,79 @@
+package xplane
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v74/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsNewerVersion(t *testing.T) {
+	tests := []struct {
+		candidate string
+		current   string
+		expected  bool
+	}{
+		{"v1.3.0", "v1.2.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v2", "v1.9.9", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2.0", "v1.3.0", false},
+		{"v1.3.0-rc1", "v1.2.0", true},
+		{"No releases found", "v1.2.0", false},
+		{"v1.3.0", "v0.0.0-20250101000000-abcdef123456", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.candidate+" vs "+tt.current, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isNewerVersion(tt.candidate, tt.current))
+		})
+	}
+}
+
+func TestIsReleaseVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected bool
+	}{
+		{"v1.2.3", true},
+		{"v1.3.0-rc1", true},
+		{"", false},
+		{"(devel)", false},
+		{"v0.0.0-20250101000000-abcdef123456", false},
+		{"v1.2.4-0.20250101000000-abcdef123456", false},
+		{"v1.2.4-rc1.0.20250101000000-abcdef123456", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isReleaseVersion(tt.version))
+		})
+	}
+}
+
+func TestCheckForUpdateIsCached(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/repos/Gdetrane/xplane/releases/latest", r.URL.Path)
+		w.Write([]byte(`{"tag_name": "v1.4.0"}`))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	provider := &GithubProvider{client: client}
+	root := t.TempDir()
+
+	notice, err := checkForUpdate(root, provider, "v1.3.2")
+	assert.NoError(t, err)
+	assert.Contains(t, notice, "v1.4.0 is available (you have v1.3.2)")
+
+	notice, err = checkForUpdate(root, provider, "v1.4.0")
+	assert.NoError(t, err)
+	assert.Empty(t, notice)
+	assert.Equal(t, 1, requests, "the second run reads the cached release")
+}
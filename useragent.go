@@ -0,0 +1,25 @@
+package xplane
+
+import "net/http"
+
+// sets the User-Agent of every request going through it, for the plain net/http calls (ollama, and any
+// future api provider) that have no user agent option of their own
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.userAgent == "" {
+		return t.base.RoundTrip(req)
+	}
+	// a RoundTripper must not modify the request it's handed
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
+
+// an http.Client sending userAgent on every request, an empty one keeps go's default
+func newHTTPClient(userAgent string) *http.Client {
+	return &http.Client{Transport: &userAgentTransport{base: http.DefaultTransport, userAgent: userAgent}}
+}
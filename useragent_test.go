@@ -0,0 +1,37 @@
+package xplane
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserAgent(t *testing.T) {
+	assert.Equal(t, "acme-gateway/1.0", (&Config{UserAgent: "acme-gateway/1.0"}).userAgent())
+	assert.Regexp(t, `^xplane/\S+$`, (&Config{}).userAgent())
+
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("User-Agent"))
+		w.Write([]byte(`{"models": [{"name": "llama3:latest"}]}`))
+	}))
+	defer server.Close()
+
+	models, err := listOllamaModels(server.URL, "xplane/v1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"llama3:latest"}, models)
+
+	provider := NewGitHubProvider("token", "", "")
+	defaultUserAgent := provider.client.UserAgent
+	provider.SetUserAgent("")
+	assert.Equal(t, defaultUserAgent, provider.client.UserAgent, "an empty user agent keeps go-github's")
+	provider.SetUserAgent("xplane/v1.2.3")
+	assert.Equal(t, "xplane/v1.2.3", provider.client.UserAgent)
+
+	_, err = newHTTPClient("").Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "xplane/v1.2.3", received[0])
+	assert.Contains(t, received[1], "Go-http-client", "no user agent keeps go's default")
+}
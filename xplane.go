@@ -0,0 +1,135 @@
+package xplane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	contextDir         = ".xplane"
+	dynamicContextFile = "dynamic_context.txt"
+	staticContextFile  = "static_context.txt"
+	commandsFile       = "commands.txt"
+	knowledgeFile      = "KNOWLEDGE.md"
+	lastPromptFile     = "last_prompt.txt"
+)
+
+// Run is what the xplane binary does: compares the current context against the stored one, prints a summary
+// of what changed and advances .xplane/dynamic_context.txt
+func Run(cfg *Config) error {
+	gitRoot, err := findGitRoot()
+	if err != nil {
+		return fmt.Errorf("not inside a git repository: %w", err)
+	}
+
+	llmProvider, err := pickLLM(cfg)
+	if err != nil {
+		return fmt.Errorf("could not load an llm provider: %w", err)
+	}
+
+	var updateCheck <-chan updateCheckResult
+	if cfg.CheckUpdates {
+		updateCheck = startUpdateCheck(cfg, gitRoot)
+	}
+
+	if cfg.Explain {
+		fmt.Println(explainCommands(cfg))
+	}
+
+	err = contextCompare(llmProvider, cfg, gitRoot)
+	printUpdateNotice(cfg, updateCheck)
+	return err
+}
+
+// Summarize gathers the current context and returns the LLM's summary of how it differs from the stored one.
+// unlike Run it never writes to .xplane, so the stored context doesn't advance, the knowledge file is left alone
+// and nothing gets rendered or posted, that's up to the caller. the context is gathered as a dry run: remote
+// results are read from the cache but never written to it, coverage and build_size deltas aren't recorded,
+// build_size doesn't run the build and an oversized git_diff is sent whole rather than summarized per file
+func Summarize(ctx context.Context, cfg *Config) (string, error) {
+	gitRoot, err := findGitRoot()
+	if err != nil {
+		return "", fmt.Errorf("not inside a git repository: %w", err)
+	}
+
+	llmProvider, err := pickLLM(cfg)
+	if err != nil {
+		return "", fmt.Errorf("could not load an llm provider: %w", err)
+	}
+
+	staticPrompt, err := readStaticPrompt(gitRoot)
+	if err != nil {
+		return "", err
+	}
+
+	currentContext, err := gatherContext(cfg, gitRoot, true)
+	if err != nil {
+		return "", fmt.Errorf("error gathering context: %w", err)
+	}
+
+	// without a stored context yet, everything is compared against the first run placeholder
+	previousContext := createPlaceHolderContext(cfg)
+	storedContext, _, err := readDynamicContext(gitRoot, cfg.CompressContext)
+	if err == nil {
+		previousContext = storedContext
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("could not read %s: %w", dynamicContextFile, err)
+	}
+
+	knowledgeSection := ""
+	if cfg.UseProjectKnowledge {
+		knowledgeContent := "No existing project knowledge found."
+		if knowledgeBytes, err := os.ReadFile(filepath.Join(gitRoot, cfg.knowledgeFilePath())); err == nil {
+			knowledgeContent = string(knowledgeBytes)
+		}
+		instructions, err := readTemplate(gitRoot, knowledgeInstructionsFile)
+		if err != nil {
+			return "", err
+		}
+		knowledgeSection = buildKnowledgeSection(knowledgeContent, instructions, cfg.Language)
+	}
+
+	if cfg.IncludePRIntent {
+		staticPrompt = prependStatedIntent(staticPrompt, gitRoot, cfg)
+	}
+
+	previousContext, currentContext, err = maybeCondenseContexts(cfg, previousContext, currentContext)
+	if err != nil {
+		return "", err
+	}
+	finalPrompt := buildFinalPrompt(staticPrompt, knowledgeSection, previousContext, currentContext, cfg)
+	cfg.reportProgress(ProgressEvent{Kind: ProgressLLMStarted, Provider: llmProvider.getName(), Model: llmProvider.getModel()})
+	startedAt := time.Now()
+	summary, err := summarizeWithContext(ctx, llmProvider, finalPrompt)
+	cfg.reportProgress(ProgressEvent{Kind: ProgressLLMFinished, Provider: llmProvider.getName(), Model: llmProvider.getModel(), Duration: time.Since(startedAt), Err: err})
+	return summary, err
+}
+
+// providers don't take a context, so the call is raced against it instead of being cancelled
+func summarizeWithContext(ctx context.Context, llm LLMProvider, finalPrompt string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	type llmResult struct {
+		summary string
+		err     error
+	}
+	done := make(chan llmResult, 1)
+	go func() {
+		summary, err := llm.summarizeContext(finalPrompt)
+		done <- llmResult{summary, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case result := <-done:
+		return result.summary, result.err
+	}
+}
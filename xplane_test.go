@@ -0,0 +1,52 @@
+package xplane
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarize(t *testing.T) {
+	root := initTestRepo(t, map[string]string{
+		"README.md":    "# demo\n",
+		"coverage.out": "mode: set\na.go:1.1,2.2 4 1\na.go:3.1,4.2 1 0\n",
+	})
+	t.Chdir(root)
+
+	var received map[string]any
+	server := newFakeOllamaServer(t, "llama3", &received)
+	defer server.Close()
+
+	cfg := &Config{
+		Commands:            []string{"readme", "coverage", "build_size"},
+		Provider:            "ollama",
+		Model:               "llama3",
+		OllamaServerAddress: server.URL,
+		BuildCmd:            "touch built",
+		BuildArtifact:       "built",
+	}
+
+	t.Run("returns the summary without touching .xplane", func(t *testing.T) {
+		summary, err := Summarize(context.Background(), cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, "generated summary", summary)
+		assert.Contains(t, received["prompt"], "# demo")
+		assert.Contains(t, received["prompt"], "Total coverage from 'coverage.out': 80.0%")
+		assert.Contains(t, received["prompt"], "Skipped: not run in a dry run, it runs XPLANE_BUILD_CMD.")
+
+		_, statErr := os.Stat(filepath.Join(root, contextDir))
+		assert.True(t, os.IsNotExist(statErr))
+		_, statErr = os.Stat(filepath.Join(root, "built"))
+		assert.True(t, os.IsNotExist(statErr), "the build isn't run")
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := Summarize(ctx, cfg)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}